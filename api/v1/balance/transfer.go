@@ -41,10 +41,8 @@ func Transfer(c *gin.Context, in *TransferInputWithSignature) (*response.Respons
 		return nil, validationErr
 	}
 
-	commitFunc, err := service.Transfer(c.Request.Context(), config.GetDB(), in.From, in.To, &in.Value.Int)
-	if err != nil {
+	if err := service.Transfer(c.Request.Context(), config.GetDB(), in.From, in.To, &in.Value.Int); err != nil {
 		return nil, response.NewExceptionResponse(err)
 	}
-	commitFunc()
 	return &response.Response{}, nil
 }
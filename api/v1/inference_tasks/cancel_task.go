@@ -0,0 +1,66 @@
+package inference_tasks
+
+import (
+	"crynux_relay/api/v1/response"
+	"crynux_relay/api/v1/validate"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"crynux_relay/service"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type CancelTaskInput struct {
+	TaskIDCommitment string `path:"task_id_commitment" json:"task_id_commitment" description:"Task id commitment" validate:"required"`
+}
+
+type CancelTaskInputWithSignature struct {
+	CancelTaskInput
+	Timestamp int64  `json:"timestamp" description:"Signature timestamp" validate:"required"`
+	Signature string `json:"signature" description:"Signature" validate:"required"`
+}
+
+func CancelTask(c *gin.Context, in *CancelTaskInputWithSignature) (*response.Response, error) {
+	match, address, err := validate.ValidateSignature(in.CancelTaskInput, in.Timestamp, in.Signature)
+
+	if err != nil || !match {
+
+		if err != nil {
+			log.Debugln("error in sig validate: " + err.Error())
+		}
+
+		validationErr := response.NewValidationErrorResponse("signature", "Invalid signature")
+		return nil, validationErr
+	}
+
+	task, err := models.GetTaskByIDCommitment(c.Request.Context(), config.GetDB(), in.TaskIDCommitment)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			validationErr := response.NewValidationErrorResponse("task_id_commitment", "Task not found")
+			return nil, validationErr
+		} else {
+			return nil, response.NewExceptionResponse(err)
+		}
+	}
+
+	if task.Creator != address {
+		return nil, response.NewValidationErrorResponse("signature", "Signer not allowed")
+	}
+
+	if task.Status != models.TaskQueued && task.Status != models.TaskWaiting {
+		return nil, response.NewValidationErrorResponse("task_id_commitment", "Illegal task state")
+	}
+
+	// the task status is guarded in the update, so a task started by the dispatcher
+	// at the same time will never be cancelled
+	err = service.CancelTask(c.Request.Context(), config.GetDB(), task)
+	if errors.Is(err, models.ErrTaskStatusChanged) {
+		return nil, response.NewValidationErrorResponse("task_id_commitment", "Illegal task state")
+	} else if err != nil {
+		return nil, response.NewExceptionResponse(err)
+	}
+	return &response.Response{}, nil
+}
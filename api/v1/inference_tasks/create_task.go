@@ -1,18 +1,17 @@
 package inference_tasks
 
 import (
+	"context"
 	"crynux_relay/api/v1/response"
 	"crynux_relay/api/v1/validate"
 	"crynux_relay/config"
 	"crynux_relay/models"
 	"crynux_relay/service"
+	"crynux_relay/storage"
 	"crypto/rand"
 	"database/sql"
 	"errors"
-	"os"
-	"path/filepath"
-	"strconv"
-	"strings"
+	"fmt"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -21,6 +20,11 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	maxTaskDependencies = 16
+	maxTaskAttempts     = 10
+)
+
 type TaskInput struct {
 	TaskIDCommitment string           `path:"task_id_commitment" json:"task_id_commitment" description:"Task id commitment" validate:"required"`
 	TaskArgs         string           `form:"task_args" json:"task_args" description:"Task arguments" validate:"required"`
@@ -34,6 +38,10 @@ type TaskInput struct {
 	TaskSize         *uint64          `form:"task_size" json:"task_size" description:"task size"`
 	TaskFee          models.BigInt    `form:"task_fee" json:"task_fee" description:"task fee, in unit wei" validate:"required"`
 	Timeout          uint64          `form:"timeout" json:"timeout" description:"timeout, in minutes" validate:"required"`
+	DependsOn        []string         `form:"depends_on" json:"depends_on,omitempty" description:"task id commitments of the parent tasks, the task is queued after all parent tasks succeed"`
+	NotBefore        *int64           `form:"not_before" json:"not_before,omitempty" description:"unix timestamp before which the task will not be dispatched"`
+	MaxAttempts      *uint64          `form:"max_attempts" json:"max_attempts,omitempty" description:"max times the task is dispatched when the selected node fails, default 1"`
+	NodeRequirement  *string          `form:"node_requirement" json:"node_requirement,omitempty" description:"requirement expression on the node hardware, like platform != darwin && cuda >= 12"`
 }
 
 type TaskInputWithSignature struct {
@@ -55,22 +63,8 @@ func CreateTask(c *gin.Context, in *TaskInputWithSignature) (*TaskResponse, erro
 		return nil, validationErr
 	}
 
-	validationErr, err := models.ValidateTaskArgsJsonStr(in.TaskArgs, in.TaskType)
-	if err != nil {
-		return nil, response.NewExceptionResponse(err)
-	}
-	if validationErr != nil {
-		return nil, response.NewValidationErrorResponse("task_args", validationErr.Error())
-	}
-
-	taskVersions := strings.Split(in.TaskVersion, ".")
-	if len(taskVersions) != 3 {
-		return nil, response.NewValidationErrorResponse("task_version", "Invalid task version")
-	}
-	for i := 0; i < 3; i++ {
-		if _, err := strconv.ParseUint(taskVersions[i], 10, 64); err != nil {
-			return nil, response.NewValidationErrorResponse("task_version", "Invalid task version")
-		}
+	if err := validateTaskInput(c.Request.Context(), &in.TaskInput, ""); err != nil {
+		return nil, err
 	}
 
 	_, err = models.GetTaskByIDCommitment(c.Request.Context(), config.GetDB(), in.TaskIDCommitment)
@@ -81,7 +75,19 @@ func CreateTask(c *gin.Context, in *TaskInputWithSignature) (*TaskResponse, erro
 		return nil, response.NewExceptionResponse(err)
 	}
 
-	if in.TaskType == models.TaskTypeSDFTLora && c.ContentType() == "multipart/form-data" {
+	if err := validateTaskDependencies(c.Request.Context(), &in.TaskInput, address, "", nil); err != nil {
+		return nil, err
+	}
+
+	task, err := newTaskFromInput(&in.TaskInput, address)
+	if err != nil {
+		return nil, response.NewExceptionResponse(err)
+	}
+
+	// the checkpoint is saved before the task is created, as the task could be dispatched to a node right after,
+	// and it is deleted if the task is not created, so that no checkpoint is left without a task
+	checkpointKey := ""
+	if models.IsCheckpointTaskType(in.TaskType) && c.ContentType() == "multipart/form-data" {
 		form, err := c.MultipartForm()
 		if err != nil {
 			return nil, response.NewExceptionResponse(err)
@@ -91,71 +97,192 @@ func CreateTask(c *gin.Context, in *TaskInputWithSignature) (*TaskResponse, erro
 				return nil, response.NewValidationErrorResponse("checkpoint", "More than one checkpoint file uploaded")
 			}
 			checkpoint := files[0]
-		
-
-			appConfig := config.GetConfig()
-
-			taskDir := filepath.Join(appConfig.DataDir.InferenceTasks, in.TaskIDCommitment, "input")
-			if err = os.MkdirAll(taskDir, 0o711); err != nil {
+			checkpointKey = storage.TaskInputKey(in.TaskIDCommitment, "checkpoint.zip")
+			if err := saveUploadedTaskFile(c, checkpoint, checkpointKey); err != nil {
 				return nil, response.NewExceptionResponse(err)
 			}
-			checkpointFilename := filepath.Join(taskDir, "checkpoint.zip")
-			if err := c.SaveUploadedFile(checkpoint, checkpointFilename); err != nil {
-				return nil, response.NewExceptionResponse(err)
+		}
+	}
+
+	if err := service.CreateTask(c.Request.Context(), config.GetDB(), task, in.DependsOn); err != nil {
+		if len(checkpointKey) > 0 {
+			if err := storage.GetStorage().Delete(context.Background(), checkpointKey); err != nil {
+				log.Errorf("CreateTask: delete checkpoint of task %s error: %v", in.TaskIDCommitment, err)
 			}
 		}
+		return nil, response.NewExceptionResponse(err)
+	}
+
+	return &TaskResponse{Data: newCreatedTaskResponse(task)}, nil
+}
+
+// validateTaskInput checks the task input fields that do not depend on other tasks.
+// The prefix is prepended to the field name of the returned validation error.
+func validateTaskInput(ctx context.Context, in *TaskInput, prefix string) error {
+	if _, ok := models.GetTaskTypeSpec(in.TaskType); !ok {
+		return response.NewValidationErrorResponse(prefix+"task_type", "Unsupported task type")
+	}
+
+	if _, err := models.ParseVersion(in.TaskVersion); err != nil {
+		return response.NewValidationErrorResponse(prefix+"task_version", "Invalid task version")
+	}
+
+	validationErr, err := models.ValidateTaskArgsJsonStr(ctx, config.GetDB(), in.TaskArgs, in.TaskType, in.TaskVersion)
+	if err != nil {
+		return response.NewExceptionResponse(err)
+	}
+	if validationErr != nil {
+		return response.NewValidationErrorResponse(prefix+"task_args", validationErr.Error())
+	}
+
+	if in.NotBefore != nil && *in.NotBefore <= 0 {
+		return response.NewValidationErrorResponse(prefix+"not_before", "Invalid not before timestamp")
+	}
+
+	if in.MaxAttempts != nil && (*in.MaxAttempts == 0 || *in.MaxAttempts > maxTaskAttempts) {
+		return response.NewValidationErrorResponse(prefix+"max_attempts", fmt.Sprintf("Max attempts should be between 1 and %d", maxTaskAttempts))
 	}
 
+	if in.NodeRequirement != nil && len(*in.NodeRequirement) > 0 {
+		if _, err := service.ParseNodeRequirement(*in.NodeRequirement); err != nil {
+			return response.NewValidationErrorResponse(prefix+"node_requirement", "Invalid node requirement: "+err.Error())
+		}
+	}
+	return nil
+}
+
+// validateTaskDependencies checks that all parent tasks exist, are created by the same creator
+// and have not failed. Parent tasks can also be the tasks created before in the same batch.
+func validateTaskDependencies(ctx context.Context, in *TaskInput, creator, prefix string, batchTasks map[string]bool) error {
+	if len(in.DependsOn) == 0 {
+		return nil
+	}
+	if len(in.DependsOn) > maxTaskDependencies {
+		return response.NewValidationErrorResponse(prefix+"depends_on", fmt.Sprintf("Too many parent tasks, at most %d", maxTaskDependencies))
+	}
+
+	seen := make(map[string]bool)
+	var parentIDCommitments []string
+	for _, parent := range in.DependsOn {
+		if parent == in.TaskIDCommitment {
+			return response.NewValidationErrorResponse(prefix+"depends_on", "Task cannot depend on itself")
+		}
+		if seen[parent] {
+			return response.NewValidationErrorResponse(prefix+"depends_on", "Duplicate parent task")
+		}
+		seen[parent] = true
+		if !batchTasks[parent] {
+			parentIDCommitments = append(parentIDCommitments, parent)
+		}
+	}
+	if len(parentIDCommitments) == 0 {
+		return nil
+	}
+
+	parents, err := models.GetTasksByIDCommitments(ctx, config.GetDB(), parentIDCommitments)
+	if err != nil {
+		return response.NewExceptionResponse(err)
+	}
+	if len(parents) != len(parentIDCommitments) {
+		return response.NewValidationErrorResponse(prefix+"depends_on", "Parent task not found")
+	}
+	for _, parent := range parents {
+		if parent.Creator != creator {
+			return response.NewValidationErrorResponse(prefix+"depends_on", "Parent task not found")
+		}
+		if parent.Status == models.TaskEndAborted || parent.Status == models.TaskEndInvalidated {
+			return response.NewValidationErrorResponse(prefix+"depends_on", "Parent task failed")
+		}
+	}
+	return nil
+}
+
+func newTaskFromInput(in *TaskInput, creator string) (*models.InferenceTask, error) {
 	samplingSeedBytes := make([]byte, 32)
 	if _, err := rand.Read(samplingSeedBytes); err != nil {
-		return nil, response.NewExceptionResponse(err)
+		return nil, err
 	}
 	samplingSeed := hexutil.Encode(samplingSeedBytes)
 
-
 	task := &models.InferenceTask{
 		TaskArgs:         in.TaskArgs,
 		TaskIDCommitment: in.TaskIDCommitment,
-		Creator:          address,
+		Creator:          creator,
 		SamplingSeed:     samplingSeed,
 		Nonce:            in.Nonce,
 		Status:           models.TaskQueued,
 		TaskType:         in.TaskType,
 		TaskVersion:      in.TaskVersion,
-		MinVRAM:          *in.MinVram,
-		RequiredGPU:      *in.RequiredGPU,
-		RequiredGPUVRAM:  *in.RequiredGPUVram,
 		TaskFee:          in.TaskFee,
-		TaskSize:         *in.TaskSize,
 		ModelIDs:         in.TaskModelIDs,
 		CreateTime: sql.NullTime{
 			Time:  time.Now(),
 			Valid: true,
 		},
-		Timeout: in.Timeout,
+		Timeout:     in.Timeout,
+		MaxAttempts: 1,
 	}
-
-	if err := service.CreateTask(c.Request.Context(), config.GetDB(), task); err != nil {
-		return nil, response.NewExceptionResponse(err)
+	if in.MinVram != nil {
+		task.MinVRAM = *in.MinVram
+	}
+	if in.RequiredGPU != nil {
+		task.RequiredGPU = *in.RequiredGPU
+	}
+	if in.RequiredGPUVram != nil {
+		task.RequiredGPUVRAM = *in.RequiredGPUVram
+	}
+	if in.TaskSize != nil {
+		task.TaskSize = *in.TaskSize
 	}
+	if in.NotBefore != nil {
+		task.NotBefore = sql.NullTime{Time: time.Unix(*in.NotBefore, 0), Valid: true}
+	}
+	if in.MaxAttempts != nil {
+		task.MaxAttempts = *in.MaxAttempts
+	}
+	if in.NodeRequirement != nil {
+		task.NodeRequirement = *in.NodeRequirement
+	}
+	if len(in.DependsOn) > 0 {
+		task.Status = models.TaskWaiting
+	}
+	v, threshold, err := models.ResolveTaskValidator(task.TaskType, task.ModelIDs)
+	if err != nil {
+		return nil, err
+	}
+	task.Validator = v.Name()
+	task.ValidatorThreshold = threshold
+	return task, nil
+}
 
-	return &TaskResponse{Data: &InferenceTask{
-		Sequence:         uint64(task.ID),
-		TaskArgs:         task.TaskArgs,
-		TaskIDCommitment: task.TaskIDCommitment,
-		Creator:          task.Creator,
-		SamplingSeed:     task.SamplingSeed,
-		Nonce:            task.Nonce,
-		Status:           task.Status,
-		TaskType:         task.TaskType,
-		TaskVersion:      task.TaskVersion,
-		MinVRAM:          task.MinVRAM,
-		RequiredGPU:      task.RequiredGPU,
-		RequiredGPUVRAM:  task.RequiredGPUVRAM,
-		TaskFee:          task.TaskFee,
-		TaskSize:         task.TaskSize,
-		ModelIDs:         task.ModelIDs,
-		CreateTime:       &task.CreateTime.Time,
-		Timeout:          task.Timeout,
-	}}, nil
+func newCreatedTaskResponse(task *models.InferenceTask) *InferenceTask {
+	t := &InferenceTask{
+		Sequence:           uint64(task.ID),
+		TaskArgs:           task.TaskArgs,
+		TaskIDCommitment:   task.TaskIDCommitment,
+		Creator:            task.Creator,
+		SamplingSeed:       task.SamplingSeed,
+		Nonce:              task.Nonce,
+		Status:             task.Status,
+		TaskType:           task.TaskType,
+		TaskVersion:        task.TaskVersion,
+		MinVRAM:            task.MinVRAM,
+		RequiredGPU:        task.RequiredGPU,
+		RequiredGPUVRAM:    task.RequiredGPUVRAM,
+		NodeRequirement:    task.NodeRequirement,
+		TaskFee:            task.TaskFee,
+		TaskSize:           task.TaskSize,
+		ModelIDs:           task.ModelIDs,
+		CreateTime:         &task.CreateTime.Time,
+		Timeout:            task.Timeout,
+		Scheduled:          task.IsScheduled(),
+		MaxAttempts:        task.MaxAttempts,
+		Attempts:           task.Attempts,
+		Validator:          task.Validator,
+		ValidatorThreshold: task.ValidatorThreshold,
+	}
+	if task.NotBefore.Valid {
+		t.NotBefore = &task.NotBefore.Time
+	}
+	return t
 }
@@ -0,0 +1,123 @@
+package inference_tasks
+
+import (
+	"crynux_relay/api/v1/response"
+	"crynux_relay/api/v1/validate"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"crynux_relay/service"
+	"fmt"
+	"math/big"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+const maxBatchTaskCount = 500
+
+type BatchTaskInput struct {
+	Tasks []TaskInput `json:"tasks" description:"Tasks to create" validate:"required"`
+}
+
+type BatchTaskInputWithSignature struct {
+	BatchTaskInput
+	Timestamp int64  `json:"timestamp" description:"Signature timestamp" validate:"required"`
+	Signature string `json:"signature" description:"Signature" validate:"required"`
+}
+
+func CreateTasks(c *gin.Context, in *BatchTaskInputWithSignature) (*TasksResponse, error) {
+	match, address, err := validate.ValidateSignature(in.BatchTaskInput, in.Timestamp, in.Signature)
+
+	if err != nil || !match {
+
+		if err != nil {
+			log.Debugln("error in sig validate: " + err.Error())
+		}
+
+		validationErr := response.NewValidationErrorResponse("signature", "Invalid signature")
+		return nil, validationErr
+	}
+
+	if len(in.Tasks) == 0 {
+		return nil, response.NewValidationErrorResponse("tasks", "No task in the batch")
+	}
+	if len(in.Tasks) > maxBatchTaskCount {
+		return nil, response.NewValidationErrorResponse("tasks", fmt.Sprintf("Too many tasks in the batch, at most %d", maxBatchTaskCount))
+	}
+
+	taskIDCommitments := make([]string, 0, len(in.Tasks))
+	seen := make(map[string]bool)
+	totalFee := big.NewInt(0)
+	for i := range in.Tasks {
+		prefix := fmt.Sprintf("tasks.%d.", i)
+		taskInput := &in.Tasks[i]
+
+		if len(taskInput.TaskIDCommitment) == 0 {
+			return nil, response.NewValidationErrorResponse(prefix+"task_id_commitment", "Task id commitment is required")
+		}
+		if seen[taskInput.TaskIDCommitment] {
+			return nil, response.NewValidationErrorResponse(prefix+"task_id_commitment", "Duplicate task in the batch")
+		}
+		seen[taskInput.TaskIDCommitment] = true
+		taskIDCommitments = append(taskIDCommitments, taskInput.TaskIDCommitment)
+
+		if err := validateTaskInput(c.Request.Context(), taskInput, prefix); err != nil {
+			return nil, err
+		}
+		totalFee.Add(totalFee, &taskInput.TaskFee.Int)
+	}
+
+	existedTasks, err := models.GetTasksByIDCommitments(c.Request.Context(), config.GetDB(), taskIDCommitments)
+	if err != nil {
+		return nil, response.NewExceptionResponse(err)
+	}
+	if len(existedTasks) > 0 {
+		for i, taskIDCommitment := range taskIDCommitments {
+			if taskIDCommitment == existedTasks[0].TaskIDCommitment {
+				return nil, response.NewValidationErrorResponse(fmt.Sprintf("tasks.%d.task_id_commitment", i), "Task already uploaded")
+			}
+		}
+	}
+
+	batchTasks := make(map[string]bool)
+	dependsOn := make(map[string][]string)
+	for i := range in.Tasks {
+		taskInput := &in.Tasks[i]
+		if err := validateTaskDependencies(c.Request.Context(), taskInput, address, fmt.Sprintf("tasks.%d.", i), batchTasks); err != nil {
+			return nil, err
+		}
+		batchTasks[taskInput.TaskIDCommitment] = true
+		if len(taskInput.DependsOn) > 0 {
+			dependsOn[taskInput.TaskIDCommitment] = taskInput.DependsOn
+		}
+	}
+
+	balance, err := service.GetBalance(c.Request.Context(), config.GetDB(), address)
+	if err != nil {
+		return nil, response.NewExceptionResponse(err)
+	}
+	if balance.Cmp(totalFee) < 0 {
+		return nil, response.NewValidationErrorResponse("balance", "Insufficient balance")
+	}
+
+	tasks := make([]*models.InferenceTask, 0, len(in.Tasks))
+	for i := range in.Tasks {
+		task, err := newTaskFromInput(&in.Tasks[i], address)
+		if err != nil {
+			return nil, response.NewExceptionResponse(err)
+		}
+		tasks = append(tasks, task)
+	}
+
+	// all tasks are inserted and the total fee is transferred in one transaction,
+	// so either the whole batch is created or none of it is
+	if err := service.CreateTasks(c.Request.Context(), config.GetDB(), tasks, dependsOn); err != nil {
+		return nil, response.NewExceptionResponse(err)
+	}
+
+	data := make([]InferenceTask, 0, len(tasks))
+	for _, task := range tasks {
+		data = append(data, *newCreatedTaskResponse(task))
+	}
+	return &TasksResponse{Data: data}, nil
+}
@@ -0,0 +1,154 @@
+package inference_tasks
+
+import (
+	"crynux_relay/api/v1/response"
+	"crynux_relay/api/v1/validate"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"crynux_relay/service"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type DisputeTaskInput struct {
+	TaskIDCommitment string `path:"task_id_commitment" json:"task_id_commitment" description:"Task id commitment" validate:"required"`
+}
+
+type DisputeTaskInputWithSignature struct {
+	DisputeTaskInput
+	Timestamp int64  `json:"timestamp" description:"Signature timestamp" validate:"required"`
+	Signature string `json:"signature" description:"Signature" validate:"required"`
+}
+
+type TaskDispute struct {
+	TaskIDCommitment              string                   `json:"task_id_commitment"`
+	Creator                       string                   `json:"creator"`
+	SelectedNode                  string                   `json:"selected_node"`
+	Deposit                       models.BigInt            `json:"deposit"`
+	VerificationTaskIDCommitments []string                 `json:"verification_task_id_commitments"`
+	Status                        models.TaskDisputeStatus `json:"status"`
+	ClawbackAmount                models.BigInt            `json:"clawback_amount"`
+	CreateTime                    time.Time                `json:"create_time"`
+	ResolvedTime                  *time.Time               `json:"resolved_time,omitempty"`
+}
+
+type TaskDisputeResponse struct {
+	response.Response
+	Data *TaskDispute `json:"data"`
+}
+
+func newTaskDisputeResponse(dispute *models.TaskDispute) *TaskDispute {
+	d := &TaskDispute{
+		TaskIDCommitment:              dispute.TaskIDCommitment,
+		Creator:                       dispute.Creator,
+		SelectedNode:                  dispute.SelectedNode,
+		Deposit:                       dispute.Deposit,
+		VerificationTaskIDCommitments: dispute.VerificationTaskIDCommitments,
+		Status:                        dispute.Status,
+		ClawbackAmount:                dispute.ClawbackAmount,
+		CreateTime:                    dispute.CreatedAt,
+	}
+	if dispute.ResolvedTime.Valid {
+		d.ResolvedTime = &dispute.ResolvedTime.Time
+	}
+	return d
+}
+
+func DisputeTask(c *gin.Context, in *DisputeTaskInputWithSignature) (*TaskDisputeResponse, error) {
+	match, address, err := validate.ValidateSignature(in.DisputeTaskInput, in.Timestamp, in.Signature)
+
+	if err != nil || !match {
+
+		if err != nil {
+			log.Debugln("error in sig validate: " + err.Error())
+		}
+
+		validationErr := response.NewValidationErrorResponse("signature", "Invalid signature")
+		return nil, validationErr
+	}
+
+	appConfig := config.GetConfig()
+	if !appConfig.Dispute.Enabled {
+		return nil, response.NewValidationErrorResponse("task_id_commitment", "Dispute not enabled")
+	}
+
+	task, err := models.GetTaskByIDCommitment(c.Request.Context(), config.GetDB(), in.TaskIDCommitment)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			validationErr := response.NewValidationErrorResponse("task_id_commitment", "Task not found")
+			return nil, validationErr
+		} else {
+			return nil, response.NewExceptionResponse(err)
+		}
+	}
+
+	if task.Creator != address {
+		return nil, response.NewValidationErrorResponse("signature", "Signer not allowed")
+	}
+
+	// only tasks validated alone can be disputed, tasks validated in a group end in TaskEndGroupSuccess
+	if task.Status != models.TaskEndSuccess || len(task.DisputeTaskIDCommitment) > 0 {
+		return nil, response.NewValidationErrorResponse("task_id_commitment", "Task not disputable")
+	}
+	window := time.Duration(appConfig.Dispute.WindowHours) * time.Hour
+	if !task.ResultUploadedTime.Valid || task.ResultUploadedTime.Time.Add(window).Before(time.Now()) {
+		return nil, response.NewValidationErrorResponse("task_id_commitment", "Dispute window closed")
+	}
+
+	if _, err := models.GetTaskDispute(c.Request.Context(), config.GetDB(), task.TaskIDCommitment); err == nil {
+		return nil, response.NewValidationErrorResponse("task_id_commitment", "Task already disputed")
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, response.NewExceptionResponse(err)
+	}
+
+	balance, err := service.GetBalance(c.Request.Context(), config.GetDB(), address)
+	if err != nil {
+		return nil, response.NewExceptionResponse(err)
+	}
+	if balance.Cmp(service.GetDisputeCost(task)) < 0 {
+		return nil, response.NewValidationErrorResponse("balance", "Insufficient balance")
+	}
+
+	dispute, err := service.OpenTaskDispute(c.Request.Context(), config.GetDB(), task)
+	if errors.Is(err, service.ErrTaskSpotCheckPending) {
+		return nil, response.NewValidationErrorResponse("task_id_commitment", "Task is being spot checked")
+	} else if errors.Is(err, service.ErrTaskSpotCheckFailed) {
+		return nil, response.NewValidationErrorResponse("task_id_commitment", "Task refunded by spot check")
+	} else if err != nil {
+		return nil, response.NewExceptionResponse(err)
+	}
+	return &TaskDisputeResponse{Data: newTaskDisputeResponse(dispute)}, nil
+}
+
+func GetTaskDispute(c *gin.Context, in *GetTaskInputWithSignature) (*TaskDisputeResponse, error) {
+	match, address, err := validate.ValidateSignature(in.GetTaskInput, in.Timestamp, in.Signature)
+
+	if err != nil || !match {
+
+		if err != nil {
+			log.Debugln("error in sig validate: " + err.Error())
+		}
+
+		validationErr := response.NewValidationErrorResponse("signature", "Invalid signature")
+		return nil, validationErr
+	}
+
+	dispute, err := models.GetTaskDispute(c.Request.Context(), config.GetDB(), in.TaskIDCommitment)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			validationErr := response.NewValidationErrorResponse("task_id_commitment", "Dispute not found")
+			return nil, validationErr
+		} else {
+			return nil, response.NewExceptionResponse(err)
+		}
+	}
+
+	if dispute.Creator != address && dispute.SelectedNode != address {
+		return nil, response.NewValidationErrorResponse("signature", "Signer not allowed")
+	}
+	return &TaskDisputeResponse{Data: newTaskDisputeResponse(dispute)}, nil
+}
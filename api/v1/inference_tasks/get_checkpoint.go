@@ -5,9 +5,8 @@ import (
 	"crynux_relay/api/v1/validate"
 	"crynux_relay/config"
 	"crynux_relay/models"
+	"crynux_relay/storage"
 	"errors"
-	"os"
-	"path/filepath"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -40,29 +39,23 @@ func GetCheckpoint(c *gin.Context, in *GetCheckpointInputWithSignature) error {
 		}
 	}
 
-	if task.Status == models.TaskQueued {
+	if task.Status == models.TaskQueued || task.Status == models.TaskWaiting {
 		return response.NewValidationErrorResponse("task_id", "Task not ready")
 	}
 
+	if task.ResultsPurgedAt.Valid {
+		return response.NewValidationErrorResponse("task_id", "Task checkpoint expired")
+	}
+
 	if task.Creator != address && task.SelectedNode != address {
 		return response.NewValidationErrorResponse("signature", "Signer not allowed")
 	}
 
-	appConfig := config.GetConfig()
-	resultFile := filepath.Join(
-		appConfig.DataDir.InferenceTasks,
-		task.TaskIDCommitment,
-		"input",
-		"checkpoint.zip",
-	)
-	if _, err := os.Stat(resultFile); err != nil {
+	err = serveTaskFile(c, storage.TaskInputKey(task.TaskIDCommitment, "checkpoint.zip"), "checkpoint.zip", "application/octet-stream")
+	if errors.Is(err, storage.ErrNotFound) {
 		return response.NewValidationErrorResponse("task_id", "Checkpoint file not found")
+	} else if err != nil {
+		return response.NewExceptionResponse(err)
 	}
-
-	c.Header("Content-Description", "File Transfer")
-	c.Header("Content-Transfer-Encoding", "binary")
-	c.Header("Content-Disposition", "attachment; filename=checkpoint.zip")
-	c.Header("Content-Type", "application/octet-stream")
-	c.File(resultFile)
 	return nil
 }
\ No newline at end of file
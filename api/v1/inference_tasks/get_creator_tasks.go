@@ -0,0 +1,144 @@
+package inference_tasks
+
+import (
+	"crynux_relay/api/v1/response"
+	"crynux_relay/api/v1/validate"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"math/big"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+type GetCreatorTasksInput struct {
+	Address string `path:"address" json:"address" validate:"required" description:"The creator address"`
+}
+
+type GetCreatorTasksInputWithSignature struct {
+	GetCreatorTasksInput
+	Status    []models.TaskStatus `query:"status" description:"Only tasks in these statuses"`
+	TaskType  *models.TaskType    `query:"task_type" description:"Only tasks of the task type"`
+	StartTime *int64              `query:"start_time" description:"Only tasks created at or after the unix timestamp"`
+	EndTime   *int64              `query:"end_time" description:"Only tasks created before the unix timestamp"`
+	ModelID   *string             `query:"model_id" description:"Only tasks using the model"`
+	MinFee    *string             `query:"min_fee" description:"Only tasks with task fee at least the amount, in unit wei"`
+	MaxFee    *string             `query:"max_fee" description:"Only tasks with task fee at most the amount, in unit wei"`
+	Cursor    uint                `query:"cursor" description:"The next_cursor of the previous page, 0 for the first page"`
+	Limit     int                 `query:"limit" default:"50" validate:"min=1,max=100" description:"Task count limit"`
+	Timestamp int64               `query:"timestamp" json:"timestamp" description:"Signature timestamp" validate:"required"`
+	Signature string              `query:"signature" json:"signature" description:"Signature" validate:"required"`
+}
+
+type TaskStatusCount struct {
+	Status models.TaskStatus `json:"status"`
+	Count  int64             `json:"count"`
+}
+
+type CreatorTaskSummary struct {
+	StatusCounts  []TaskStatusCount `json:"status_counts"`
+	TotalSpent    models.BigInt     `json:"total_spent"`
+	TotalRefunded models.BigInt     `json:"total_refunded"`
+}
+
+type CreatorTasks struct {
+	Tasks      []InferenceTask    `json:"tasks"`
+	NextCursor uint               `json:"next_cursor"`
+	Summary    CreatorTaskSummary `json:"summary"`
+}
+
+type CreatorTasksResponse struct {
+	response.Response
+	Data *CreatorTasks `json:"data"`
+}
+
+func parseFee(field string, fee *string) (*big.Int, error) {
+	if fee == nil {
+		return nil, nil
+	}
+	res, ok := new(big.Int).SetString(*fee, 10)
+	if !ok || res.Sign() < 0 {
+		return nil, response.NewValidationErrorResponse(field, "Invalid task fee")
+	}
+	return res, nil
+}
+
+// GetCreatorTasks lists the tasks of the creator, newest first. The summary covers all the tasks matching the filters, not only the page.
+// The fee of tasks ended successfully or invalidated is spent, and the fee of aborted or group refunded tasks is refunded.
+func GetCreatorTasks(c *gin.Context, in *GetCreatorTasksInputWithSignature) (*CreatorTasksResponse, error) {
+	match, address, err := validate.ValidateSignature(in.GetCreatorTasksInput, in.Timestamp, in.Signature)
+
+	if err != nil || !match {
+
+		if err != nil {
+			log.Debugln("error in sig validate: " + err.Error())
+		}
+
+		validationErr := response.NewValidationErrorResponse("signature", "Invalid signature")
+		return nil, validationErr
+	}
+
+	if address != in.Address {
+		return nil, response.NewValidationErrorResponse("signature", "Signer not allowed")
+	}
+
+	filter := &models.CreatorTaskFilter{
+		Statuses: in.Status,
+		TaskType: in.TaskType,
+	}
+	if in.StartTime != nil {
+		startTime := time.Unix(*in.StartTime, 0)
+		filter.StartTime = &startTime
+	}
+	if in.EndTime != nil {
+		endTime := time.Unix(*in.EndTime, 0)
+		filter.EndTime = &endTime
+	}
+	if in.ModelID != nil {
+		filter.ModelID = *in.ModelID
+	}
+	if filter.MinFee, err = parseFee("min_fee", in.MinFee); err != nil {
+		return nil, err
+	}
+	if filter.MaxFee, err = parseFee("max_fee", in.MaxFee); err != nil {
+		return nil, err
+	}
+
+	tasks, err := models.GetCreatorTasks(c.Request.Context(), config.GetDB(), address, filter, in.Cursor, in.Limit)
+	if err != nil {
+		return nil, response.NewExceptionResponse(err)
+	}
+	summaries, err := models.GetCreatorTaskSummary(c.Request.Context(), config.GetDB(), address, filter)
+	if err != nil {
+		return nil, response.NewExceptionResponse(err)
+	}
+
+	data := &CreatorTasks{
+		Tasks: make([]InferenceTask, 0, len(tasks)),
+		Summary: CreatorTaskSummary{
+			StatusCounts:  make([]TaskStatusCount, 0, len(summaries)),
+			TotalSpent:    models.BigInt{Int: *big.NewInt(0)},
+			TotalRefunded: models.BigInt{Int: *big.NewInt(0)},
+		},
+	}
+	for i := range tasks {
+		data.Tasks = append(data.Tasks, *newTaskResponse(&tasks[i]))
+	}
+	if len(tasks) == in.Limit {
+		data.NextCursor = tasks[len(tasks)-1].ID
+	}
+	for _, summary := range summaries {
+		data.Summary.StatusCounts = append(data.Summary.StatusCounts, TaskStatusCount{
+			Status: summary.Status,
+			Count:  summary.Count,
+		})
+		switch summary.Status {
+		case models.TaskEndSuccess, models.TaskEndGroupSuccess, models.TaskEndInvalidated:
+			data.Summary.TotalSpent.Add(&data.Summary.TotalSpent.Int, summary.TotalFee)
+		case models.TaskEndAborted, models.TaskEndGroupRefund:
+			data.Summary.TotalRefunded.Add(&data.Summary.TotalRefunded.Int, summary.TotalFee)
+		}
+	}
+	return &CreatorTasksResponse{Data: data}, nil
+}
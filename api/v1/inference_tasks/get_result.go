@@ -5,9 +5,8 @@ import (
 	"crynux_relay/api/v1/validate"
 	"crynux_relay/config"
 	"crynux_relay/models"
+	"crynux_relay/storage"
 	"errors"
-	"os"
-	"path/filepath"
 
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
@@ -56,32 +55,23 @@ func GetResult(c *gin.Context, in *GetResultInputWithSignature) error {
 		return response.NewValidationErrorResponse("task_id", "Task results not uploaded")
 	}
 
-	appConfig := config.GetConfig()
-
-	var fileExt string
-	if task.TaskType == models.TaskTypeSD || task.TaskType == models.TaskTypeSDFTLora {
-		fileExt = ".png"
-	} else {
-		fileExt = ".json"
+	if task.ResultsPurgedAt.Valid {
+		return response.NewValidationErrorResponse("task_id", "Task results expired")
 	}
 
-	resultFile := filepath.Join(
-		appConfig.DataDir.InferenceTasks,
-		task.TaskIDCommitment,
-		"results",
-		in.Index+fileExt,
-	)
+	spec, ok := models.GetTaskTypeSpec(task.TaskType)
+	if !ok {
+		return response.NewValidationErrorResponse("task_id_commitment", "Task type not supported")
+	}
 
-	if _, err := os.Stat(resultFile); err != nil {
+	filename := in.Index + spec.ResultFileExt
+	err = serveTaskFile(c, storage.TaskResultKey(task.TaskIDCommitment, filename), filename, spec.ResultMimeType)
+	if errors.Is(err, storage.ErrNotFound) {
 		return response.NewValidationErrorResponse("index", "File not found")
+	} else if err != nil {
+		return response.NewExceptionResponse(err)
 	}
 
-	c.Header("Content-Description", "File Transfer")
-	c.Header("Content-Transfer-Encoding", "binary")
-	c.Header("Content-Disposition", "attachment; filename="+in.Index+fileExt)
-	c.Header("Content-Type", "application/octet-stream")
-	c.File(resultFile)
-
 	return nil
 }
 
@@ -125,24 +115,17 @@ func GetResultCheckpoint(c *gin.Context, in *GetResultCheckpointInputWithSignatu
 		return response.NewValidationErrorResponse("task_id", "Task checkpoint not uploaded")
 	}
 
-	appConfig := config.GetConfig()
-	resultFile := filepath.Join(
-		appConfig.DataDir.InferenceTasks,
-		task.TaskIDCommitment,
-		"results",
-		"checkpoint.zip",
-	)
+	if task.ResultsPurgedAt.Valid {
+		return response.NewValidationErrorResponse("task_id", "Task checkpoint expired")
+	}
 
-	if _, err := os.Stat(resultFile); err != nil {
+	err = serveTaskFile(c, storage.TaskResultKey(task.TaskIDCommitment, "checkpoint.zip"), "checkpoint.zip", "application/octet-stream")
+	if errors.Is(err, storage.ErrNotFound) {
 		return response.NewValidationErrorResponse("task_id", "Checkpoint file not found")
+	} else if err != nil {
+		return response.NewExceptionResponse(err)
 	}
 
-	c.Header("Content-Description", "File Transfer")
-	c.Header("Content-Transfer-Encoding", "binary")
-	c.Header("Content-Disposition", "attachment; filename=checkpoint.zip")
-	c.Header("Content-Type", "application/octet-stream")
-	c.File(resultFile)
-
 	return nil
 
 }
@@ -0,0 +1,79 @@
+package inference_tasks
+
+import (
+	"crynux_relay/api/v1/response"
+	"crynux_relay/api/v1/validate"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type TaskAttempt struct {
+	Attempt     uint64                    `json:"attempt"`
+	NodeAddress string                    `json:"node_address"`
+	StartTime   *time.Time                `json:"start_time,omitempty"`
+	EndTime     *time.Time                `json:"end_time,omitempty"`
+	Outcome     models.TaskAttemptOutcome `json:"outcome"`
+	AbortReason models.TaskAbortReason    `json:"abort_reason"`
+}
+
+type TaskAttemptsResponse struct {
+	response.Response
+	Data []TaskAttempt `json:"data"`
+}
+
+func GetTaskAttempts(c *gin.Context, in *GetTaskInputWithSignature) (*TaskAttemptsResponse, error) {
+	match, address, err := validate.ValidateSignature(in.GetTaskInput, in.Timestamp, in.Signature)
+
+	if err != nil || !match {
+
+		if err != nil {
+			log.Debugln("error in sig validate: " + err.Error())
+		}
+
+		validationErr := response.NewValidationErrorResponse("signature", "Invalid signature")
+		return nil, validationErr
+	}
+
+	task, err := models.GetTaskByIDCommitment(c.Request.Context(), config.GetDB(), in.TaskIDCommitment)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			validationErr := response.NewValidationErrorResponse("task_id_commitment", "Task not found")
+			return nil, validationErr
+		} else {
+			return nil, response.NewExceptionResponse(err)
+		}
+	}
+
+	if task.Creator != address {
+		return nil, response.NewValidationErrorResponse("signature", "Signer not allowed")
+	}
+
+	attempts, err := models.GetTaskAttempts(c.Request.Context(), config.GetDB(), task.TaskIDCommitment)
+	if err != nil {
+		return nil, response.NewExceptionResponse(err)
+	}
+
+	data := make([]TaskAttempt, 0, len(attempts))
+	for _, attempt := range attempts {
+		a := TaskAttempt{
+			Attempt:     attempt.Attempt,
+			NodeAddress: attempt.NodeAddress,
+			Outcome:     attempt.Outcome,
+			AbortReason: attempt.AbortReason,
+		}
+		if attempt.StartTime.Valid {
+			a.StartTime = &attempt.StartTime.Time
+		}
+		if attempt.EndTime.Valid {
+			a.EndTime = &attempt.EndTime.Time
+		}
+		data = append(data, a)
+	}
+	return &TaskAttemptsResponse{Data: data}, nil
+}
@@ -54,48 +54,5 @@ func GetTaskById(c *gin.Context, in *GetTaskInputWithSignature) (*TaskResponse,
 		return nil, response.NewValidationErrorResponse("signature", "Signer not allowed")
 	}
 
-	qosScore := uint64(0)
-	if task.QOSScore.Valid {
-		qosScore = uint64(task.QOSScore.Int64)
-	}
-
-	t := &InferenceTask{
-		Sequence:         uint64(task.ID),
-		TaskArgs:         task.TaskArgs,
-		TaskIDCommitment: task.TaskIDCommitment,
-		Creator:          task.Creator,
-		SamplingSeed:     task.SamplingSeed,
-		Nonce:            task.Nonce,
-		Status:           task.Status,
-		TaskType:         task.TaskType,
-		TaskVersion:      task.TaskVersion,
-		Timeout:          task.Timeout,
-		MinVRAM:          task.MinVRAM,
-		RequiredGPU:      task.RequiredGPU,
-		RequiredGPUVRAM:  task.RequiredGPUVRAM,
-		TaskFee:          task.TaskFee,
-		TaskSize:         task.TaskSize,
-		ModelIDs:         task.ModelIDs,
-		AbortReason:      task.AbortReason,
-		TaskError:        task.TaskError,
-		Score:            task.Score,
-		QOSScore:         qosScore,
-		SelectedNode:     task.SelectedNode,
-	}
-	if task.CreateTime.Valid {
-		t.CreateTime = &task.CreateTime.Time
-	}
-	if task.StartTime.Valid {
-		t.StartTime = &task.StartTime.Time
-	}
-	if task.ScoreReadyTime.Valid {
-		t.ScoreReadyTime = &task.ScoreReadyTime.Time
-	}
-	if task.ValidatedTime.Valid {
-		t.ValidatedTime = &task.ValidatedTime.Time
-	}
-	if task.ResultUploadedTime.Valid {
-		t.ResultUploadedTime = &task.ResultUploadedTime.Time
-	}
-	return &TaskResponse{Data: t}, nil
+	return &TaskResponse{Data: newTaskResponse(task)}, nil
 }
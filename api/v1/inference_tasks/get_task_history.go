@@ -0,0 +1,81 @@
+package inference_tasks
+
+import (
+	"crynux_relay/api/v1/response"
+	"crynux_relay/api/v1/validate"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type TaskStatusTransition struct {
+	Event      models.TaskStatusEvent `json:"event"`
+	FromStatus models.TaskStatus      `json:"from_status"`
+	ToStatus   models.TaskStatus      `json:"to_status"`
+	Actor      string                 `json:"actor"`
+	Reason     string                 `json:"reason"`
+	Time       time.Time              `json:"time"`
+}
+
+type TaskHistoryResponse struct {
+	response.Response
+	Data []TaskStatusTransition `json:"data"`
+}
+
+func GetTaskHistory(c *gin.Context, in *GetTaskInputWithSignature) (*TaskHistoryResponse, error) {
+	match, address, err := validate.ValidateSignature(in.GetTaskInput, in.Timestamp, in.Signature)
+
+	if err != nil || !match {
+
+		if err != nil {
+			log.Debugln("error in sig validate: " + err.Error())
+		}
+
+		validationErr := response.NewValidationErrorResponse("signature", "Invalid signature")
+		return nil, validationErr
+	}
+
+	task, err := models.GetTaskByIDCommitment(c.Request.Context(), config.GetDB(), in.TaskIDCommitment)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			validationErr := response.NewValidationErrorResponse("task_id_commitment", "Task not found")
+			return nil, validationErr
+		} else {
+			return nil, response.NewExceptionResponse(err)
+		}
+	}
+
+	transitions, err := models.GetTaskStatusTransitions(c.Request.Context(), config.GetDB(), task.TaskIDCommitment)
+	if err != nil {
+		return nil, response.NewExceptionResponse(err)
+	}
+
+	// the creator and the nodes that have worked on the task are allowed to see its history
+	allowed := task.Creator == address || task.SelectedNode == address
+	for _, transition := range transitions {
+		if transition.Actor == address {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return nil, response.NewValidationErrorResponse("signature", "Signer not allowed")
+	}
+
+	data := make([]TaskStatusTransition, 0, len(transitions))
+	for _, transition := range transitions {
+		data = append(data, TaskStatusTransition{
+			Event:      transition.Event,
+			FromStatus: transition.FromStatus,
+			ToStatus:   transition.ToStatus,
+			Actor:      transition.Actor,
+			Reason:     transition.Reason,
+			Time:       transition.CreatedAt,
+		})
+	}
+	return &TaskHistoryResponse{Data: data}, nil
+}
@@ -0,0 +1,116 @@
+package inference_tasks
+
+import (
+	"crynux_relay/api/v1/response"
+	"crynux_relay/api/v1/validate"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type ValidationRecord struct {
+	TaskIDCommitment string                        `json:"task_id_commitment"`
+	SelectedNode     string                        `json:"selected_node"`
+	ExecutionOrder   uint64                        `json:"execution_order"`
+	ExecutionTime    uint64                        `json:"execution_time"`
+	Status           models.TaskStatus             `json:"status"`
+	Score            string                        `json:"score"`
+	Comparisons      []models.ValidationComparison `json:"comparisons"`
+	QOSScore         *uint64                       `json:"qos_score,omitempty"`
+	Verdict          models.TaskStatus             `json:"verdict"`
+}
+
+type TaskValidation struct {
+	TaskID        string             `json:"task_id"`
+	Grouped       bool               `json:"grouped"`
+	Validator     string             `json:"validator"`
+	Threshold     float64            `json:"threshold"`
+	ValidatedTime time.Time          `json:"validated_time"`
+	Records       []ValidationRecord `json:"records"`
+}
+
+type TaskValidationResponse struct {
+	response.Response
+	Data *TaskValidation `json:"data"`
+}
+
+func GetTaskValidation(c *gin.Context, in *GetTaskInputWithSignature) (*TaskValidationResponse, error) {
+	match, address, err := validate.ValidateSignature(in.GetTaskInput, in.Timestamp, in.Signature)
+
+	if err != nil || !match {
+
+		if err != nil {
+			log.Debugln("error in sig validate: " + err.Error())
+		}
+
+		validationErr := response.NewValidationErrorResponse("signature", "Invalid signature")
+		return nil, validationErr
+	}
+
+	task, err := models.GetTaskByIDCommitment(c.Request.Context(), config.GetDB(), in.TaskIDCommitment)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			validationErr := response.NewValidationErrorResponse("task_id_commitment", "Task not found")
+			return nil, validationErr
+		} else {
+			return nil, response.NewExceptionResponse(err)
+		}
+	}
+
+	if len(task.TaskID) == 0 {
+		return nil, response.NewValidationErrorResponse("task_id_commitment", "Task not validated")
+	}
+	records, err := models.GetValidationRecords(c.Request.Context(), config.GetDB(), task.TaskID, task.Creator)
+	if err != nil {
+		return nil, response.NewExceptionResponse(err)
+	}
+	if len(records) == 0 {
+		return nil, response.NewValidationErrorResponse("task_id_commitment", "Task not validated")
+	}
+
+	// the creator and nodes of the tasks validated together are allowed to see the evidence
+	allowed := task.Creator == address
+	for _, record := range records {
+		if record.SelectedNode == address {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return nil, response.NewValidationErrorResponse("signature", "Signer not allowed")
+	}
+
+	data := &TaskValidation{
+		TaskID:        task.TaskID,
+		Grouped:       records[0].Grouped,
+		Validator:     records[0].Validator,
+		Threshold:     records[0].Threshold,
+		ValidatedTime: records[0].CreatedAt,
+		Records:       make([]ValidationRecord, 0, len(records)),
+	}
+	for _, record := range records {
+		r := ValidationRecord{
+			TaskIDCommitment: record.TaskIDCommitment,
+			SelectedNode:     record.SelectedNode,
+			ExecutionOrder:   record.ExecutionOrder,
+			ExecutionTime:    record.ExecutionTime,
+			Status:           record.Status,
+			Score:            record.Score,
+			Comparisons:      record.Comparisons,
+			Verdict:          record.Verdict,
+		}
+		if r.Comparisons == nil {
+			r.Comparisons = []models.ValidationComparison{}
+		}
+		if record.QOSScore.Valid {
+			qosScore := uint64(record.QOSScore.Int64)
+			r.QOSScore = &qosScore
+		}
+		data.Records = append(data.Records, r)
+	}
+	return &TaskValidationResponse{Data: data}, nil
+}
@@ -0,0 +1,77 @@
+package inference_tasks
+
+import (
+	"crynux_relay/api/v1/response"
+	"crynux_relay/api/v1/validate"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"crynux_relay/service"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type IncreaseTaskFeeInput struct {
+	TaskIDCommitment string        `path:"task_id_commitment" json:"task_id_commitment" description:"Task id commitment" validate:"required"`
+	Amount           models.BigInt `json:"amount" description:"The amount added to the task fee, in unit wei" validate:"required"`
+}
+
+type IncreaseTaskFeeInputWithSignature struct {
+	IncreaseTaskFeeInput
+	Timestamp int64  `json:"timestamp" description:"Signature timestamp" validate:"required"`
+	Signature string `json:"signature" description:"Signature" validate:"required"`
+}
+
+func IncreaseTaskFee(c *gin.Context, in *IncreaseTaskFeeInputWithSignature) (*response.Response, error) {
+	match, address, err := validate.ValidateSignature(in.IncreaseTaskFeeInput, in.Timestamp, in.Signature)
+
+	if err != nil || !match {
+
+		if err != nil {
+			log.Debugln("error in sig validate: " + err.Error())
+		}
+
+		validationErr := response.NewValidationErrorResponse("signature", "Invalid signature")
+		return nil, validationErr
+	}
+
+	if in.Amount.Sign() <= 0 {
+		return nil, response.NewValidationErrorResponse("amount", "Amount must be positive")
+	}
+
+	task, err := models.GetTaskByIDCommitment(c.Request.Context(), config.GetDB(), in.TaskIDCommitment)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			validationErr := response.NewValidationErrorResponse("task_id_commitment", "Task not found")
+			return nil, validationErr
+		} else {
+			return nil, response.NewExceptionResponse(err)
+		}
+	}
+
+	if task.Creator != address {
+		return nil, response.NewValidationErrorResponse("signature", "Signer not allowed")
+	}
+
+	if task.Status != models.TaskQueued {
+		return nil, response.NewValidationErrorResponse("task_id_commitment", "Illegal task state")
+	}
+
+	balance, err := service.GetBalance(c.Request.Context(), config.GetDB(), address)
+	if err != nil {
+		return nil, response.NewExceptionResponse(err)
+	}
+	if balance.Cmp(&in.Amount.Int) < 0 {
+		return nil, response.NewValidationErrorResponse("balance", "Insufficient balance")
+	}
+
+	err = service.IncreaseTaskFee(c.Request.Context(), config.GetDB(), task, &in.Amount.Int)
+	if errors.Is(err, models.ErrTaskStatusChanged) {
+		return nil, response.NewValidationErrorResponse("task_id_commitment", "Illegal task state")
+	} else if err != nil {
+		return nil, response.NewExceptionResponse(err)
+	}
+	return &response.Response{}, nil
+}
@@ -0,0 +1,88 @@
+package inference_tasks
+
+import (
+	"crynux_relay/api/v1/response"
+	"crynux_relay/api/v1/validate"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// maxPinHours is the hard limit of pin hours regardless of the config, about 100 years,
+// so that the retention time never overflows
+const maxPinHours uint64 = 100 * 365 * 24
+
+type PinTaskInput struct {
+	TaskIDCommitment string `path:"task_id_commitment" json:"task_id_commitment" description:"Task id commitment" validate:"required"`
+	Hours            uint64 `json:"hours" description:"Hours from now during which the task data will be retained" validate:"required"`
+}
+
+type PinTaskInputWithSignature struct {
+	PinTaskInput
+	Timestamp int64  `json:"timestamp" description:"Signature timestamp" validate:"required"`
+	Signature string `json:"signature" description:"Signature" validate:"required"`
+}
+
+type PinTaskResponse struct {
+	response.Response
+	Data time.Time `json:"data"`
+}
+
+func PinTask(c *gin.Context, in *PinTaskInputWithSignature) (*PinTaskResponse, error) {
+	match, address, err := validate.ValidateSignature(in.PinTaskInput, in.Timestamp, in.Signature)
+
+	if err != nil || !match {
+
+		if err != nil {
+			log.Debugln("error in sig validate: " + err.Error())
+		}
+
+		validationErr := response.NewValidationErrorResponse("signature", "Invalid signature")
+		return nil, validationErr
+	}
+
+	appConfig := config.GetConfig()
+	limit := maxPinHours
+	if appConfig.Retention.MaxPinHours > 0 && appConfig.Retention.MaxPinHours < limit {
+		limit = appConfig.Retention.MaxPinHours
+	}
+	if in.Hours > limit {
+		return nil, response.NewValidationErrorResponse("hours", fmt.Sprintf("Task can be pinned for at most %d hours", limit))
+	}
+
+	task, err := models.GetTaskByIDCommitment(c.Request.Context(), config.GetDB(), in.TaskIDCommitment)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			validationErr := response.NewValidationErrorResponse("task_id_commitment", "Task not found")
+			return nil, validationErr
+		} else {
+			return nil, response.NewExceptionResponse(err)
+		}
+	}
+
+	if task.Creator != address {
+		return nil, response.NewValidationErrorResponse("signature", "Signer not allowed")
+	}
+
+	if task.ResultsPurgedAt.Valid {
+		return nil, response.NewValidationErrorResponse("task_id_commitment", "Task results expired")
+	}
+
+	retainUntil := time.Now().Add(time.Duration(in.Hours) * time.Hour)
+	if task.RetainUntil.Valid && task.RetainUntil.Time.After(retainUntil) {
+		retainUntil = task.RetainUntil.Time
+	}
+	if err := task.Update(c.Request.Context(), config.GetDB(), map[string]interface{}{
+		"retain_until": sql.NullTime{Time: retainUntil, Valid: true},
+	}); err != nil {
+		return nil, response.NewExceptionResponse(err)
+	}
+	return &PinTaskResponse{Data: retainUntil}, nil
+}
@@ -0,0 +1,74 @@
+package inference_tasks
+
+import (
+	"crynux_relay/api/v1/response"
+	"crynux_relay/api/v1/validate"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"crynux_relay/service"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type ReportProgressInput struct {
+	TaskIDCommitment string `path:"task_id_commitment" json:"task_id_commitment" description:"Task id commitment" validate:"required"`
+	Percent          uint64 `json:"percent" description:"Progress percent, from 0 to 100"`
+	Step             uint64 `json:"step" description:"Current step"`
+	Stage            string `json:"stage" description:"Current stage"`
+}
+
+type ReportProgressInputWithSignature struct {
+	ReportProgressInput
+	Timestamp int64  `json:"timestamp" description:"Signature timestamp" validate:"required"`
+	Signature string `json:"signature" description:"Signature" validate:"required"`
+}
+
+func ReportProgress(c *gin.Context, in *ReportProgressInputWithSignature) (*response.Response, error) {
+	match, address, err := validate.ValidateSignature(in.ReportProgressInput, in.Timestamp, in.Signature)
+
+	if err != nil || !match {
+
+		if err != nil {
+			log.Debugln("error in sig validate: " + err.Error())
+		}
+
+		validationErr := response.NewValidationErrorResponse("signature", "Invalid signature")
+		return nil, validationErr
+	}
+
+	if in.Percent > 100 {
+		return nil, response.NewValidationErrorResponse("percent", "Invalid percent")
+	}
+	if len(in.Stage) > 255 {
+		return nil, response.NewValidationErrorResponse("stage", "Stage too long")
+	}
+
+	task, err := models.GetTaskByIDCommitment(c.Request.Context(), config.GetDB(), in.TaskIDCommitment)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			validationErr := response.NewValidationErrorResponse("task_id_commitment", "Task not found")
+			return nil, validationErr
+		} else {
+			return nil, response.NewExceptionResponse(err)
+		}
+	}
+
+	if task.Status != models.TaskStarted && task.Status != models.TaskParametersUploaded {
+		return nil, response.NewValidationErrorResponse("task_id_commitment", "Illegal task state")
+	}
+
+	if task.SelectedNode != address {
+		return nil, response.NewValidationErrorResponse("signature", "Signer not allowed")
+	}
+
+	err = service.SetTaskProgress(c.Request.Context(), config.GetDB(), task, in.Percent, in.Step, in.Stage)
+	if errors.Is(err, models.ErrTaskStatusChanged) {
+		return nil, response.NewValidationErrorResponse("task_id_commitment", "Illegal task state")
+	} else if err != nil {
+		return nil, response.NewExceptionResponse(err)
+	}
+	return &response.Response{}, nil
+}
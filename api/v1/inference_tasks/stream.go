@@ -0,0 +1,259 @@
+package inference_tasks
+
+import (
+	"crynux_relay/api/v1/response"
+	"crynux_relay/api/v1/validate"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	maxStreamChunkSize  = 64 * 1024
+	maxStreamChunkCount = 10000
+)
+
+type UploadStreamChunkInput struct {
+	TaskIDCommitment string `path:"task_id_commitment" json:"task_id_commitment" description:"Task id commitment" validate:"required"`
+	Sequence         uint64 `json:"sequence" description:"Sequence of the chunk, starting from 0"`
+	Content          string `json:"content" description:"Chunk content" validate:"required"`
+}
+
+type UploadStreamChunkInputWithSignature struct {
+	UploadStreamChunkInput
+	Timestamp int64  `json:"timestamp" description:"Signature timestamp" validate:"required"`
+	Signature string `json:"signature" description:"Signature" validate:"required"`
+}
+
+func UploadStreamChunk(c *gin.Context, in *UploadStreamChunkInputWithSignature) (*response.Response, error) {
+	match, address, err := validate.ValidateSignature(in.UploadStreamChunkInput, in.Timestamp, in.Signature)
+
+	if err != nil || !match {
+
+		if err != nil {
+			log.Debugln("error in sig validate: " + err.Error())
+		}
+
+		validationErr := response.NewValidationErrorResponse("signature", "Invalid signature")
+		return nil, validationErr
+	}
+
+	task, err := models.GetTaskByIDCommitment(c.Request.Context(), config.GetDB(), in.TaskIDCommitment)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			validationErr := response.NewValidationErrorResponse("task_id_commitment", "Task not found")
+			return nil, validationErr
+		} else {
+			return nil, response.NewExceptionResponse(err)
+		}
+	}
+
+	if !models.IsStreamingTaskType(task.TaskType) {
+		return nil, response.NewValidationErrorResponse("task_id_commitment", "Task type not supported")
+	}
+
+	if task.SelectedNode != address {
+		return nil, response.NewValidationErrorResponse("signature", "Signer not allowed")
+	}
+
+	if task.Status == models.TaskQueued || task.Status == models.TaskWaiting || task.IsEnded() {
+		return nil, response.NewValidationErrorResponse("task_id_commitment", "Illegal task state")
+	}
+
+	if len(in.Content) > maxStreamChunkSize {
+		return nil, response.NewValidationErrorResponse("content", "Chunk too large")
+	}
+
+	nextSequence, err := models.GetNextTaskStreamSequence(c.Request.Context(), config.GetDB(), task.TaskIDCommitment)
+	if err != nil {
+		return nil, response.NewExceptionResponse(err)
+	}
+	// the chunk is uploaded before, maybe retried by the node
+	if in.Sequence < nextSequence {
+		return &response.Response{}, nil
+	}
+	if in.Sequence > nextSequence {
+		return nil, response.NewValidationErrorResponse("sequence", fmt.Sprintf("Sequence gap, expected %d", nextSequence))
+	}
+	if nextSequence >= maxStreamChunkCount {
+		return nil, response.NewValidationErrorResponse("sequence", "Too many chunks")
+	}
+
+	chunk := &models.TaskStreamChunk{
+		TaskIDCommitment: task.TaskIDCommitment,
+		Sequence:         in.Sequence,
+		Content:          in.Content,
+	}
+	if err := chunk.Create(c.Request.Context(), config.GetDB()); err != nil {
+		return nil, response.NewExceptionResponse(err)
+	}
+	return &response.Response{}, nil
+}
+
+type GetStreamInput struct {
+	TaskIDCommitment string `path:"task_id_commitment" json:"task_id_commitment" description:"Task id commitment" validate:"required"`
+}
+
+type GetStreamInputWithSignature struct {
+	GetStreamInput
+	Timestamp int64  `query:"timestamp" description:"Signature timestamp" validate:"required"`
+	Signature string `query:"signature" description:"Signature" validate:"required"`
+}
+
+type StreamChunk struct {
+	Sequence uint64 `json:"sequence"`
+	Content  string `json:"content"`
+}
+
+type StreamReset struct {
+	Attempts uint64 `json:"attempts"`
+}
+
+type StreamEnd struct {
+	Status      models.TaskStatus      `json:"status"`
+	AbortReason models.TaskAbortReason `json:"abort_reason"`
+	// whether the results uploaded by the node match the submitted score
+	Validated bool `json:"validated"`
+}
+
+// GetStream relays the chunks uploaded by the node to the creator as server-sent events.
+// Chunks are not validated, the "end" event carries the validation outcome of the final results.
+// Clients can resume the stream by the Last-Event-ID header.
+// A "reset" event is sent when the task is requeued, the chunks received before should be dropped.
+func GetStream(c *gin.Context, in *GetStreamInputWithSignature) error {
+	match, address, err := validate.ValidateSignature(in.GetStreamInput, in.Timestamp, in.Signature)
+
+	if err != nil || !match {
+
+		if err != nil {
+			log.Debugln(err)
+		}
+
+		return response.NewValidationErrorResponse("signature", "Invalid signature")
+	}
+
+	task, err := models.GetTaskByIDCommitment(c.Request.Context(), config.GetDB(), in.TaskIDCommitment)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			validationErr := response.NewValidationErrorResponse("task_id_commitment", "Task not found")
+			return validationErr
+		} else {
+			return response.NewExceptionResponse(err)
+		}
+	}
+
+	if task.Creator != address {
+		return response.NewValidationErrorResponse("signature", "Signer not allowed")
+	}
+
+	if !models.IsStreamingTaskType(task.TaskType) {
+		return response.NewValidationErrorResponse("task_id_commitment", "Task type not supported")
+	}
+
+	var nextSequence uint64 = 0
+	if lastEventID := c.GetHeader("Last-Event-ID"); len(lastEventID) > 0 {
+		lastSequence, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			return response.NewValidationErrorResponse("Last-Event-ID", "Invalid last event id")
+		}
+		nextSequence = lastSequence + 1
+	}
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.Header().Set("X-Accel-Buffering", "no")
+	c.Writer.WriteHeader(http.StatusOK)
+	c.Writer.Flush()
+
+	ctx := c.Request.Context()
+	limit := 100
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	lastWrite := time.Now()
+
+	attempts := task.Attempts
+	for {
+		// check the status before reading chunks, so that no chunk is missed when the task ends
+		if err := task.SyncStatus(ctx, config.GetDB()); err != nil {
+			log.Errorf("GetStream: sync task %s status error: %v", task.TaskIDCommitment, err)
+			return nil
+		}
+		ended := task.IsEnded()
+
+		// the task is requeued and run by another node, the stream starts over
+		if task.Attempts != attempts {
+			c.Render(-1, sse.Event{
+				Event: "reset",
+				Data:  StreamReset{Attempts: task.Attempts},
+			})
+			c.Writer.Flush()
+			lastWrite = time.Now()
+			attempts = task.Attempts
+			nextSequence = 0
+		}
+
+		chunks, err := models.GetTaskStreamChunks(ctx, config.GetDB(), task.TaskIDCommitment, nextSequence, limit)
+		if err != nil {
+			log.Errorf("GetStream: get task %s stream chunks error: %v", task.TaskIDCommitment, err)
+			return nil
+		}
+		for _, chunk := range chunks {
+			c.Render(-1, sse.Event{
+				Id:    strconv.FormatUint(chunk.Sequence, 10),
+				Event: "chunk",
+				Data:  StreamChunk{Sequence: chunk.Sequence, Content: chunk.Content},
+			})
+			nextSequence = chunk.Sequence + 1
+		}
+		if len(chunks) > 0 {
+			c.Writer.Flush()
+			lastWrite = time.Now()
+		}
+		if len(chunks) == limit {
+			continue
+		}
+
+		if ended {
+			latestTask, err := models.GetTaskByIDCommitment(ctx, config.GetDB(), task.TaskIDCommitment)
+			if err != nil {
+				log.Errorf("GetStream: get task %s error: %v", task.TaskIDCommitment, err)
+				return nil
+			}
+			c.Render(-1, sse.Event{
+				Event: "end",
+				Data: StreamEnd{
+					Status:      latestTask.Status,
+					AbortReason: latestTask.AbortReason,
+					Validated:   latestTask.Status == models.TaskEndSuccess || latestTask.Status == models.TaskEndGroupSuccess,
+				},
+			})
+			c.Writer.Flush()
+			return nil
+		}
+
+		// keep the connection alive through proxies
+		if time.Since(lastWrite) > 15*time.Second {
+			if _, err := c.Writer.WriteString(": keepalive\n\n"); err != nil {
+				return nil
+			}
+			c.Writer.Flush()
+			lastWrite = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
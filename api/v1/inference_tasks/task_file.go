@@ -0,0 +1,60 @@
+package inference_tasks
+
+import (
+	"crynux_relay/config"
+	"crynux_relay/storage"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveTaskFile sends the stored file of the key to the client, or redirects the client
+// to the presigned url of the file when it is enabled and supported by the storage backend.
+// It returns storage.ErrNotFound if the file does not exist.
+func serveTaskFile(c *gin.Context, key, filename, contentType string) error {
+	s := storage.GetStorage()
+	if _, err := s.Stat(c.Request.Context(), key); err != nil {
+		return err
+	}
+
+	appConfig := config.GetConfig()
+	if appConfig.Storage.PresignedRedirect {
+		expires := time.Duration(appConfig.Storage.PresignedExpires) * time.Second
+		if expires == 0 {
+			expires = 10 * time.Minute
+		}
+		url, err := s.PresignedURL(c.Request.Context(), key, expires, filename)
+		if err == nil {
+			c.Redirect(http.StatusFound, url)
+			return nil
+		}
+		if !errors.Is(err, storage.ErrPresignNotSupported) {
+			return err
+		}
+	}
+
+	reader, info, err := s.Get(c.Request.Context(), key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, info.Size, contentType, reader, map[string]string{
+		"Content-Description":       "File Transfer",
+		"Content-Transfer-Encoding": "binary",
+		"Content-Disposition":       "attachment; filename=" + filename,
+	})
+	return nil
+}
+
+func saveUploadedTaskFile(c *gin.Context, file *multipart.FileHeader, key string) error {
+	f, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return storage.GetStorage().Put(c.Request.Context(), key, f, file.Size)
+}
@@ -6,6 +6,13 @@ import (
 	"time"
 )
 
+type TaskProgress struct {
+	Percent     uint64    `json:"percent"`
+	Step        uint64    `json:"step"`
+	Stage       string    `json:"stage"`
+	UpdatedTime time.Time `json:"updated_time"`
+}
+
 type InferenceTask struct {
 	Sequence           uint64                 `json:"sequence"`
 	TaskArgs           string                 `json:"task_args"`
@@ -20,6 +27,7 @@ type InferenceTask struct {
 	MinVRAM            uint64                 `json:"min_vram"`
 	RequiredGPU        string                 `json:"required_gpu"`
 	RequiredGPUVRAM    uint64                 `json:"required_gpu_vram"`
+	NodeRequirement    string                 `json:"node_requirement"`
 	TaskFee            models.BigInt          `json:"task_fee"`
 	TaskSize           uint64                 `json:"task_size"`
 	ModelIDs           []string               `json:"model_ids"`
@@ -28,11 +36,20 @@ type InferenceTask struct {
 	Score              string                 `json:"score"`
 	QOSScore           uint64                 `json:"qos_score"`
 	SelectedNode       string                 `json:"selected_node"`
+	MaxAttempts        uint64                 `json:"max_attempts"`
+	Attempts           uint64                 `json:"attempts"`
+	Validator          string                 `json:"validator"`
+	ValidatorThreshold float64                `json:"validator_threshold"`
+	Scheduled          bool                   `json:"scheduled"`
+	NotBefore          *time.Time             `json:"not_before,omitempty"`
 	CreateTime         *time.Time             `json:"create_time,omitempty"`
 	StartTime          *time.Time             `json:"start_time,omitempty"`
 	ScoreReadyTime     *time.Time             `json:"score_ready_time,omitempty"`
 	ValidatedTime      *time.Time             `json:"validated_time,omitempty"`
 	ResultUploadedTime *time.Time             `json:"result_uploaded_time,omitempty"`
+	RetainUntil        *time.Time             `json:"retain_until,omitempty"`
+	ResultsPurgedAt    *time.Time             `json:"results_purged_at,omitempty"`
+	Progress           *TaskProgress          `json:"progress,omitempty"`
 }
 
 type TaskResponse struct {
@@ -44,3 +61,73 @@ type TasksResponse struct {
 	response.Response
 	Data []InferenceTask `json:"data"`
 }
+
+func newTaskResponse(task *models.InferenceTask) *InferenceTask {
+	qosScore := uint64(0)
+	if task.QOSScore.Valid {
+		qosScore = uint64(task.QOSScore.Int64)
+	}
+
+	t := &InferenceTask{
+		Sequence:           uint64(task.ID),
+		TaskArgs:           task.TaskArgs,
+		TaskIDCommitment:   task.TaskIDCommitment,
+		Creator:            task.Creator,
+		SamplingSeed:       task.SamplingSeed,
+		Nonce:              task.Nonce,
+		Status:             task.Status,
+		TaskType:           task.TaskType,
+		TaskVersion:        task.TaskVersion,
+		Timeout:            task.Timeout,
+		MinVRAM:            task.MinVRAM,
+		RequiredGPU:        task.RequiredGPU,
+		RequiredGPUVRAM:    task.RequiredGPUVRAM,
+		NodeRequirement:    task.NodeRequirement,
+		TaskFee:            task.TaskFee,
+		TaskSize:           task.TaskSize,
+		ModelIDs:           task.ModelIDs,
+		AbortReason:        task.AbortReason,
+		TaskError:          task.TaskError,
+		Score:              task.Score,
+		QOSScore:           qosScore,
+		SelectedNode:       task.SelectedNode,
+		MaxAttempts:        task.MaxAttempts,
+		Attempts:           task.Attempts,
+		Validator:          task.Validator,
+		ValidatorThreshold: task.ValidatorThreshold,
+		Scheduled:          task.IsScheduled(),
+	}
+	if task.NotBefore.Valid {
+		t.NotBefore = &task.NotBefore.Time
+	}
+	if task.CreateTime.Valid {
+		t.CreateTime = &task.CreateTime.Time
+	}
+	if task.StartTime.Valid {
+		t.StartTime = &task.StartTime.Time
+	}
+	if task.ScoreReadyTime.Valid {
+		t.ScoreReadyTime = &task.ScoreReadyTime.Time
+	}
+	if task.ValidatedTime.Valid {
+		t.ValidatedTime = &task.ValidatedTime.Time
+	}
+	if task.ResultUploadedTime.Valid {
+		t.ResultUploadedTime = &task.ResultUploadedTime.Time
+	}
+	if task.RetainUntil.Valid {
+		t.RetainUntil = &task.RetainUntil.Time
+	}
+	if task.ResultsPurgedAt.Valid {
+		t.ResultsPurgedAt = &task.ResultsPurgedAt.Time
+	}
+	if task.ProgressUpdatedTime.Valid {
+		t.Progress = &TaskProgress{
+			Percent:     task.ProgressPercent,
+			Step:        task.ProgressStep,
+			Stage:       task.ProgressStage,
+			UpdatedTime: task.ProgressUpdatedTime.Time,
+		}
+	}
+	return t
+}
@@ -3,14 +3,12 @@ package inference_tasks
 import (
 	"crynux_relay/api/v1/response"
 	"crynux_relay/api/v1/validate"
-	"crynux_relay/blockchain"
 	"crynux_relay/config"
 	"crynux_relay/models"
 	"crynux_relay/service"
+	"crynux_relay/storage"
 	"errors"
 	"mime/multipart"
-	"os"
-	"path/filepath"
 	"strconv"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -60,6 +58,16 @@ func UploadResult(c *gin.Context, in *ResultInputWithSignature) (*response.Respo
 		validationErr := response.NewValidationErrorResponse("task_id_commitment", "Task not validated")
 		return nil, validationErr
 	}
+	spec, ok := models.GetTaskTypeSpec(task.TaskType)
+	if !ok {
+		return nil, response.NewValidationErrorResponse("task_id_commitment", "Task type not supported")
+	}
+	// the score is hashed by the algorithm of the task type, whichever validator compares the scores
+	v, err := models.GetTaskScoreValidator(task.TaskType)
+	if err != nil {
+		return nil, response.NewExceptionResponse(err)
+	}
+
 	// Check whether the images are correct
 	var uploadedScoreBytes []byte
 
@@ -80,13 +88,7 @@ func UploadResult(c *gin.Context, in *ResultInputWithSignature) (*response.Respo
 			return nil, response.NewExceptionResponse(err)
 		}
 
-		var hash []byte
-		if task.TaskType == models.TaskTypeSD || task.TaskType == models.TaskTypeSDFTLora {
-			hash, err = blockchain.GetPHashForImage(fileObj)
-		} else {
-			hash, err = blockchain.GetHashForGPTResponse(fileObj)
-		}
-
+		hash, err := v.Hash(fileObj)
 		if err != nil {
 			return nil, response.NewExceptionResponse(err)
 		}
@@ -109,29 +111,15 @@ func UploadResult(c *gin.Context, in *ResultInputWithSignature) (*response.Respo
 		return nil, validationErr
 	}
 
-	appConfig := config.GetConfig()
-
-	taskDir := filepath.Join(appConfig.DataDir.InferenceTasks, task.TaskIDCommitment, "results")
-	if err = os.MkdirAll(taskDir, 0o711); err != nil {
-		return nil, response.NewExceptionResponse(err)
-	}
-
-	var fileExt string
-	if task.TaskType == models.TaskTypeSD || task.TaskType == models.TaskTypeSDFTLora {
-		fileExt = ".png"
-	} else {
-		fileExt = ".json"
-	}
-
 	for i, file := range files {
-		filename := filepath.Join(taskDir, strconv.Itoa(i)+fileExt)
-		if err := c.SaveUploadedFile(file, filename); err != nil {
+		key := storage.TaskResultKey(task.TaskIDCommitment, strconv.Itoa(i)+spec.ResultFileExt)
+		if err := saveUploadedTaskFile(c, file, key); err != nil {
 			return nil, response.NewExceptionResponse(err)
 		}
 	}
 
 	// store checkpoint of finetune type task
-	if task.TaskType == models.TaskTypeSDFTLora {
+	if models.IsCheckpointTaskType(task.TaskType) {
 		var checkpoint *multipart.FileHeader
 		if checkpoints, ok := form.File["checkpoint"]; !ok {
 			return nil, response.NewValidationErrorResponse("checkpoint", "Checkpoint not uploaded")
@@ -141,8 +129,8 @@ func UploadResult(c *gin.Context, in *ResultInputWithSignature) (*response.Respo
 			}
 			checkpoint = checkpoints[0]
 		}
-		checkpointFilename := filepath.Join(taskDir, "checkpoint.zip")
-		if err := c.SaveUploadedFile(checkpoint, checkpointFilename); err != nil {
+		key := storage.TaskResultKey(task.TaskIDCommitment, "checkpoint.zip")
+		if err := saveUploadedTaskFile(c, checkpoint, key); err != nil {
 			return nil, response.NewExceptionResponse(err)
 		}
 	}
@@ -26,7 +26,8 @@ type ValidateTaskInputWithSignature struct {
 }
 
 func ValidateTask(c *gin.Context, in *ValidateTaskInputWithSignature) (*response.Response, error) {
-	if len(in.TaskIDCommitments) != 1 && len(in.TaskIDCommitments) != 3 {
+	groupSize := int(config.GetConfig().Validation.GroupSize)
+	if len(in.TaskIDCommitments) != 1 && len(in.TaskIDCommitments) != groupSize {
 		return nil, response.NewValidationErrorResponse("task_id_commitments", "TaskIDCommitments length incorrect")
 	}
 
@@ -54,13 +55,17 @@ func ValidateTask(c *gin.Context, in *ValidateTaskInputWithSignature) (*response
 		if task.Creator != address {
 			return nil, response.NewValidationErrorResponse("signature", "Signer not allowed")
 		}
+		// tasks verifying a dispute are validated by the relay
+		if len(task.DisputeTaskIDCommitment) > 0 {
+			return nil, response.NewValidationErrorResponse("task_id_commitment", "Task validated by relay")
+		}
 		tasks = append(tasks, task)
 	}
 
 	for range 3 {
 		if len(tasks) == 1 {
 			err = service.ValidateSingleTask(c.Request.Context(), tasks[0], in.TaskID, in.VrfProof, in.PublicKey)
-		} else if len(tasks) == 3 {
+		} else {
 			err = service.ValidateTaskGroup(c.Request.Context(), tasks, in.TaskID, in.VrfProof, in.PublicKey)
 		}
 		if err == nil {
@@ -18,11 +18,12 @@ import (
 )
 
 type NodeJoinInput struct {
-	Address  string        `json:"address" path:"address" description:"address" validate:"required"`
-	GPUName  string        `json:"gpu_name" description:"gpu_name" validate:"required"`
-	GPUVram  uint64        `json:"gpu_vram" description:"gpu_vram" validate:"required"`
-	Version  string        `json:"version" description:"version" validate:"required"`
-	ModelIDs []string      `json:"model_ids" description:"node local model ids" validate:"required"`
+	Address  string                    `json:"address" path:"address" description:"address" validate:"required"`
+	GPUName  string                    `json:"gpu_name" description:"gpu_name" validate:"required"`
+	GPUVram  uint64                    `json:"gpu_vram" description:"gpu_vram" validate:"required"`
+	Version  string                    `json:"version" description:"version" validate:"required"`
+	ModelIDs []string                  `json:"model_ids" description:"node local model ids" validate:"required"`
+	Hardware *models.NodeHardwareInput `json:"hardware,omitempty" description:"hardware profile of the node"`
 }
 
 type NodeJoinInputWithSignature struct {
@@ -62,6 +63,21 @@ func NodeJoin(c *gin.Context, in *NodeJoinInputWithSignature) (*response.Respons
 		}
 	}
 
+	var hardware *models.NodeHardware
+	if in.Hardware != nil {
+		hardware, err = models.NewNodeHardware(in.Address, in.Hardware)
+		if err != nil {
+			var inputErr *models.NodeHardwareInputError
+			if errors.As(err, &inputErr) {
+				return nil, response.NewValidationErrorResponse("hardware."+inputErr.Field, inputErr.Message)
+			}
+			return nil, response.NewExceptionResponse(err)
+		}
+		if hardware.VRAM == 0 {
+			hardware.VRAM = in.GPUVram
+		}
+	}
+
 	node, err := models.GetNodeByAddress(c.Request.Context(), config.GetDB(), in.Address)
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		node = &models.Node{
@@ -100,7 +116,7 @@ func NodeJoin(c *gin.Context, in *NodeJoinInputWithSignature) (*response.Respons
 
 	node.StakeAmount = models.BigInt{Int: *stakeAmount}
 
-	if err := service.SetNodeStatusJoin(c.Request.Context(), config.GetDB(), node, in.ModelIDs); err != nil {
+	if err := service.SetNodeStatusJoin(c.Request.Context(), config.GetDB(), node, in.ModelIDs, hardware); err != nil {
 		return nil, response.NewExceptionResponse(err)
 	}
 	return &response.Response{}, nil
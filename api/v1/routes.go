@@ -8,6 +8,7 @@ import (
 	"crynux_relay/api/v1/network"
 	"crynux_relay/api/v1/nodes"
 	"crynux_relay/api/v1/response"
+	"crynux_relay/api/v1/schemas"
 	"crynux_relay/api/v1/staking"
 	"crynux_relay/api/v1/stats"
 	"crynux_relay/api/v1/time"
@@ -38,6 +39,27 @@ func InitRoutes(r *fizz.Fizz) {
 		fizz.Summary("Get a task by task id"),
 		fizz.Response("400", "validation errors", response.ValidationErrorResponse{}, nil, nil),
 	}, tonic.Handler(inference_tasks.GetTaskById, 200))
+	tasksGroup.GET("/:task_id_commitment/attempts", []fizz.OperationOption{
+		fizz.Summary("Get the attempts of the task"),
+		fizz.Response("400", "validation errors", response.ValidationErrorResponse{}, nil, nil),
+	}, tonic.Handler(inference_tasks.GetTaskAttempts, 200))
+	tasksGroup.GET("/:task_id_commitment/validation", []fizz.OperationOption{
+		fizz.Summary("Get the validation evidence of the task"),
+		fizz.Response("400", "validation errors", response.ValidationErrorResponse{}, nil, nil),
+	}, tonic.Handler(inference_tasks.GetTaskValidation, 200))
+	tasksGroup.GET("/:task_id_commitment/history", []fizz.OperationOption{
+		fizz.Summary("Get the status history of the task"),
+		fizz.Response("400", "validation errors", response.ValidationErrorResponse{}, nil, nil),
+	}, tonic.Handler(inference_tasks.GetTaskHistory, 200))
+	tasksGroup.POST("/:task_id_commitment/dispute", []fizz.OperationOption{
+		fizz.Summary("Dispute the result of a task"),
+		fizz.Response("400", "validation errors", response.ValidationErrorResponse{}, nil, nil),
+		fizz.Response("500", "exception", response.ExceptionResponse{}, nil, nil),
+	}, tonic.Handler(inference_tasks.DisputeTask, 200))
+	tasksGroup.GET("/:task_id_commitment/dispute", []fizz.OperationOption{
+		fizz.Summary("Get the dispute of the task"),
+		fizz.Response("400", "validation errors", response.ValidationErrorResponse{}, nil, nil),
+	}, tonic.Handler(inference_tasks.GetTaskDispute, 200))
 
 	tasksGroup.POST("/:task_id_commitment/results", []fizz.OperationOption{
 		fizz.Summary("Upload task result"),
@@ -54,6 +76,15 @@ func InitRoutes(r *fizz.Fizz) {
 		fizz.Response("400", "validation errors", response.ValidationErrorResponse{}, nil, nil),
 	}, tonic.Handler(inference_tasks.GetResultCheckpoint, 200))
 
+	tasksGroup.POST("/:task_id_commitment/stream", []fizz.OperationOption{
+		fizz.Summary("Upload a chunk of the streaming task result"),
+		fizz.Response("400", "validation errors", response.ValidationErrorResponse{}, nil, nil),
+	}, tonic.Handler(inference_tasks.UploadStreamChunk, 200))
+	tasksGroup.GET("/:task_id_commitment/stream", []fizz.OperationOption{
+		fizz.Summary("Get the streaming task result as server-sent events"),
+		fizz.Response("400", "validation errors", response.ValidationErrorResponse{}, nil, nil),
+	}, tonic.Handler(inference_tasks.GetStream, 200))
+
 	tasksGroup.GET("/:task_id_commitment/checkpoint", []fizz.OperationOption{
 		fizz.Summary("Get the input checkpoint of the task"),
 		fizz.Response("400", "validation errors", response.ValidationErrorResponse{}, nil, nil),
@@ -63,6 +94,10 @@ func InitRoutes(r *fizz.Fizz) {
 		fizz.Summary("Submit task score"),
 		fizz.Response("400", "validation errors", response.ValidationErrorResponse{}, nil, nil),
 	}, tonic.Handler(inference_tasks.SubmitScore, 200))
+	tasksGroup.POST("/batch", []fizz.OperationOption{
+		fizz.Summary("Create a batch of tasks"),
+		fizz.Response("400", "validation errors", response.ValidationErrorResponse{}, nil, nil),
+	}, tonic.Handler(inference_tasks.CreateTasks, 200))
 	tasksGroup.POST("/validate", []fizz.OperationOption{
 		fizz.Summary("Validate single task or task group"),
 		fizz.Response("400", "validation errors", response.ValidationErrorResponse{}, nil, nil),
@@ -71,11 +106,34 @@ func InitRoutes(r *fizz.Fizz) {
 		fizz.Summary("Abort task, report task abort resaon"),
 		fizz.Response("400", "validation errors", response.ValidationErrorResponse{}, nil, nil),
 	}, tonic.Handler(inference_tasks.AbortTask, 200))
+	tasksGroup.POST("/:task_id_commitment/cancel", []fizz.OperationOption{
+		fizz.Summary("Cancel a queued task and refund the task fee"),
+		fizz.Response("400", "validation errors", response.ValidationErrorResponse{}, nil, nil),
+	}, tonic.Handler(inference_tasks.CancelTask, 200))
+	tasksGroup.POST("/:task_id_commitment/fee", []fizz.OperationOption{
+		fizz.Summary("Increase the fee of a queued task"),
+		fizz.Response("400", "validation errors", response.ValidationErrorResponse{}, nil, nil),
+	}, tonic.Handler(inference_tasks.IncreaseTaskFee, 200))
+	tasksGroup.POST("/:task_id_commitment/pin", []fizz.OperationOption{
+		fizz.Summary("Extend the retention of the task data"),
+		fizz.Response("400", "validation errors", response.ValidationErrorResponse{}, nil, nil),
+	}, tonic.Handler(inference_tasks.PinTask, 200))
+	tasksGroup.POST("/:task_id_commitment/progress", []fizz.OperationOption{
+		fizz.Summary("Report task progress"),
+		fizz.Response("400", "validation errors", response.ValidationErrorResponse{}, nil, nil),
+	}, tonic.Handler(inference_tasks.ReportProgress, 200))
 	tasksGroup.POST("/:task_id_commitment/task_error", []fizz.OperationOption{
 		fizz.Summary("Report task error"),
 		fizz.Response("400", "validation errors", response.ValidationErrorResponse{}, nil, nil),
 	}, tonic.Handler(inference_tasks.ReportTaskError, 200))
 
+	creatorGroup := v1g.Group("creators", "creators", "Task creator APIs")
+	creatorGroup.GET("/:address/tasks", []fizz.OperationOption{
+		fizz.Summary("Get the tasks of the creator"),
+		fizz.Response("400", "validation errors", response.ValidationErrorResponse{}, nil, nil),
+		fizz.Response("500", "exception", response.ExceptionResponse{}, nil, nil),
+	}, tonic.Handler(inference_tasks.GetCreatorTasks, 200))
+
 	nodeGroup := v1g.Group("node", "node", "Node APIs")
 	nodeGroup.GET("/:address", []fizz.OperationOption{
 		fizz.Summary("Get node info"),
@@ -126,6 +184,20 @@ func InitRoutes(r *fizz.Fizz) {
 		fizz.Response("400", "validation errors", response.ValidationErrorResponse{}, nil, nil),
 	}, tonic.Handler(staking.GetStaking, 200))
 
+	schemasGroup := v1g.Group("schemas", "schemas", "task args schema related APIs")
+	schemasGroup.GET("/:task_type/:version", []fizz.OperationOption{
+		fizz.Summary("Get the task args schema enforced for the task version"),
+		fizz.Response("400", "validation errors", response.ValidationErrorResponse{}, nil, nil),
+	}, tonic.Handler(schemas.GetSchema, 200))
+	schemasGroup.POST("/:task_type/:version", []fizz.OperationOption{
+		fizz.Summary("Upload a task args schema"),
+		fizz.Response("400", "validation errors", response.ValidationErrorResponse{}, nil, nil),
+	}, tonic.Handler(schemas.UploadSchema, 200))
+	schemasGroup.POST("/:task_type/:version/activate", []fizz.OperationOption{
+		fizz.Summary("Activate or deactivate a task args schema"),
+		fizz.Response("400", "validation errors", response.ValidationErrorResponse{}, nil, nil),
+	}, tonic.Handler(schemas.ActivateSchema, 200))
+
 	eventsGroup := v1g.Group("events", "events", "events related APIs")
 	eventsGroup.GET("", []fizz.OperationOption{
 		fizz.Summary("Get events"),
@@ -0,0 +1,54 @@
+package schemas
+
+import (
+	"crynux_relay/api/v1/response"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"encoding/json"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GetSchemaInput struct {
+	TaskType models.TaskType `path:"task_type" json:"task_type" description:"Task type"`
+	Version  string          `path:"version" json:"version" description:"Task version" validate:"required"`
+}
+
+type Schema struct {
+	TaskType models.TaskType `json:"task_type"`
+	// version of the schema, the highest one not higher than the requested task version
+	Version string          `json:"version"`
+	Source  string          `json:"source"`
+	Schema  json.RawMessage `json:"schema"`
+}
+
+type GetSchemaResponse struct {
+	response.Response
+	Data *Schema `json:"data"`
+}
+
+func GetSchema(c *gin.Context, in *GetSchemaInput) (*GetSchemaResponse, error) {
+	if _, ok := models.GetTaskTypeSpec(in.TaskType); !ok {
+		return nil, response.NewValidationErrorResponse("task_type", "Unsupported task type")
+	}
+	if _, err := models.ParseVersion(in.Version); err != nil {
+		return nil, response.NewValidationErrorResponse("version", "Invalid version")
+	}
+
+	resolved, err := models.ResolveTaskSchema(c.Request.Context(), config.GetDB(), in.TaskType, in.Version)
+	if errors.Is(err, models.ErrTaskSchemaNotFound) {
+		return nil, response.NewValidationErrorResponse("version", "Schema not found")
+	} else if err != nil {
+		return nil, response.NewExceptionResponse(err)
+	}
+
+	return &GetSchemaResponse{
+		Data: &Schema{
+			TaskType: resolved.TaskType,
+			Version:  resolved.Version,
+			Source:   resolved.Source,
+			Schema:   json.RawMessage(resolved.Content),
+		},
+	}, nil
+}
@@ -0,0 +1,117 @@
+package schemas
+
+import (
+	"crynux_relay/api/v1/response"
+	"crynux_relay/api/v1/validate"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"encoding/json"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type UploadSchemaInput struct {
+	TaskType models.TaskType `path:"task_type" json:"task_type" description:"Task type"`
+	Version  string          `path:"version" json:"version" description:"The lowest task version the schema applies to" validate:"required"`
+	Schema   string          `json:"schema" description:"Json schema of the task args" validate:"required"`
+	Active   bool            `json:"active" description:"Whether the schema is enforced after uploaded"`
+}
+
+type UploadSchemaInputWithSignature struct {
+	UploadSchemaInput
+	Timestamp int64  `json:"timestamp" description:"Signature timestamp" validate:"required"`
+	Signature string `json:"signature" description:"Signature" validate:"required"`
+}
+
+// UploadSchema creates or replaces the schema of the task type and version, only the relay account is allowed
+func UploadSchema(c *gin.Context, in *UploadSchemaInputWithSignature) (*response.Response, error) {
+	match, address, err := validate.ValidateSignature(in.UploadSchemaInput, in.Timestamp, in.Signature)
+	if err != nil || !match {
+
+		if err != nil {
+			log.Debugln("error in sig validate: " + err.Error())
+		}
+
+		validationErr := response.NewValidationErrorResponse("signature", "Invalid signature")
+		return nil, validationErr
+	}
+
+	appConfig := config.GetConfig()
+	if address != appConfig.Blockchain.Account.Address {
+		return nil, response.NewValidationErrorResponse("signature", "Signer not allowed")
+	}
+
+	if _, ok := models.GetTaskTypeSpec(in.TaskType); !ok {
+		return nil, response.NewValidationErrorResponse("task_type", "Unsupported task type")
+	}
+	if _, err := models.ParseVersion(in.Version); err != nil {
+		return nil, response.NewValidationErrorResponse("version", "Invalid version")
+	}
+	if !json.Valid([]byte(in.Schema)) {
+		return nil, response.NewValidationErrorResponse("schema", "Invalid json")
+	}
+	if _, err := models.CompileTaskSchema(in.Schema); err != nil {
+		return nil, response.NewValidationErrorResponse("schema", err.Error())
+	}
+
+	schema, err := models.GetTaskSchema(c.Request.Context(), config.GetDB(), in.TaskType, in.Version)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		schema = &models.TaskSchema{TaskType: in.TaskType, Version: in.Version}
+	} else if err != nil {
+		return nil, response.NewExceptionResponse(err)
+	}
+	schema.Content = in.Schema
+	schema.Active = in.Active
+	if err := schema.Save(c.Request.Context(), config.GetDB()); err != nil {
+		return nil, response.NewExceptionResponse(err)
+	}
+	models.InvalidateTaskSchemaCache()
+	return &response.Response{}, nil
+}
+
+type ActivateSchemaInput struct {
+	TaskType models.TaskType `path:"task_type" json:"task_type" description:"Task type"`
+	Version  string          `path:"version" json:"version" description:"Schema version" validate:"required"`
+	Active   bool            `json:"active" description:"Whether the schema is enforced"`
+}
+
+type ActivateSchemaInputWithSignature struct {
+	ActivateSchemaInput
+	Timestamp int64  `json:"timestamp" description:"Signature timestamp" validate:"required"`
+	Signature string `json:"signature" description:"Signature" validate:"required"`
+}
+
+// ActivateSchema enables or disables an uploaded schema, only the relay account is allowed
+func ActivateSchema(c *gin.Context, in *ActivateSchemaInputWithSignature) (*response.Response, error) {
+	match, address, err := validate.ValidateSignature(in.ActivateSchemaInput, in.Timestamp, in.Signature)
+	if err != nil || !match {
+
+		if err != nil {
+			log.Debugln("error in sig validate: " + err.Error())
+		}
+
+		validationErr := response.NewValidationErrorResponse("signature", "Invalid signature")
+		return nil, validationErr
+	}
+
+	appConfig := config.GetConfig()
+	if address != appConfig.Blockchain.Account.Address {
+		return nil, response.NewValidationErrorResponse("signature", "Signer not allowed")
+	}
+
+	schema, err := models.GetTaskSchema(c.Request.Context(), config.GetDB(), in.TaskType, in.Version)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, response.NewValidationErrorResponse("version", "Schema not found")
+	} else if err != nil {
+		return nil, response.NewExceptionResponse(err)
+	}
+	schema.Active = in.Active
+	if err := schema.Save(c.Request.Context(), config.GetDB()); err != nil {
+		return nil, response.NewExceptionResponse(err)
+	}
+	models.InvalidateTaskSchemaCache()
+	return &response.Response{}, nil
+}
@@ -28,6 +28,7 @@ func GetNodeEventLogs(ctx *gin.Context) error {
 			return config.GetDB().WithContext(dbCtx).Model(&models.InferenceTask{}).
 				Where("created_at >= ?", start).
 				Where("start_time IS NOT NULL").
+				Where("canary = ?", false).
 				Order("id").Offset(offset).Limit(limit).Find(&tasks).Error
 		}()
 		if err != nil {
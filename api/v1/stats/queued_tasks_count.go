@@ -20,7 +20,7 @@ func GetQueuedTasksCount(c *gin.Context) (*QueuedTasksCountResponse, error) {
 	dbCtx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
-	if err := config.GetDB().WithContext(dbCtx).Model(&models.InferenceTask{}).Where("status = ?", models.TaskQueued).Count(&cnt).Error; err != nil {
+	if err := config.GetDB().WithContext(dbCtx).Model(&models.InferenceTask{}).Where("status = ?", models.TaskQueued).Where("canary = ?", false).Count(&cnt).Error; err != nil {
 		return nil, err
 	}
 	return &QueuedTasksCountResponse{
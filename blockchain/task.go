@@ -4,14 +4,9 @@ import (
 	"context"
 	"crynux_relay/blockchain/bindings"
 	"crynux_relay/config"
-	"crypto/sha256"
-	"encoding/binary"
-	"image/png"
-	"io"
 	"math/big"
 	"time"
 
-	"github.com/corona10/goimagehash"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 )
@@ -107,27 +102,3 @@ func ReportTaskResultUploaded(ctx context.Context, taskIDCommitment [32]byte) (s
 	addNonce(nonce)
 	return tx.Hash().Hex(), nil
 }
-
-func GetPHashForImage(reader io.Reader) ([]byte, error) {
-	image, err := png.Decode(reader)
-	if err != nil {
-		return nil, err
-	}
-	pHash, err := goimagehash.PerceptionHash(image)
-	if err != nil {
-		return nil, err
-	}
-
-	bs := make([]byte, pHash.Bits()/8)
-	binary.BigEndian.PutUint64(bs, pHash.GetHash())
-	return bs, nil
-}
-
-func GetHashForGPTResponse(reader io.Reader) ([]byte, error) {
-	content, err := io.ReadAll(reader)
-	if err != nil {
-		return nil, err
-	}
-	h := sha256.Sum256(content)
-	return h[:], nil
-}
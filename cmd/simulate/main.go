@@ -0,0 +1,183 @@
+// Command simulate runs synthetic nodes and tasks through the dispatch, validation and payment code of the relay
+// against an in-memory sqlite database in virtual time, to tune the qos and node selection parameters offline.
+//
+// The validation, queue and node selection settings are read from the relay config in the config dir.
+// Spot checks and canary tasks are disabled, as they are resolved by background jobs which are not simulated.
+// The results are written to the output dir as summary.json, nodes.csv and queue_waits.csv.
+//
+//	go run ./cmd/simulate -config config -duration 2h -nodes 100 -arrival-rate 1 -kickout-threshold 3
+package main
+
+import (
+	"context"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"crynux_relay/service"
+	"crynux_relay/simulation"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// parseGPUs parses the gpus in the form of "name:vram:speed:share,..."
+func parseGPUs(s string) ([]simulation.GPU, error) {
+	var gpus []simulation.GPU
+	for _, item := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ":")
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("invalid gpu %q, should be name:vram:speed:share", item)
+		}
+		vram, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gpu vram %q", parts[1])
+		}
+		speed, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gpu speed %q", parts[2])
+		}
+		share, err := strconv.ParseFloat(parts[3], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gpu share %q", parts[3])
+		}
+		gpus = append(gpus, simulation.GPU{Name: parts[0], Vram: vram, Speed: speed, Share: share})
+	}
+	return gpus, nil
+}
+
+func formatGPUs(gpus []simulation.GPU) string {
+	items := make([]string, len(gpus))
+	for i, gpu := range gpus {
+		items[i] = fmt.Sprintf("%s:%d:%g:%g", gpu.Name, gpu.Vram, gpu.Speed, gpu.Share)
+	}
+	return strings.Join(items, ",")
+}
+
+func writeFile(dir, name string, write func(w io.Writer) error) error {
+	f, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func run() error {
+	params := simulation.DefaultParams()
+
+	configDir := flag.String("config", "", "dir of the relay config, /app/config or config by default")
+	outputDir := flag.String("output", "simulation", "dir to write the results to")
+	logLevel := flag.String("log-level", "info", "log level of the relay")
+
+	flag.Int64Var(&params.Seed, "seed", params.Seed, "seed of the synthetic nodes and tasks")
+	flag.DurationVar(&params.Duration, "duration", params.Duration, "how long tasks arrive for, in virtual time")
+	flag.IntVar(&params.Nodes, "nodes", params.Nodes, "number of nodes")
+	gpus := flag.String("gpus", formatGPUs(params.GPUs), "gpu mix of the nodes, as name:vram:speed:share,...")
+	flag.Float64Var(&params.MinStake, "min-stake", params.MinStake, "min stake of a node, in ether")
+	flag.Float64Var(&params.MaxStake, "max-stake", params.MaxStake, "max stake of a node, in ether")
+	flag.IntVar(&params.NodeModels, "node-models", params.NodeModels, "number of local models of a node when it joins")
+	flag.StringVar(&params.NodeVersion, "node-version", params.NodeVersion, "version of the nodes")
+	flag.Float64Var(&params.FaultyNodeShare, "faulty-node-share", params.FaultyNodeShare, "share of the nodes that fail and time out tasks")
+	flag.Float64Var(&params.FailureRate, "failure-rate", params.FailureRate, "rate of tasks a faulty node reports an error for")
+	flag.Float64Var(&params.TimeoutRate, "timeout-rate", params.TimeoutRate, "rate of tasks a faulty node times out")
+	flag.Float64Var(&params.CheaterShare, "cheater-share", params.CheaterShare, "share of the nodes that cheat")
+	flag.Float64Var(&params.CheatRate, "cheat-rate", params.CheatRate, "rate of tasks a cheater reports a made up score for")
+	flag.IntVar(&params.Creators, "creators", params.Creators, "number of task creators")
+	flag.Float64Var(&params.ArrivalRate, "arrival-rate", params.ArrivalRate, "mean number of tasks arriving per second")
+	taskType := flag.Uint("task-type", uint(params.TaskType), "type of the tasks")
+	flag.StringVar(&params.TaskVersion, "task-version", params.TaskVersion, "version of the tasks")
+	flag.Float64Var(&params.TaskFee, "task-fee", params.TaskFee, "mean task fee, in ether")
+	flag.Float64Var(&params.TaskFeeSpread, "task-fee-spread", params.TaskFeeSpread, "task fees are uniform in task fee * (1 +- spread)")
+	flag.Uint64Var(&params.MinVRAM, "min-vram", params.MinVRAM, "min vram of the tasks, in GB")
+	flag.IntVar(&params.Models, "models", params.Models, "number of task models, chosen by a zipf distribution")
+	flag.Uint64Var(&params.Timeout, "timeout", params.Timeout, "timeout of the tasks, in seconds")
+	flag.Uint64Var(&params.MaxAttempts, "max-attempts", params.MaxAttempts, "max attempts of the tasks")
+	flag.DurationVar(&params.ExecutionTime, "execution-time", params.ExecutionTime, "mean execution time of a task on a gpu of speed 1")
+	flag.DurationVar(&params.ModelSwitchTime, "model-switch-time", params.ModelSwitchTime, "time to load a model not in use")
+	flag.DurationVar(&params.ValidateInterval, "validate-interval", params.ValidateInterval, "time between two rounds of validating the waiting groups")
+
+	flag.Uint64Var(&service.TASK_SCORE_POOL_SIZE, "task-score-pool-size", service.TASK_SCORE_POOL_SIZE, "number of recent tasks checked for kickout")
+	flag.Uint64Var(&service.KickoutThreshold, "kickout-threshold", service.KickoutThreshold, "number of timeouts in the recent tasks to kick out a node")
+	flag.Uint64Var(&service.NODE_QOS_SCORE_POOL_SIZE, "node-qos-score-pool-size", service.NODE_QOS_SCORE_POOL_SIZE, "number of task qos scores averaged into the node qos score")
+	flag.Uint64Var(&service.MAX_TASK_QOS_SCORE, "max-task-qos-score", service.MAX_TASK_QOS_SCORE, "qos score of the first finished task in a validation group")
+	flag.Float64Var(&service.StakingScoreWeight, "staking-score-weight", service.StakingScoreWeight, "weight Ws of the staking score s in the selecting probability s*q/(Ws*q + Wq*s)")
+	flag.Float64Var(&service.QosScoreWeight, "qos-score-weight", service.QosScoreWeight, "weight Wq of the qos score q in the selecting probability s*q/(Ws*q + Wq*s)")
+	flag.Float64Var(&service.LocalityBonus, "locality-bonus", service.LocalityBonus, "weight raise of nodes with the task models in use")
+	flag.Parse()
+
+	var err error
+	if params.GPUs, err = parseGPUs(*gpus); err != nil {
+		return err
+	}
+	if *taskType > 255 {
+		return errors.New("invalid task type")
+	}
+	params.TaskType = models.TaskType(*taskType)
+	if service.TASK_SCORE_POOL_SIZE == 0 || service.NODE_QOS_SCORE_POOL_SIZE == 0 || service.MAX_TASK_QOS_SCORE == 0 {
+		return errors.New("pool sizes and max task qos score should be positive")
+	}
+
+	level, err := log.ParseLevel(*logLevel)
+	if err != nil {
+		return err
+	}
+	log.SetLevel(level)
+
+	if err := config.InitConfig(*configDir); err != nil {
+		return err
+	}
+	if err := models.CheckValidationRules(); err != nil {
+		return err
+	}
+	conf := config.GetConfig()
+	conf.Db.Driver = "sqlite"
+	conf.Db.ConnectionString = "file::memory:"
+	conf.Db.Log.Level = "error"
+	conf.Db.Log.Output = "stderr"
+	conf.SpotCheck.Enabled = false
+	conf.Canary.Enabled = false
+	if err := config.InitDB(conf); err != nil {
+		return err
+	}
+
+	simulator, err := simulation.NewSimulator(params)
+	if err != nil {
+		return err
+	}
+	log.Infof("Simulate: running %s of tasks on %d nodes", params.Duration, params.Nodes)
+	res, err := simulator.Run(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(*outputDir, 0755); err != nil {
+		return err
+	}
+	if err := writeFile(*outputDir, "summary.json", res.WriteJSON); err != nil {
+		return err
+	}
+	if err := writeFile(*outputDir, "nodes.csv", res.WriteNodesCSV); err != nil {
+		return err
+	}
+	if err := writeFile(*outputDir, "queue_waits.csv", res.WriteQueueWaitsCSV); err != nil {
+		return err
+	}
+	log.Infof("Simulate: %d tasks created, results written to %s", res.CreatedTasks, *outputDir)
+	return nil
+}
+
+func main() {
+	if err := run(); err != nil {
+		log.Errorln(err.Error())
+		os.Exit(1)
+	}
+}
@@ -38,6 +38,32 @@ type AppConfig struct {
 		InferenceTasks string `mapstructure:"inference_tasks"`
 	} `mapstructure:"data_dir"`
 
+	Storage struct {
+		Backend string `mapstructure:"backend" description:"filesystem or s3"`
+		// redirect downloads to presigned urls, only supported by the s3 backend
+		PresignedRedirect bool   `mapstructure:"presigned_redirect"`
+		PresignedExpires  uint64 `mapstructure:"presigned_expires" description:"expire time of presigned urls, in seconds"`
+		S3                struct {
+			Endpoint        string `mapstructure:"endpoint"`
+			Region          string `mapstructure:"region"`
+			Bucket          string `mapstructure:"bucket"`
+			AccessKeyID     string `mapstructure:"access_key_id"`
+			SecretAccessKey string `mapstructure:"secret_access_key"`
+			UseSSL          bool   `mapstructure:"use_ssl"`
+			Prefix          string `mapstructure:"prefix"`
+		} `mapstructure:"s3"`
+	} `mapstructure:"storage"`
+
+	Retention struct {
+		Enabled       bool   `mapstructure:"enabled"`
+		CheckInterval uint64 `mapstructure:"check_interval" description:"interval between two purges, in minutes"`
+		MaxPinHours   uint64 `mapstructure:"max_pin_hours" description:"max hours a task can be pinned for, 0 means the hard limit of about 100 years"`
+		// retention hours of each task type, 0 means the task data is kept forever
+		StableDiffusionInference    uint64 `mapstructure:"stable_diffusion_inference"`
+		GPTInference                uint64 `mapstructure:"gpt_inference"`
+		StableDiffusionFinetuneLora uint64 `mapstructure:"stable_diffusion_finetune_lora"`
+	} `mapstructure:"retention"`
+
 	Blockchain struct {
 		RPS           uint64 `mapstructure:"rps"`
 		RpcEndpoint   string `mapstructure:"rpc_endpoint"`
@@ -64,7 +90,101 @@ type AppConfig struct {
 		DistanceThreshold uint64 `mapstructure:"distance_threshold"`
 	}
 
+	Validation struct {
+		// tasks in a validation group, and how many of them must agree on the result
+		GroupSize uint64 `mapstructure:"group_size"`
+		Quorum    uint64 `mapstructure:"quorum"`
+		// a task is selected for validation when its vrf number mod sampling modulus is 0
+		SamplingModulus uint64 `mapstructure:"sampling_modulus"`
+		// the first rule matching the task type and one of the model ids of a task is used,
+		// a rule without model id matches all models of the task type.
+		// Nodes hash the results by the default validator of the task type, so the validator of a rule
+		// must be the default one, and the rule only changes its threshold.
+		Rules []struct {
+			TaskType  uint8    `mapstructure:"task_type"`
+			ModelID   string   `mapstructure:"model_id"`
+			Validator string   `mapstructure:"validator"`
+			Threshold *float64 `mapstructure:"threshold"`
+		} `mapstructure:"rules"`
+	} `mapstructure:"validation"`
+
+	Dispute struct {
+		Enabled     bool   `mapstructure:"enabled"`
+		WindowHours uint64 `mapstructure:"window_hours" description:"hours after the task succeeds in which the creator can open a dispute"`
+		Deposit     uint64 `mapstructure:"deposit" description:"dispute deposit, in ether unit"`
+	} `mapstructure:"dispute"`
+
+	Canary struct {
+		Enabled  bool   `mapstructure:"enabled"`
+		Interval uint64 `mapstructure:"interval" description:"interval between two canary tasks, in minutes"`
+		// creators of canary and spot check tasks, topped up from the relay account.
+		// Each task is created by one of them picked at random, so that nodes cannot filter out the tasks
+		// by a single creator visible in the task response. At least 3 different addresses, which are not
+		// the relay account, are required when canary or spot check is enabled.
+		Creators []string `mapstructure:"creators"`
+		// slash the node when its canary result mismatches the reference, otherwise only its qos score is lowered
+		Slash bool `mapstructure:"slash"`
+		// canary tasks are picked from the pool in turn. The args should be taken from the tasks of real creators,
+		// and the pool should be large enough, so that the args do not tell the canary tasks apart
+		Tasks []struct {
+			TaskType        uint8    `mapstructure:"task_type"`
+			TaskArgs        string   `mapstructure:"task_args"`
+			TaskVersion     string   `mapstructure:"task_version"`
+			ModelIDs        []string `mapstructure:"model_ids"`
+			MinVRAM         uint64   `mapstructure:"min_vram"`
+			RequiredGPU     string   `mapstructure:"required_gpu"`
+			RequiredGPUVRAM uint64   `mapstructure:"required_gpu_vram"`
+			TaskSize        uint64   `mapstructure:"task_size"`
+			TaskFee         string   `mapstructure:"task_fee" description:"task fee, in wei"`
+			Timeout         uint64   `mapstructure:"timeout"`
+			// reference score of the task args, in hex
+			Score string `mapstructure:"score"`
+		} `mapstructure:"tasks"`
+	} `mapstructure:"canary"`
+
+	SpotCheck struct {
+		Enabled bool `mapstructure:"enabled"`
+		// the spot check rate of a node starts at max rate when it joins, and halves every half life
+		// until it reaches base rate. Nodes with low qos score or recently invalidated tasks are checked more often
+		BaseRate                 float64 `mapstructure:"base_rate"`
+		MaxRate                  float64 `mapstructure:"max_rate"`
+		HalfLifeHours            uint64  `mapstructure:"half_life_hours"`
+		LowQOSScore              float64 `mapstructure:"low_qos_score"`
+		InvalidatedLookbackHours uint64  `mapstructure:"invalidated_lookback_hours"`
+	} `mapstructure:"spot_check"`
+
+	NodeSelection struct {
+		// weighted samples nodes by staking, qos score and model locality.
+		// lowest_latency prefers gpus with the lowest mean execution time of the task type,
+		// model_locality only selects nodes with the best model locality, and round_robin selects nodes in turn for testing
+		Selector string `mapstructure:"selector"`
+	} `mapstructure:"node_selection"`
+
+	Queue struct {
+		// fee_first dispatches tasks with higher task fee first.
+		// fair_share shares nodes between creators in proportion to their weights, and ages waiting tasks
+		Policy         string  `mapstructure:"policy"`
+		DefaultWeight  float64 `mapstructure:"default_weight"`
+		CreatorWeights []struct {
+			Address string  `mapstructure:"address"`
+			Weight  float64 `mapstructure:"weight"`
+		} `mapstructure:"creator_weights"`
+		// a waiting task gains the priority of one task of a creator with weight 1 every aging seconds
+		AgingSeconds uint64 `mapstructure:"aging_seconds"`
+	} `mapstructure:"queue"`
+
+	Leader struct {
+		// relay instances sharing a database elect a leader by a lease in the database.
+		// Only the leader dispatches tasks and runs the background jobs, the others only serve the api
+		InstanceID   string `mapstructure:"instance_id"`
+		LeaseSeconds uint64 `mapstructure:"lease_seconds"`
+	} `mapstructure:"leader"`
+
 	TaskSchema struct {
+		// schemas in the directory are named as <task type name>/<version>.json
+		Dir string `mapstructure:"dir"`
+		// Deprecated: urls of the task args schemas, which replace the embedded default schemas of the task types.
+		// Use schema files in the directory or schemas uploaded through the api instead.
 		StableDiffusionInference    string `mapstructure:"stable_diffusion_inference"`
 		GPTInference                string `mapstructure:"gpt_inference"`
 		StableDiffusionFinetuneLora string `mapstructure:"stable_diffusion_finetune_lora"`
@@ -1,11 +1,14 @@
 package config
 
 import (
+	"crynux_relay/validator"
 	"crypto/ecdsa"
 	"errors"
+	"fmt"
 	"os"
 	"strings"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/spf13/viper"
 )
@@ -45,7 +48,171 @@ func InitConfig(configPath string) error {
 	if err := checkBlockchainAccount(); err != nil {
 		return err
 	}
+	if err := checkValidation(); err != nil {
+		return err
+	}
+	if err := checkCanary(); err != nil {
+		return err
+	}
+	if err := checkSpotCheck(); err != nil {
+		return err
+	}
+	if err := checkNodeSelection(); err != nil {
+		return err
+	}
+	if err := checkQueue(); err != nil {
+		return err
+	}
+	if err := checkLeader(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func checkValidation() error {
+	if appConfig.Validation.GroupSize == 0 {
+		appConfig.Validation.GroupSize = 3
+	}
+	if appConfig.Validation.Quorum == 0 {
+		appConfig.Validation.Quorum = appConfig.Validation.GroupSize/2 + 1
+	}
+	if appConfig.Validation.SamplingModulus == 0 {
+		appConfig.Validation.SamplingModulus = 100
+	}
+
+	if appConfig.Validation.GroupSize < 2 {
+		return errors.New("validation group size should be at least 2")
+	}
+	// more than half of the group should agree, so that there is only one majority result
+	if appConfig.Validation.Quorum*2 <= appConfig.Validation.GroupSize || appConfig.Validation.Quorum > appConfig.Validation.GroupSize {
+		return errors.New("validation quorum should be more than half of the group size")
+	}
+	for _, rule := range appConfig.Validation.Rules {
+		if _, ok := validator.Get(rule.Validator); !ok {
+			return fmt.Errorf("unknown validator %q in validation rules", rule.Validator)
+		}
+		if rule.Threshold != nil && !(*rule.Threshold >= 0) {
+			return fmt.Errorf("invalid threshold of validator %q in validation rules", rule.Validator)
+		}
+	}
+	return nil
+}
+
+func checkCanary() error {
+	if appConfig.Canary.Interval == 0 {
+		appConfig.Canary.Interval = 60
+	}
+	if appConfig.Canary.Enabled && len(appConfig.Canary.Tasks) == 0 {
+		return errors.New("canary tasks not set")
+	}
+	if appConfig.Canary.Enabled {
+		return checkCanaryCreators()
+	}
+	return nil
+}
+
+// minCanaryCreators is the min number of creators of canary and spot check tasks
+const minCanaryCreators = 3
 
+// checkCanaryCreators makes sure canary and spot check tasks are created by a set of dedicated accounts,
+// so that they cannot be told apart from other tasks by the relay address or a single fixed address as the creator
+func checkCanaryCreators() error {
+	creators := appConfig.Canary.Creators
+	if len(creators) < minCanaryCreators {
+		return fmt.Errorf("at least %d canary creators are required", minCanaryCreators)
+	}
+	relayAddress := common.HexToAddress(appConfig.Blockchain.Account.Address)
+	seen := make(map[common.Address]bool)
+	for _, creator := range creators {
+		if !common.IsHexAddress(creator) {
+			return fmt.Errorf("invalid canary creator address %s", creator)
+		}
+		address := common.HexToAddress(creator)
+		if address == relayAddress {
+			return errors.New("canary creators should not include the relay account")
+		}
+		if seen[address] {
+			return fmt.Errorf("duplicate canary creator %s", creator)
+		}
+		seen[address] = true
+	}
+	return nil
+}
+
+func checkSpotCheck() error {
+	if appConfig.SpotCheck.BaseRate == 0 {
+		appConfig.SpotCheck.BaseRate = 0.01
+	}
+	if appConfig.SpotCheck.MaxRate == 0 {
+		appConfig.SpotCheck.MaxRate = 0.5
+	}
+	if appConfig.SpotCheck.HalfLifeHours == 0 {
+		appConfig.SpotCheck.HalfLifeHours = 72
+	}
+	if appConfig.SpotCheck.LowQOSScore == 0 {
+		appConfig.SpotCheck.LowQOSScore = 5
+	}
+	if appConfig.SpotCheck.InvalidatedLookbackHours == 0 {
+		appConfig.SpotCheck.InvalidatedLookbackHours = 168
+	}
+
+	if appConfig.SpotCheck.BaseRate > appConfig.SpotCheck.MaxRate || appConfig.SpotCheck.MaxRate > 1 {
+		return errors.New("spot check rates should satisfy base rate <= max rate <= 1")
+	}
+	// spot check tasks are created by the canary creator
+	if appConfig.SpotCheck.Enabled {
+		return checkCanaryCreators()
+	}
+	return nil
+}
+
+func checkNodeSelection() error {
+	switch appConfig.NodeSelection.Selector {
+	case "":
+		appConfig.NodeSelection.Selector = "weighted"
+	case "weighted", "lowest_latency", "model_locality", "round_robin":
+	default:
+		return errors.New("node selector should be weighted, lowest_latency, model_locality or round_robin")
+	}
+	return nil
+}
+
+func checkQueue() error {
+	if len(appConfig.Queue.Policy) == 0 {
+		appConfig.Queue.Policy = "fee_first"
+	}
+	if appConfig.Queue.Policy != "fee_first" && appConfig.Queue.Policy != "fair_share" {
+		return errors.New("queue policy should be fee_first or fair_share")
+	}
+	if appConfig.Queue.DefaultWeight == 0 {
+		appConfig.Queue.DefaultWeight = 1
+	}
+	if appConfig.Queue.AgingSeconds == 0 {
+		appConfig.Queue.AgingSeconds = 60
+	}
+	if appConfig.Queue.DefaultWeight < 0 {
+		return errors.New("queue default weight should be positive")
+	}
+	for _, creatorWeight := range appConfig.Queue.CreatorWeights {
+		if creatorWeight.Weight <= 0 {
+			return errors.New("queue creator weights should be positive")
+		}
+	}
+	return nil
+}
+
+func checkLeader() error {
+	if appConfig.Leader.LeaseSeconds == 0 {
+		appConfig.Leader.LeaseSeconds = 15
+	}
+	if len(appConfig.Leader.InstanceID) == 0 {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return err
+		}
+		appConfig.Leader.InstanceID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
 	return nil
 }
 
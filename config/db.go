@@ -2,6 +2,10 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -48,6 +52,11 @@ func InitDB(appConfig *AppConfig) error {
 		return err
 	}
 
+	if err := checkDBVersion(instance, appConfig.Db.Driver); err != nil {
+		log.Error("InitDB Failed:" + err.Error())
+		return err
+	}
+
 	if appConfig.Db.Driver == "sqlite" {
 		sqlDB.SetMaxOpenConns(1)
 	} else {
@@ -61,6 +70,42 @@ func InitDB(appConfig *AppConfig) error {
 	return nil
 }
 
+var dbVersionPattern = regexp.MustCompile(`^(\d+)\.(\d+)`)
+
+// checkDBVersion checks that the database supports the window functions the task queue is scanned with,
+// which are supported since MySQL 8.0, MariaDB 10.2 and SQLite 3.25. All supported Postgres versions have them.
+func checkDBVersion(instance *gorm.DB, driver string) error {
+	var query string
+	var minMajor, minMinor int
+	if driver == "mysql" {
+		query = "SELECT VERSION()"
+		minMajor, minMinor = 8, 0
+	} else if driver == "sqlite" {
+		query = "SELECT sqlite_version()"
+		minMajor, minMinor = 3, 25
+	} else {
+		return nil
+	}
+
+	var version string
+	if err := instance.Raw(query).Scan(&version).Error; err != nil {
+		return err
+	}
+	if driver == "mysql" && strings.Contains(strings.ToLower(version), "mariadb") {
+		minMajor, minMinor = 10, 2
+	}
+	matches := dbVersionPattern.FindStringSubmatch(version)
+	if matches == nil {
+		return fmt.Errorf("unknown %s version: %s", driver, version)
+	}
+	major, _ := strconv.Atoi(matches[1])
+	minor, _ := strconv.Atoi(matches[2])
+	if major < minMajor || (major == minMajor && minor < minMinor) {
+		return fmt.Errorf("%s version %s is not supported, at least %d.%d is required", driver, version, minMajor, minMinor)
+	}
+	return nil
+}
+
 func GetDB() *gorm.DB {
 	return db
 }
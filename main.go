@@ -6,10 +6,13 @@ import (
 	"crynux_relay/blockchain"
 	"crynux_relay/config"
 	"crynux_relay/migrate"
+	"crynux_relay/models"
 	"crynux_relay/service"
+	"crynux_relay/storage"
 	"crynux_relay/tasks"
 	"fmt"
 	"os"
+	"sync"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -21,6 +24,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := models.CheckValidationRules(); err != nil {
+		print("Error in validation rules")
+		print(err.Error())
+		os.Exit(1)
+	}
+
 	conf := config.GetConfig()
 
 	if err := config.InitLog(conf); err != nil {
@@ -36,6 +45,10 @@ func main() {
 
 	startDBMigration()
 
+	if err := storage.InitStorage(conf); err != nil {
+		log.Fatalln(err)
+	}
+
 	if err := blockchain.Init(context.Background()); err != nil {
 		log.Fatalln(err)
 	}
@@ -43,24 +56,49 @@ func main() {
 		log.Fatalln(err)
 	}
 
-	if err := service.InitBalanceCache(context.Background(), config.GetDB()); err != nil {
+	if err := service.ApplyPendingTransferEvents(context.Background(), config.GetDB()); err != nil {
 		log.Fatalln(err)
 	}
 	if err := service.InitSelectingProb(context.Background(), config.GetDB()); err != nil {
 		log.Fatalln(err)
 	}
-	go service.StartTaskProcesser(context.Background())
-	go service.StartBalanceSync(context.Background(), config.GetDB())
-	// go tasks.ProcessTasks(context.Background())
-	go tasks.StartSyncNetwork(context.Background())
-	go tasks.StartStatsTaskCount(context.Background())
-	go tasks.StartStatsTaskExecutionTimeCount(context.Background())
-	go tasks.StartStatsTaskUploadResultTimeCount(context.Background())
-	go tasks.StartStatsTaskWaitingTimeCount(context.Background())
+	go tasks.StartRefreshMaxStaking(context.Background())
+	// the api is served by every instance, while only the leader dispatches tasks and runs the background jobs
+	go service.RunAsLeader(context.Background(), config.GetDB(), startBackgroundJobs)
 
 	startServer()
 }
 
+// startBackgroundJobs runs the jobs of the leader and returns after all of them have stopped,
+// so that the leader lease is not given up while a job is still running
+func startBackgroundJobs(ctx context.Context) {
+	jobs := []func(context.Context){
+		service.StartTaskProcesser,
+		// tasks.ProcessTasks,
+		tasks.StartProcessWaitingTasks,
+		tasks.StartResolveTaskDisputes,
+		tasks.StartCreateCanaryTasks,
+		tasks.StartResolveCanaryTasks,
+		tasks.StartResolveSpotChecks,
+		tasks.StartPurgeTaskData,
+		tasks.StartSyncNetwork,
+		tasks.StartStatsTaskCount,
+		tasks.StartStatsTaskExecutionTimeCount,
+		tasks.StartStatsTaskUploadResultTimeCount,
+		tasks.StartStatsTaskWaitingTimeCount,
+	}
+
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func(job func(context.Context)) {
+			defer wg.Done()
+			job(ctx)
+		}(job)
+	}
+	wg.Wait()
+}
+
 func startServer() {
 	conf := config.GetConfig()
 
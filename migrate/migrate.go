@@ -66,4 +66,22 @@ func InitMigration(db *gorm.DB) {
 	migrationScripts = append(migrationScripts, migrations.M20250715(db))
 	migrationScripts = append(migrationScripts, migrations.M20250725(db))
 	migrationScripts = append(migrationScripts, migrations.M20250728(db))
+	migrationScripts = append(migrationScripts, migrations.M20250801(db))
+	migrationScripts = append(migrationScripts, migrations.M20250802(db))
+	migrationScripts = append(migrationScripts, migrations.M20250803(db))
+	migrationScripts = append(migrationScripts, migrations.M20250804(db))
+	migrationScripts = append(migrationScripts, migrations.M20250805(db))
+	migrationScripts = append(migrationScripts, migrations.M20250806(db))
+	migrationScripts = append(migrationScripts, migrations.M20250807(db))
+	migrationScripts = append(migrationScripts, migrations.M20250808(db))
+	migrationScripts = append(migrationScripts, migrations.M20250809(db))
+	migrationScripts = append(migrationScripts, migrations.M20250810(db))
+	migrationScripts = append(migrationScripts, migrations.M20250811(db))
+	migrationScripts = append(migrationScripts, migrations.M20250812(db))
+	migrationScripts = append(migrationScripts, migrations.M20250813(db))
+	migrationScripts = append(migrationScripts, migrations.M20250814(db))
+	migrationScripts = append(migrationScripts, migrations.M20250815(db))
+	migrationScripts = append(migrationScripts, migrations.M20250816(db))
+	migrationScripts = append(migrationScripts, migrations.M20250817(db))
+	migrationScripts = append(migrationScripts, migrations.M20250818(db))
 }
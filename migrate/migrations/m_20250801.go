@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func M20250801(db *gorm.DB) *gormigrate.Gormigrate {
+	type InferenceTask struct {
+		QueuedTime sql.NullTime `json:"queued_time" gorm:"null;default:null"`
+	}
+
+	type TaskDependency struct {
+		gorm.Model
+		TaskIDCommitment       string `json:"task_id_commitment" gorm:"index"`
+		ParentTaskIDCommitment string `json:"parent_task_id_commitment" gorm:"index"`
+	}
+
+	return gormigrate.New(db, gormigrate.DefaultOptions, []*gormigrate.Migration{
+		{
+			ID: "M20250801",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Migrator().AddColumn(&InferenceTask{}, "QueuedTime"); err != nil {
+					return err
+				}
+				return tx.Migrator().CreateTable(&TaskDependency{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropTable(&TaskDependency{}); err != nil {
+					return err
+				}
+				return tx.Migrator().DropColumn(&InferenceTask{}, "QueuedTime")
+			},
+		},
+	})
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func M20250802(db *gorm.DB) *gormigrate.Gormigrate {
+	type InferenceTask struct {
+		NotBefore sql.NullTime `json:"not_before" gorm:"index;null;default:null"`
+	}
+
+	return gormigrate.New(db, gormigrate.DefaultOptions, []*gormigrate.Migration{
+		{
+			ID: "M20250802",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Migrator().AddColumn(&InferenceTask{}, "NotBefore"); err != nil {
+					return err
+				}
+				return tx.Migrator().CreateIndex(&InferenceTask{}, "NotBefore")
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropIndex(&InferenceTask{}, "NotBefore"); err != nil {
+					return err
+				}
+				return tx.Migrator().DropColumn(&InferenceTask{}, "NotBefore")
+			},
+		},
+	})
+}
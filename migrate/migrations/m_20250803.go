@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func M20250803(db *gorm.DB) *gormigrate.Gormigrate {
+	type InferenceTask struct {
+		RetainUntil     sql.NullTime `json:"retain_until" gorm:"null;default:null"`
+		ResultsPurgedAt sql.NullTime `json:"results_purged_at" gorm:"index;null;default:null"`
+	}
+
+	return gormigrate.New(db, gormigrate.DefaultOptions, []*gormigrate.Migration{
+		{
+			ID: "M20250803",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Migrator().AddColumn(&InferenceTask{}, "RetainUntil"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().AddColumn(&InferenceTask{}, "ResultsPurgedAt"); err != nil {
+					return err
+				}
+				return tx.Migrator().CreateIndex(&InferenceTask{}, "ResultsPurgedAt")
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropIndex(&InferenceTask{}, "ResultsPurgedAt"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().DropColumn(&InferenceTask{}, "ResultsPurgedAt"); err != nil {
+					return err
+				}
+				return tx.Migrator().DropColumn(&InferenceTask{}, "RetainUntil")
+			},
+		},
+	})
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func M20250804(db *gorm.DB) *gormigrate.Gormigrate {
+	type TaskStreamChunk struct {
+		gorm.Model
+		TaskIDCommitment string `json:"task_id_commitment" gorm:"size:191;uniqueIndex:idx_task_stream_chunk_sequence"`
+		Sequence         uint64 `json:"sequence" gorm:"uniqueIndex:idx_task_stream_chunk_sequence"`
+		Content          string `json:"content" gorm:"type:text"`
+	}
+
+	return gormigrate.New(db, gormigrate.DefaultOptions, []*gormigrate.Migration{
+		{
+			ID: "M20250804",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.Migrator().CreateTable(&TaskStreamChunk{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&TaskStreamChunk{})
+			},
+		},
+	})
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func M20250805(db *gorm.DB) *gormigrate.Gormigrate {
+	type InferenceTask struct {
+		ProgressPercent     uint64       `json:"progress_percent"`
+		ProgressStep        uint64       `json:"progress_step"`
+		ProgressStage       string       `json:"progress_stage"`
+		ProgressUpdatedTime sql.NullTime `json:"progress_updated_time" gorm:"null;default:null"`
+	}
+
+	return gormigrate.New(db, gormigrate.DefaultOptions, []*gormigrate.Migration{
+		{
+			ID: "M20250805",
+			Migrate: func(tx *gorm.DB) error {
+				for _, column := range []string{"ProgressPercent", "ProgressStep", "ProgressStage", "ProgressUpdatedTime"} {
+					if err := tx.Migrator().AddColumn(&InferenceTask{}, column); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				for _, column := range []string{"ProgressPercent", "ProgressStep", "ProgressStage", "ProgressUpdatedTime"} {
+					if err := tx.Migrator().DropColumn(&InferenceTask{}, column); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+	})
+}
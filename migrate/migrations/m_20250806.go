@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func M20250806(db *gorm.DB) *gormigrate.Gormigrate {
+	type InferenceTask struct {
+		MaxAttempts uint64 `json:"max_attempts" gorm:"default:1"`
+		Attempts    uint64 `json:"attempts"`
+	}
+
+	type InferenceTaskAttempt struct {
+		gorm.Model
+		TaskIDCommitment string       `json:"task_id_commitment" gorm:"index"`
+		Attempt          uint64       `json:"attempt"`
+		NodeAddress      string       `json:"node_address" gorm:"index"`
+		StartTime        sql.NullTime `json:"start_time" gorm:"null;default:null"`
+		EndTime          sql.NullTime `json:"end_time" gorm:"null;default:null"`
+		Outcome          uint8        `json:"outcome"`
+		AbortReason      uint8        `json:"abort_reason"`
+	}
+
+	return gormigrate.New(db, gormigrate.DefaultOptions, []*gormigrate.Migration{
+		{
+			ID: "M20250806",
+			Migrate: func(tx *gorm.DB) error {
+				for _, column := range []string{"MaxAttempts", "Attempts"} {
+					if err := tx.Migrator().AddColumn(&InferenceTask{}, column); err != nil {
+						return err
+					}
+				}
+				return tx.Migrator().CreateTable(&InferenceTaskAttempt{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropTable(&InferenceTaskAttempt{}); err != nil {
+					return err
+				}
+				for _, column := range []string{"MaxAttempts", "Attempts"} {
+					if err := tx.Migrator().DropColumn(&InferenceTask{}, column); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+	})
+}
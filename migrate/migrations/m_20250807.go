@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func M20250807(db *gorm.DB) *gormigrate.Gormigrate {
+	type TaskSchema struct {
+		gorm.Model
+		TaskType uint8  `json:"task_type" gorm:"uniqueIndex:idx_task_schema_type_version"`
+		Version  string `json:"version" gorm:"size:191;uniqueIndex:idx_task_schema_type_version"`
+		Content  string `json:"content" gorm:"type:text"`
+		Active   bool   `json:"active"`
+	}
+
+	return gormigrate.New(db, gormigrate.DefaultOptions, []*gormigrate.Migration{
+		{
+			ID: "M20250807",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.Migrator().CreateTable(&TaskSchema{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&TaskSchema{})
+			},
+		},
+	})
+}
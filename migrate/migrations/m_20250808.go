@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func M20250808(db *gorm.DB) *gormigrate.Gormigrate {
+	type InferenceTask struct {
+		Validator          string  `json:"validator"`
+		ValidatorThreshold float64 `json:"validator_threshold"`
+	}
+
+	return gormigrate.New(db, gormigrate.DefaultOptions, []*gormigrate.Migration{
+		{
+			ID: "M20250808",
+			Migrate: func(tx *gorm.DB) error {
+				for _, column := range []string{"Validator", "ValidatorThreshold"} {
+					if err := tx.Migrator().AddColumn(&InferenceTask{}, column); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				for _, column := range []string{"Validator", "ValidatorThreshold"} {
+					if err := tx.Migrator().DropColumn(&InferenceTask{}, column); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+	})
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func M20250809(db *gorm.DB) *gormigrate.Gormigrate {
+	type ValidationRecord struct {
+		gorm.Model
+		TaskID           string        `json:"task_id" gorm:"index"`
+		TaskIDCommitment string        `json:"task_id_commitment" gorm:"index"`
+		SelectedNode     string        `json:"selected_node"`
+		Grouped          bool          `json:"grouped"`
+		ExecutionOrder   uint64        `json:"execution_order"`
+		ExecutionTime    uint64        `json:"execution_time"`
+		Status           uint8         `json:"status"`
+		Score            string        `json:"score" gorm:"type:text"`
+		Validator        string        `json:"validator"`
+		Threshold        float64       `json:"threshold"`
+		Comparisons      string        `json:"comparisons" gorm:"type:text"`
+		QOSScore         sql.NullInt64 `json:"qos_score"`
+		Verdict          uint8         `json:"verdict"`
+	}
+
+	return gormigrate.New(db, gormigrate.DefaultOptions, []*gormigrate.Migration{
+		{
+			ID: "M20250809",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.Migrator().CreateTable(&ValidationRecord{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&ValidationRecord{})
+			},
+		},
+	})
+}
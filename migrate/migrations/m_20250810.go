@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"crynux_relay/models"
+	"database/sql"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func M20250810(db *gorm.DB) *gormigrate.Gormigrate {
+	type InferenceTask struct {
+		DisputeTaskIDCommitment string `json:"dispute_task_id_commitment" gorm:"index"`
+	}
+
+	type TaskDispute struct {
+		gorm.Model
+		TaskIDCommitment              string        `json:"task_id_commitment" gorm:"size:191;uniqueIndex"`
+		Creator                       string        `json:"creator"`
+		SelectedNode                  string        `json:"selected_node" gorm:"index"`
+		Deposit                       models.BigInt `json:"deposit" gorm:"type:string;size:255"`
+		VerificationTaskIDCommitments string        `json:"verification_task_id_commitments" gorm:"type:text"`
+		Status                        uint8         `json:"status" gorm:"index"`
+		ClawbackAmount                models.BigInt `json:"clawback_amount" gorm:"type:string;size:255"`
+		ResolvedTime                  sql.NullTime  `json:"resolved_time" gorm:"null;default:null"`
+	}
+
+	return gormigrate.New(db, gormigrate.DefaultOptions, []*gormigrate.Migration{
+		{
+			ID: "M20250810",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Migrator().AddColumn(&InferenceTask{}, "DisputeTaskIDCommitment"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().CreateIndex(&InferenceTask{}, "DisputeTaskIDCommitment"); err != nil {
+					return err
+				}
+				return tx.Migrator().CreateTable(&TaskDispute{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropTable(&TaskDispute{}); err != nil {
+					return err
+				}
+				return tx.Migrator().DropColumn(&InferenceTask{}, "DisputeTaskIDCommitment")
+			},
+		},
+	})
+}
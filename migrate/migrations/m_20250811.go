@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func M20250811(db *gorm.DB) *gormigrate.Gormigrate {
+	type InferenceTask struct {
+		Canary bool `json:"canary" gorm:"index;default:false"`
+	}
+
+	type CanaryTask struct {
+		gorm.Model
+		TaskIDCommitment string       `json:"task_id_commitment" gorm:"size:191;uniqueIndex"`
+		SelectedNode     string       `json:"selected_node" gorm:"index"`
+		ReferenceScore   string       `json:"reference_score" gorm:"type:text"`
+		Status           uint8        `json:"status" gorm:"index"`
+		ResolvedTime     sql.NullTime `json:"resolved_time" gorm:"null;default:null"`
+	}
+
+	return gormigrate.New(db, gormigrate.DefaultOptions, []*gormigrate.Migration{
+		{
+			ID: "M20250811",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Migrator().AddColumn(&InferenceTask{}, "Canary"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().CreateIndex(&InferenceTask{}, "Canary"); err != nil {
+					return err
+				}
+				return tx.Migrator().CreateTable(&CanaryTask{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropTable(&CanaryTask{}); err != nil {
+					return err
+				}
+				return tx.Migrator().DropColumn(&InferenceTask{}, "Canary")
+			},
+		},
+	})
+}
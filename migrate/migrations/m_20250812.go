@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"crynux_relay/models"
+	"database/sql"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func M20250812(db *gorm.DB) *gormigrate.Gormigrate {
+	type InferenceTask struct {
+		SpotCheckTaskIDCommitment string `json:"spot_check_task_id_commitment" gorm:"index"`
+	}
+
+	type SpotCheck struct {
+		gorm.Model
+		TaskIDCommitment      string        `json:"task_id_commitment" gorm:"size:191;uniqueIndex"`
+		SelectedNode          string        `json:"selected_node" gorm:"index"`
+		CheckTaskIDCommitment string        `json:"check_task_id_commitment" gorm:"index"`
+		Rate                  float64       `json:"rate"`
+		Payment               models.BigInt `json:"payment" gorm:"type:string;size:255"`
+		Status                uint8         `json:"status" gorm:"index"`
+		ResolvedTime          sql.NullTime  `json:"resolved_time" gorm:"null;default:null"`
+	}
+
+	return gormigrate.New(db, gormigrate.DefaultOptions, []*gormigrate.Migration{
+		{
+			ID: "M20250812",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Migrator().AddColumn(&InferenceTask{}, "SpotCheckTaskIDCommitment"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().CreateIndex(&InferenceTask{}, "SpotCheckTaskIDCommitment"); err != nil {
+					return err
+				}
+				return tx.Migrator().CreateTable(&SpotCheck{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropTable(&SpotCheck{}); err != nil {
+					return err
+				}
+				return tx.Migrator().DropColumn(&InferenceTask{}, "SpotCheckTaskIDCommitment")
+			},
+		},
+	})
+}
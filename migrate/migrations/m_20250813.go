@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func M20250813(db *gorm.DB) *gormigrate.Gormigrate {
+	type TaskStatusTransition struct {
+		gorm.Model
+		TaskIDCommitment string `json:"task_id_commitment" gorm:"index"`
+		Event            string `json:"event"`
+		FromStatus       uint8  `json:"from_status"`
+		ToStatus         uint8  `json:"to_status"`
+		Actor            string `json:"actor"`
+		Reason           string `json:"reason"`
+	}
+
+	return gormigrate.New(db, gormigrate.DefaultOptions, []*gormigrate.Migration{
+		{
+			ID: "M20250813",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.Migrator().CreateTable(&TaskStatusTransition{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&TaskStatusTransition{})
+			},
+		},
+	})
+}
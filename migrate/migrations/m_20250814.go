@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func M20250814(db *gorm.DB) *gormigrate.Gormigrate {
+	type InferenceTask struct {
+		Creator string `json:"creator" gorm:"type:string;size:191;index"`
+	}
+
+	return gormigrate.New(db, gormigrate.DefaultOptions, []*gormigrate.Migration{
+		{
+			ID: "M20250814",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Migrator().AlterColumn(&InferenceTask{}, "Creator"); err != nil {
+					return err
+				}
+				return tx.Migrator().CreateIndex(&InferenceTask{}, "Creator")
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropIndex(&InferenceTask{}, "Creator")
+			},
+		},
+	})
+}
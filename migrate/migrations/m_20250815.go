@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func M20250815(db *gorm.DB) *gormigrate.Gormigrate {
+	type Lease struct {
+		gorm.Model
+		Name      string    `json:"name" gorm:"size:191;uniqueIndex"`
+		Holder    string    `json:"holder"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	return gormigrate.New(db, gormigrate.DefaultOptions, []*gormigrate.Migration{
+		{
+			ID: "M20250815",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.Migrator().CreateTable(&Lease{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&Lease{})
+			},
+		},
+	})
+}
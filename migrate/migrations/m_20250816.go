@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func M20250816(db *gorm.DB) *gormigrate.Gormigrate {
+	type InferenceTask struct {
+		NodeRequirement string `json:"node_requirement" gorm:"type:text"`
+	}
+
+	type NodeHardware struct {
+		gorm.Model
+		NodeAddress   string      `json:"node_address" gorm:"size:191;uniqueIndex"`
+		Vendor        string      `json:"vendor"`
+		GPUModel      string      `json:"gpu_model"`
+		VRAM          uint64      `json:"vram"`
+		GPUCount      uint64      `json:"gpu_count"`
+		Platform      string      `json:"platform"`
+		DriverVersion string      `json:"driver_version"`
+		CUDAVersion   string      `json:"cuda_version"`
+		Precisions    StringArray `json:"precisions" gorm:"type:text"`
+		DiskSpace     uint64      `json:"disk_space"`
+		TaskTypes     StringArray `json:"task_types" gorm:"type:text"`
+	}
+
+	return gormigrate.New(db, gormigrate.DefaultOptions, []*gormigrate.Migration{
+		{
+			ID: "M20250816",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Migrator().AddColumn(&InferenceTask{}, "NodeRequirement"); err != nil {
+					return err
+				}
+				return tx.Migrator().CreateTable(&NodeHardware{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropTable(&NodeHardware{}); err != nil {
+					return err
+				}
+				return tx.Migrator().DropColumn(&InferenceTask{}, "NodeRequirement")
+			},
+		},
+	})
+}
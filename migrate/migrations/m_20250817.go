@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func M20250817(db *gorm.DB) *gormigrate.Gormigrate {
+	type Node struct {
+		QOSScorePool StringArray `json:"-" gorm:"type:text"`
+	}
+
+	return gormigrate.New(db, gormigrate.DefaultOptions, []*gormigrate.Migration{
+		{
+			ID: "M20250817",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.Migrator().AddColumn(&Node{}, "QOSScorePool")
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropColumn(&Node{}, "QOSScorePool")
+			},
+		},
+	})
+}
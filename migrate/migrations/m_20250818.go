@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func M20250818(db *gorm.DB) *gormigrate.Gormigrate {
+	type Node struct {
+		FirstJoinTime sql.NullTime `json:"first_join_time" gorm:"null;default:null"`
+	}
+
+	return gormigrate.New(db, gormigrate.DefaultOptions, []*gormigrate.Migration{
+		{
+			ID: "M20250818",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Migrator().AddColumn(&Node{}, "FirstJoinTime"); err != nil {
+					return err
+				}
+				// the node row is created when the node joins for the first time
+				return tx.Exec("UPDATE nodes SET first_join_time = created_at WHERE first_join_time IS NULL").Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropColumn(&Node{}, "FirstJoinTime")
+			},
+		},
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
 	"strings"
 )
@@ -19,6 +20,15 @@ func (i *BigInt) Scan(val interface{}) error {
 		intStr = v
 	case []byte:
 		intStr = string(v)
+	// sqlite returns numeric expressions, like casts to decimal, as integers or floats
+	case int64:
+		intStr = fmt.Sprint(v)
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) || v != math.Trunc(v) {
+			return errors.New(fmt.Sprint("Unable to convert BigInt value to integer: ", v))
+		}
+		big.NewFloat(v).Int(&i.Int)
+		return nil
 	case nil:
 		return nil
 	default:
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type CanaryTaskStatus uint8
+
+const (
+	CanaryTaskPending CanaryTaskStatus = iota
+	// the result of the node matches the reference score
+	CanaryTaskPassed
+	// the result of the node mismatches the reference score, or the node reported an error
+	CanaryTaskFailed
+	// the task is aborted before the node reports its result, e.g. it times out
+	CanaryTaskAborted
+)
+
+var ErrCanaryTaskStatusChanged = errors.New("canary task status changed")
+
+// CanaryTask keeps the reference score of a canary task issued by the relay, and how the node did on it
+type CanaryTask struct {
+	gorm.Model
+	TaskIDCommitment string           `json:"task_id_commitment" gorm:"size:191;uniqueIndex"`
+	SelectedNode     string           `json:"selected_node" gorm:"index"`
+	ReferenceScore   string           `json:"reference_score" gorm:"type:text"`
+	Status           CanaryTaskStatus `json:"status" gorm:"index"`
+	ResolvedTime     sql.NullTime     `json:"resolved_time" gorm:"null;default:null"`
+}
+
+func (canary *CanaryTask) Create(ctx context.Context, db *gorm.DB) error {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return db.WithContext(dbCtx).Create(canary).Error
+}
+
+// Update updates the canary task only if its status is not changed by others
+func (canary *CanaryTask) Update(ctx context.Context, db *gorm.DB, values map[string]interface{}) error {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result := db.WithContext(dbCtx).Model(canary).Where("status = ?", canary.Status).Updates(values)
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return ErrCanaryTaskStatusChanged
+	}
+	return nil
+}
+
+func GetPendingCanaryTasks(ctx context.Context, db *gorm.DB, startID uint, limit int) ([]CanaryTask, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var canaries []CanaryTask
+	if err := db.WithContext(dbCtx).Model(&CanaryTask{}).
+		Where("status = ?", CanaryTaskPending).
+		Where("id > ?", startID).
+		Order("id").
+		Limit(limit).
+		Find(&canaries).Error; err != nil {
+		return nil, err
+	}
+	return canaries, nil
+}
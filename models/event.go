@@ -245,3 +245,143 @@ func (e *NodeSlashedEvent) ToEvent() (*Event, error) {
 		Args:        string(bs),
 	}, nil
 }
+
+type TaskFeeIncreasedEvent struct {
+	TaskIDCommitment string `json:"task_id_commitment"`
+	Creator          string `json:"creator"`
+	Amount           BigInt `json:"amount"`
+	TaskFee          BigInt `json:"task_fee"`
+}
+
+func (e *TaskFeeIncreasedEvent) ToEvent() (*Event, error) {
+	bs, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return &Event{
+		Type:             "TaskFeeIncreased",
+		TaskIDCommitment: e.TaskIDCommitment,
+		Args:             string(bs),
+	}, nil
+}
+
+type TaskQueuedEvent struct {
+	TaskIDCommitment string `json:"task_id_commitment"`
+}
+
+func (e *TaskQueuedEvent) ToEvent() (*Event, error) {
+	bs, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return &Event{
+		Type:             "TaskQueued",
+		TaskIDCommitment: e.TaskIDCommitment,
+		Args:             string(bs),
+	}, nil
+}
+
+type TaskProgressEvent struct {
+	TaskIDCommitment string `json:"task_id_commitment"`
+	SelectedNode     string `json:"selected_node"`
+	Percent          uint64 `json:"percent"`
+	Step             uint64 `json:"step"`
+	Stage            string `json:"stage"`
+}
+
+func (e *TaskProgressEvent) ToEvent() (*Event, error) {
+	bs, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return &Event{
+		Type:             "TaskProgress",
+		TaskIDCommitment: e.TaskIDCommitment,
+		NodeAddress:      e.SelectedNode,
+		Args:             string(bs),
+	}, nil
+}
+
+type TaskRequeuedEvent struct {
+	TaskIDCommitment string          `json:"task_id_commitment"`
+	SelectedNode     string          `json:"selected_node"`
+	AbortReason      TaskAbortReason `json:"abort_reason"`
+	Attempts         uint64          `json:"attempts"`
+}
+
+func (e *TaskRequeuedEvent) ToEvent() (*Event, error) {
+	bs, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return &Event{
+		Type:             "TaskRequeued",
+		TaskIDCommitment: e.TaskIDCommitment,
+		NodeAddress:      e.SelectedNode,
+		Args:             string(bs),
+	}, nil
+}
+
+type TaskDisputeOpenedEvent struct {
+	TaskIDCommitment              string   `json:"task_id_commitment"`
+	Creator                       string   `json:"creator"`
+	SelectedNode                  string   `json:"selected_node"`
+	Deposit                       BigInt   `json:"deposit"`
+	VerificationTaskIDCommitments []string `json:"verification_task_id_commitments"`
+}
+
+func (e *TaskDisputeOpenedEvent) ToEvent() (*Event, error) {
+	bs, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return &Event{
+		Type:             "TaskDisputeOpened",
+		TaskIDCommitment: e.TaskIDCommitment,
+		NodeAddress:      e.SelectedNode,
+		Args:             string(bs),
+	}, nil
+}
+
+type TaskDisputeVerifiedEvent struct {
+	TaskIDCommitment             string     `json:"task_id_commitment"`
+	VerificationTaskIDCommitment string     `json:"verification_task_id_commitment"`
+	VerificationNode             string     `json:"verification_node"`
+	VerificationStatus           TaskStatus `json:"verification_status"`
+	Same                         bool       `json:"same"`
+	Distances                    []uint64   `json:"distances"`
+}
+
+func (e *TaskDisputeVerifiedEvent) ToEvent() (*Event, error) {
+	bs, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return &Event{
+		Type:             "TaskDisputeVerified",
+		TaskIDCommitment: e.TaskIDCommitment,
+		NodeAddress:      e.VerificationNode,
+		Args:             string(bs),
+	}, nil
+}
+
+type TaskDisputeResolvedEvent struct {
+	TaskIDCommitment string            `json:"task_id_commitment"`
+	SelectedNode     string            `json:"selected_node"`
+	Status           TaskDisputeStatus `json:"status"`
+	ClawbackAmount   BigInt            `json:"clawback_amount"`
+	DepositReceiver  string            `json:"deposit_receiver"`
+}
+
+func (e *TaskDisputeResolvedEvent) ToEvent() (*Event, error) {
+	bs, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return &Event{
+		Type:             "TaskDisputeResolved",
+		TaskIDCommitment: e.TaskIDCommitment,
+		NodeAddress:      e.SelectedNode,
+		Args:             string(bs),
+	}, nil
+}
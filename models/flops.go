@@ -1,6 +1,10 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
 
 type NetworkFLOPS struct {
 	gorm.Model
@@ -103,8 +107,57 @@ var gpuGFLOPSMap map[string]float64 = map[string]float64{
 }
 
 
-func GetGPUGFLOPS(gpuName string) float64 {
-	if gflops, ok := gpuGFLOPSMap[gpuName]; ok {
+var gpuVendors = []string{"nvidia", "amd", "apple"}
+
+// gpuNameIgnoredWords are the words of gpu names which are not part of the gpu model
+var gpuNameIgnoredWords = map[string]bool{"geforce": true, "gpu": true, "type": true}
+
+var gpuNameReplacer = strings.NewReplacer("(r)", " ", "(tm)", " ")
+
+// gpuVendor returns the vendor in the prefix of the name, or empty if the vendor is unknown
+func gpuVendor(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, v := range gpuVendors {
+		if strings.HasPrefix(name, v) {
+			return v
+		}
+	}
+	return ""
+}
+
+// normalizeGPUName makes the lookup key of a gpu from its vendor and model, so that
+// "NVIDIA GeForce RTX 4090+Linux", "GeForce RTX 4090" of nvidia and "nvidia rtx 4090" are the same gpu.
+// The vendor is taken from the model when it is not given.
+func normalizeGPUName(vendor, model string) string {
+	model, _, _ = strings.Cut(model, "+")
+	if v := gpuVendor(vendor); len(v) > 0 {
+		vendor = v
+	} else {
+		vendor = gpuVendor(model)
+	}
+	words := strings.Fields(gpuNameReplacer.Replace(strings.ToLower(model)))
+	modelWords := make([]string, 0, len(words))
+	for _, word := range words {
+		if word == vendor || gpuNameIgnoredWords[word] {
+			continue
+		}
+		modelWords = append(modelWords, word)
+	}
+	return vendor + "/" + strings.Join(modelWords, " ")
+}
+
+var normalizedGPUGFLOPSMap = func() map[string]float64 {
+	res := make(map[string]float64, len(gpuGFLOPSMap))
+	for name, gflops := range gpuGFLOPSMap {
+		res[normalizeGPUName("", name)] = gflops
+	}
+	return res
+}()
+
+// GetGPUGFLOPS returns the gflops of the gpu by its vendor and model. The vendor can be empty
+// if the model starts with it, like the gpu names reported by nodes.
+func GetGPUGFLOPS(vendor, gpuModel string) float64 {
+	if gflops, ok := normalizedGPUGFLOPSMap[normalizeGPUName(vendor, gpuModel)]; ok {
 		return gflops
 	} else {
 		return 10 * 1024
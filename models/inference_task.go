@@ -2,11 +2,14 @@ package models
 
 import (
 	"context"
+	"crynux_relay/utils"
 	"database/sql"
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math/big"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -34,6 +37,7 @@ const (
 	TaskEndAborted
 	TaskEndGroupRefund
 	TaskEndGroupSuccess
+	TaskWaiting
 )
 
 type TaskType uint8
@@ -52,6 +56,9 @@ const (
 	TaskAbortModelDownloadFailed
 	TaskAbortIncorrectResult
 	TaskAbortTaskFeeTooLow
+	TaskAbortCancelledByCreator
+	TaskAbortParentFailed
+	TaskAbortNodeSlashed
 )
 
 type TaskError uint8
@@ -61,6 +68,36 @@ const (
 	TaskErrorParametersValidationFailed
 )
 
+func (reason TaskAbortReason) String() string {
+	switch reason {
+	case TaskAbortTimeout:
+		return "timeout"
+	case TaskAbortModelDownloadFailed:
+		return "model_download_failed"
+	case TaskAbortIncorrectResult:
+		return "incorrect_result"
+	case TaskAbortTaskFeeTooLow:
+		return "task_fee_too_low"
+	case TaskAbortCancelledByCreator:
+		return "cancelled_by_creator"
+	case TaskAbortParentFailed:
+		return "parent_failed"
+	case TaskAbortNodeSlashed:
+		return "node_slashed"
+	default:
+		return ""
+	}
+}
+
+func (taskError TaskError) String() string {
+	switch taskError {
+	case TaskErrorParametersValidationFailed:
+		return "parameters_validation_failed"
+	default:
+		return ""
+	}
+}
+
 type StringArray []string
 
 func (arr *StringArray) Scan(val interface{}) error {
@@ -75,6 +112,11 @@ func (arr *StringArray) Scan(val interface{}) error {
 	default:
 		return errors.New(fmt.Sprint("Unable to parse value to StringArray: ", val))
 	}
+	// an empty array is stored as an empty string
+	if len(arrString) == 0 {
+		*arr = StringArray{}
+		return nil
+	}
 	*arr = strings.Split(arrString, ";")
 	return nil
 }
@@ -96,7 +138,7 @@ type InferenceTask struct {
 	gorm.Model
 	TaskArgs         string          `json:"task_args"`
 	TaskIDCommitment string          `json:"task_id_commitment" gorm:"index"`
-	Creator          string          `json:"creator"`
+	Creator          string          `json:"creator" gorm:"type:string;size:191;index"`
 	SamplingSeed     string          `json:"sampling_seed"`
 	Nonce            string          `json:"nonce"`
 	Status           TaskStatus      `json:"status"`
@@ -116,8 +158,26 @@ type InferenceTask struct {
 	SelectedNode     string          `json:"selected_node"`
 	TaskID           string          `json:"task_id"`
 	ModelSwtiched    bool            `json:"model_swtiched"`
+	// the task is requeued when it fails by the fault of the selected node, until the attempts reach max attempts
+	MaxAttempts uint64 `json:"max_attempts" gorm:"default:1"`
+	Attempts    uint64 `json:"attempts"`
+	// validator and threshold used to compare the task score with the other tasks in its group, decided when the task is created
+	Validator          string  `json:"validator"`
+	ValidatorThreshold float64 `json:"validator_threshold"`
+	// requirement expression on the hardware of the selected node, like "platform != darwin && cuda >= 12"
+	NodeRequirement string `json:"node_requirement" gorm:"type:text"`
+	// the disputed task which this task reruns to verify
+	DisputeTaskIDCommitment string `json:"dispute_task_id_commitment" gorm:"index"`
+	// the task which this task reruns to spot check its node
+	SpotCheckTaskIDCommitment string `json:"spot_check_task_id_commitment" gorm:"index"`
+	// canary tasks are issued by the relay to check the nodes, and excluded from the stats
+	Canary bool `json:"canary" gorm:"index;default:false"`
 	// time when task is created (get from blockchain)
 	CreateTime sql.NullTime `json:"create_time" gorm:"index;null;default:null"`
+	// time before which the task should not be dispatched, set by the creator
+	NotBefore sql.NullTime `json:"not_before" gorm:"index;null;default:null"`
+	// time when task is put into the queue, only set for tasks released from waiting
+	QueuedTime sql.NullTime `json:"queued_time" gorm:"null;default:null"`
 	// time when task is started (get from blockchain)
 	StartTime sql.NullTime `json:"start_time" gorm:"index;null;default:null"`
 	// time when task score is ready (get from blockchain)
@@ -126,6 +186,15 @@ type InferenceTask struct {
 	ValidatedTime sql.NullTime `json:"validated_time" gorm:"index;null;default:null"`
 	// time when relay report task results are uploaded
 	ResultUploadedTime sql.NullTime `json:"result_uploaded_time" gorm:"index;null;default:null"`
+	// latest progress reported by the selected node
+	ProgressPercent     uint64       `json:"progress_percent"`
+	ProgressStep        uint64       `json:"progress_step"`
+	ProgressStage       string       `json:"progress_stage"`
+	ProgressUpdatedTime sql.NullTime `json:"progress_updated_time" gorm:"null;default:null"`
+	// time before which the task data will not be purged, set by the creator
+	RetainUntil sql.NullTime `json:"retain_until" gorm:"null;default:null"`
+	// time when task data is purged by the retention policy
+	ResultsPurgedAt sql.NullTime `json:"results_purged_at" gorm:"index;null;default:null"`
 }
 
 func (task *InferenceTask) VersionNumbers() [3]uint64 {
@@ -155,10 +224,25 @@ func (task *InferenceTask) SyncStatus(ctx context.Context, db *gorm.DB) error {
 	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 	var res InferenceTask
-	if err := db.WithContext(dbCtx).Model(task).Select("status").First(&res, task.ID).Error; err != nil {
+	if err := db.WithContext(dbCtx).Model(task).Select("status", "attempts").First(&res, task.ID).Error; err != nil {
 		return err
 	}
 	task.Status = res.Status
+	task.Attempts = res.Attempts
+	return nil
+}
+
+func (task *InferenceTask) SyncTaskFee(ctx context.Context, db *gorm.DB) error {
+	if task.ID == 0 {
+		return ErrTaskIDEmpty
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var res InferenceTask
+	if err := db.WithContext(dbCtx).Model(task).Select("task_fee").First(&res, task.ID).Error; err != nil {
+		return err
+	}
+	task.TaskFee = res.TaskFee
 	return nil
 }
 
@@ -213,6 +297,41 @@ func GetTaskGroupByTaskID(ctx context.Context, db *gorm.DB, taskID string) ([]In
 	return tasks, nil
 }
 
+func GetTasksByIDCommitments(ctx context.Context, db *gorm.DB, taskIDCommitments []string) ([]InferenceTask, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var tasks []InferenceTask
+	if err := db.WithContext(dbCtx).Model(&InferenceTask{}).Where("task_id_commitment IN ?", taskIDCommitments).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// QueueStartTime returns the time from which the task waits in the queue for a node
+func (task *InferenceTask) QueueStartTime() time.Time {
+	t := task.CreateTime.Time
+	if task.QueuedTime.Valid && task.QueuedTime.Time.After(t) {
+		t = task.QueuedTime.Time
+	}
+	if task.NotBefore.Valid && task.NotBefore.Time.After(t) {
+		t = task.NotBefore.Time
+	}
+	return t
+}
+
+func (task *InferenceTask) IsEnded() bool {
+	switch task.Status {
+	case TaskEndSuccess, TaskEndGroupSuccess, TaskEndAborted, TaskEndInvalidated, TaskEndGroupRefund:
+		return true
+	}
+	return false
+}
+
+// IsScheduled returns whether the task is queued but not yet eligible for dispatch
+func (task *InferenceTask) IsScheduled() bool {
+	return task.Status == TaskQueued && task.NotBefore.Valid && task.NotBefore.Time.After(utils.Now())
+}
+
 func (task *InferenceTask) ExecutionTime() time.Duration {
 	if task.StartTime.Valid && task.ScoreReadyTime.Valid {
 		return task.ScoreReadyTime.Time.Sub(task.StartTime.Time)
@@ -232,7 +351,11 @@ func GetTotalTaskCount(ctx context.Context, db *gorm.DB) (int64, error) {
 	if err := db.WithContext(dbCtx).Model(&InferenceTask{}).Select("max(id) as count").First(&res).Error; err != nil {
 		return 0, err
 	}
-	return res.Count, nil
+	var canaryCount int64
+	if err := db.WithContext(dbCtx).Model(&InferenceTask{}).Where("canary = ?", true).Count(&canaryCount).Error; err != nil {
+		return 0, err
+	}
+	return res.Count - canaryCount, nil
 }
 
 func GetRunningTaskCount(ctx context.Context, db *gorm.DB) (int64, error) {
@@ -242,6 +365,7 @@ func GetRunningTaskCount(ctx context.Context, db *gorm.DB) (int64, error) {
 	var res int64
 	if err := db.WithContext(dbCtx).Model(&InferenceTask{}).
 		Where("status IN ?", []TaskStatus{TaskStarted, TaskParametersUploaded, TaskErrorReported, TaskScoreReady, TaskValidated, TaskGroupValidated}).
+		Where("canary = ?", false).
 		Count(&res).Error; err != nil {
 		return 0, err
 	}
@@ -253,8 +377,160 @@ func GetQueuedTaskCount(ctx context.Context, db *gorm.DB) (int64, error) {
 	defer cancel()
 
 	var res int64
-	if err := db.WithContext(dbCtx).Model(&InferenceTask{}).Where("status = ?", TaskQueued).Count(&res).Error; err != nil {
+	if err := db.WithContext(dbCtx).Model(&InferenceTask{}).Where("status = ?", TaskQueued).Where("canary = ?", false).Count(&res).Error; err != nil {
 		return 0, err
 	}
 	return res, nil
 }
+
+// GetNodeInvalidatedTaskCount returns the number of tasks of the node invalidated since the time
+func GetNodeInvalidatedTaskCount(ctx context.Context, db *gorm.DB, address string, since time.Time) (int64, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var count int64
+	if err := db.WithContext(dbCtx).Model(&InferenceTask{}).
+		Where("selected_node = ?", address).
+		Where("status = ?", TaskEndInvalidated).
+		Where("validated_time >= ?", since).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetInvalidatedNodes returns the nodes among the addresses which have tasks invalidated since the time, in one grouped query
+func GetInvalidatedNodes(ctx context.Context, db *gorm.DB, addresses []string, since time.Time) (map[string]bool, error) {
+	res := make(map[string]bool)
+	if len(addresses) == 0 {
+		return res, nil
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var nodes []string
+	if err := db.WithContext(dbCtx).Model(&InferenceTask{}).
+		Where("selected_node IN ?", addresses).
+		Where("status = ?", TaskEndInvalidated).
+		Where("validated_time >= ?", since).
+		Group("selected_node").
+		Pluck("selected_node", &nodes).Error; err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		res[node] = true
+	}
+	return res, nil
+}
+
+// CreatorTaskFilter filters the tasks of a creator, unset fields are not filtered on
+type CreatorTaskFilter struct {
+	Statuses  []TaskStatus
+	TaskType  *TaskType
+	StartTime *time.Time
+	EndTime   *time.Time
+	ModelID   string
+	MinFee    *big.Int
+	MaxFee    *big.Int
+}
+
+// escapeLike escapes the wildcards of s for a LIKE pattern with ! as the escape character
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+func filterCreatorTasks(db *gorm.DB, creator string, filter *CreatorTaskFilter) *gorm.DB {
+	stmt := db.Model(&InferenceTask{}).Where("creator = ?", creator)
+	if len(filter.Statuses) > 0 {
+		stmt = stmt.Where("status IN ?", filter.Statuses)
+	}
+	if filter.TaskType != nil {
+		stmt = stmt.Where("task_type = ?", *filter.TaskType)
+	}
+	if filter.StartTime != nil {
+		stmt = stmt.Where("create_time >= ?", *filter.StartTime)
+	}
+	if filter.EndTime != nil {
+		stmt = stmt.Where("create_time < ?", *filter.EndTime)
+	}
+	if len(filter.ModelID) > 0 {
+		// model ids are stored joined by ;
+		modelID := escapeLike(filter.ModelID)
+		stmt = stmt.Where(
+			"(model_ids = ? OR model_ids LIKE ? ESCAPE '!' OR model_ids LIKE ? ESCAPE '!' OR model_ids LIKE ? ESCAPE '!')",
+			filter.ModelID, modelID+";%", "%;"+modelID, "%;"+modelID+";%",
+		)
+	}
+	// task fees are stored as decimal strings without leading zeros, so they are compared by length first,
+	// which is exact for any fee and on any database, unlike casting to a numeric type
+	if filter.MinFee != nil {
+		minFee := filter.MinFee.String()
+		stmt = stmt.Where("(LENGTH(task_fee) > ? OR (LENGTH(task_fee) = ? AND task_fee >= ?))", len(minFee), len(minFee), minFee)
+	}
+	if filter.MaxFee != nil {
+		maxFee := filter.MaxFee.String()
+		stmt = stmt.Where("(LENGTH(task_fee) < ? OR (LENGTH(task_fee) = ? AND task_fee <= ?))", len(maxFee), len(maxFee), maxFee)
+	}
+	return stmt
+}
+
+// GetCreatorTasks returns at most limit tasks of the creator matching the filter, newest first.
+// Only tasks with id less than the cursor are returned if the cursor is not 0.
+func GetCreatorTasks(ctx context.Context, db *gorm.DB, creator string, filter *CreatorTaskFilter, cursor uint, limit int) ([]InferenceTask, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	stmt := filterCreatorTasks(db.WithContext(dbCtx), creator, filter)
+	if cursor > 0 {
+		stmt = stmt.Where("id < ?", cursor)
+	}
+	var tasks []InferenceTask
+	if err := stmt.Order("id DESC").Limit(limit).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+type TaskStatusSummary struct {
+	Status   TaskStatus
+	Count    int64
+	TotalFee *big.Int
+}
+
+// GetCreatorTaskSummary returns the count and the total fee of the tasks of the creator matching the filter by status.
+// The fees are summed up in big integers, as the sums may overflow the numeric types of some databases.
+func GetCreatorTaskSummary(ctx context.Context, db *gorm.DB, creator string, filter *CreatorTaskFilter) ([]TaskStatusSummary, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := filterCreatorTasks(db.WithContext(dbCtx), creator, filter).
+		Select("status, task_fee").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := make([]TaskStatusSummary, 0)
+	indices := make(map[TaskStatus]int)
+	for rows.Next() {
+		var status TaskStatus
+		var taskFee BigInt
+		if err := rows.Scan(&status, &taskFee); err != nil {
+			return nil, err
+		}
+		index, ok := indices[status]
+		if !ok {
+			index = len(summaries)
+			indices[status] = index
+			summaries = append(summaries, TaskStatusSummary{Status: status, TotalFee: big.NewInt(0)})
+		}
+		summaries[index].Count++
+		summaries[index].TotalFee.Add(summaries[index].TotalFee, &taskFee.Int)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Status < summaries[j].Status
+	})
+	return summaries, nil
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Lease is held by one relay instance at a time until it expires, unless the holder renews it
+type Lease struct {
+	gorm.Model
+	Name      string    `json:"name" gorm:"size:191;uniqueIndex"`
+	Holder    string    `json:"holder"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// dbNow is the current time of the database in milliseconds. Leases are compared and expired by the database time only,
+// so that they do not depend on the clocks of the relay instances.
+func dbNow(db *gorm.DB) clause.Expr {
+	return dbTimeAfter(db, 0)
+}
+
+// dbTimeAfter is the time of the database after d
+func dbTimeAfter(db *gorm.DB, d time.Duration) clause.Expr {
+	if db.Dialector.Name() == "sqlite" {
+		return gorm.Expr("strftime('%Y-%m-%d %H:%M:%f', 'now', ?)", fmt.Sprintf("%+.3f seconds", d.Seconds()))
+	}
+	return gorm.Expr("DATE_ADD(CURRENT_TIMESTAMP(3), INTERVAL ? MICROSECOND)", d.Microseconds())
+}
+
+// AcquireLease takes or renews the lease for the holder for the ttl.
+// It succeeds if the lease does not exist, is held by the holder or has expired.
+func AcquireLease(ctx context.Context, db *gorm.DB, name, holder string, ttl time.Duration) (bool, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// a new lease is created expired, and is taken by the update below like an expired one
+	lease := &Lease{
+		Name:      name,
+		Holder:    holder,
+		ExpiresAt: time.Unix(0, 0).UTC(),
+	}
+	if err := db.WithContext(dbCtx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoNothing: true,
+	}).Create(lease).Error; err != nil {
+		return false, err
+	}
+
+	res := db.WithContext(dbCtx).Model(&Lease{}).
+		Where("name = ?", name).
+		Where("holder = ? OR expires_at < ?", holder, dbNow(db)).
+		Updates(map[string]interface{}{
+			"holder":     holder,
+			"expires_at": dbTimeAfter(db, ttl),
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// ReleaseLease expires the lease if it is held by the holder, so that others can take it at once
+func ReleaseLease(ctx context.Context, db *gorm.DB, name, holder string) error {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return db.WithContext(dbCtx).Model(&Lease{}).
+		Where("name = ?", name).
+		Where("holder = ?", holder).
+		Update("expires_at", dbNow(db)).Error
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruCache is a cache of a bounded size which evicts the least recently used entry when full
+type lruCache[V any] struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+}
+
+type lruEntry[V any] struct {
+	key   string
+	value V
+}
+
+func newLRUCache[V any](size int) *lruCache[V] {
+	return &lruCache[V]{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *lruCache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.order.MoveToFront(elem)
+		return elem.Value.(*lruEntry[V]).value, true
+	}
+	var zero V
+	return zero, false
+}
+
+func (c *lruCache[V]) Add(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		elem.Value.(*lruEntry[V]).value = value
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry[V]{key: key, value: value})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry[V]).key)
+	}
+}
+
+func (c *lruCache[V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.items = make(map[string]*list.Element)
+}
@@ -29,10 +29,12 @@ type Node struct {
 	GPUName                 string         `json:"gpu_name" gorm:"index"`
 	GPUVram                 uint64         `json:"gpu_vram" gorm:"index"`
 	QOSScore                float64        `json:"qos_score"`
+	QOSScorePool            StringArray    `json:"-" gorm:"type:text"`
 	MajorVersion            uint64         `json:"major_version"`
 	MinorVersion            uint64         `json:"minor_version"`
 	PatchVersion            uint64         `json:"patch_version"`
 	JoinTime                time.Time      `json:"join_time"`
+	FirstJoinTime           sql.NullTime   `json:"first_join_time" gorm:"null;default:null"`
 	StakeAmount             BigInt         `json:"stake_amount"`
 	CurrentTaskIDCommitment sql.NullString `json:"current_task_id_commitment" gorm:"null;default:null"`
 	CurrentTask             InferenceTask  `json:"-" gorm:"foreignKey:TaskIDCommitment;references:CurrentTaskIDCommitment"`
@@ -0,0 +1,166 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NodeHardware is the hardware profile of a node, reported when the node joins
+type NodeHardware struct {
+	gorm.Model
+	NodeAddress string `json:"node_address" gorm:"size:191;uniqueIndex"`
+	// gpu vendor in lower case, like nvidia, amd or apple
+	Vendor   string `json:"vendor"`
+	GPUModel string `json:"gpu_model"`
+	// vram of each gpu, in GB
+	VRAM     uint64 `json:"vram"`
+	GPUCount uint64 `json:"gpu_count"`
+	// operating system in lower case, like linux, windows or darwin
+	Platform      string `json:"platform"`
+	DriverVersion string `json:"driver_version"`
+	// empty when cuda is not available
+	CUDAVersion string      `json:"cuda_version"`
+	Precisions  StringArray `json:"precisions" gorm:"type:text"`
+	// free disk space, in GB
+	DiskSpace uint64 `json:"disk_space"`
+	// names of the task types the node is able to run, empty means all task types
+	TaskTypes StringArray `json:"task_types" gorm:"type:text"`
+}
+
+func (hardware *NodeHardware) SupportsTaskType(taskType TaskType) bool {
+	if len(hardware.TaskTypes) == 0 {
+		return true
+	}
+	spec, ok := GetTaskTypeSpec(taskType)
+	if !ok {
+		return false
+	}
+	for _, name := range hardware.TaskTypes {
+		if name == spec.Name {
+			return true
+		}
+	}
+	return false
+}
+
+func (hardware *NodeHardware) GFLOPS() float64 {
+	count := hardware.GPUCount
+	if count == 0 {
+		count = 1
+	}
+	if count > MaxNodeGPUCount {
+		count = MaxNodeGPUCount
+	}
+	return GetGPUGFLOPS(hardware.Vendor, hardware.GPUModel) * float64(count)
+}
+
+// MaxNodeGPUCount is the max number of gpus a node can report. The count is not verified,
+// so it is bounded to keep a node from claiming the flops of the whole network.
+const MaxNodeGPUCount = 8
+
+// NodeHardwareInput is the hardware profile reported by the node when it joins.
+// The profile of nodes not reporting one is made from the gpu name.
+type NodeHardwareInput struct {
+	Vendor        string   `json:"vendor" description:"gpu vendor, like nvidia, amd or apple"`
+	Model         string   `json:"model" description:"gpu model"`
+	VRAM          uint64   `json:"vram" description:"vram of each gpu, in GB"`
+	Count         uint64   `json:"count" description:"number of gpus, 1 by default, at most 8"`
+	Platform      string   `json:"platform" description:"operating system, like linux, windows or darwin"`
+	DriverVersion string   `json:"driver_version" description:"gpu driver version"`
+	CUDAVersion   string   `json:"cuda_version" description:"cuda version, empty if cuda is not available"`
+	Precisions    []string `json:"precisions" description:"supported precisions, like fp32, fp16 or bf16"`
+	DiskSpace     uint64   `json:"disk_space" description:"free disk space, in GB"`
+	TaskTypes     []uint64 `json:"task_types" description:"supported task types, all task types if empty"`
+}
+
+// NodeHardwareInputError tells which field of the hardware input is invalid
+type NodeHardwareInputError struct {
+	Field   string
+	Message string
+}
+
+func (e *NodeHardwareInputError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+func NewNodeHardware(address string, in *NodeHardwareInput) (*NodeHardware, error) {
+	if len(in.Model) == 0 {
+		return nil, &NodeHardwareInputError{Field: "model", Message: "Gpu model is required"}
+	}
+	if len(in.Platform) == 0 {
+		return nil, &NodeHardwareInputError{Field: "platform", Message: "Platform is required"}
+	}
+	count := in.Count
+	if count == 0 {
+		count = 1
+	}
+	if count > MaxNodeGPUCount {
+		return nil, &NodeHardwareInputError{Field: "count", Message: "Too many gpus"}
+	}
+	precisions := make([]string, len(in.Precisions))
+	for i, precision := range in.Precisions {
+		precisions[i] = strings.ToLower(precision)
+	}
+	taskTypes := make([]string, len(in.TaskTypes))
+	for i, taskType := range in.TaskTypes {
+		spec, ok := GetTaskTypeSpec(TaskType(taskType))
+		if taskType > 255 || !ok {
+			return nil, &NodeHardwareInputError{Field: "task_types", Message: "Unsupported task type"}
+		}
+		taskTypes[i] = spec.Name
+	}
+	return &NodeHardware{
+		NodeAddress:   address,
+		Vendor:        strings.ToLower(in.Vendor),
+		GPUModel:      in.Model,
+		VRAM:          in.VRAM,
+		GPUCount:      count,
+		Platform:      strings.ToLower(in.Platform),
+		DriverVersion: in.DriverVersion,
+		CUDAVersion:   in.CUDAVersion,
+		Precisions:    precisions,
+		DiskSpace:     in.DiskSpace,
+		TaskTypes:     taskTypes,
+	}, nil
+}
+
+// NewNodeHardwareFromGPUName makes the hardware profile of a node which does not report one,
+// from its gpu name in the form of "model+Platform"
+func NewNodeHardwareFromGPUName(address, gpuName string, gpuVram uint64) *NodeHardware {
+	gpuModel, platform, _ := strings.Cut(gpuName, "+")
+	gpuModel = strings.TrimSpace(gpuModel)
+
+	vendor := gpuVendor(gpuModel)
+	return &NodeHardware{
+		NodeAddress: address,
+		Vendor:      vendor,
+		GPUModel:    gpuModel,
+		VRAM:        gpuVram,
+		GPUCount:    1,
+		Platform:    strings.ToLower(strings.TrimSpace(platform)),
+	}
+}
+
+// GetNodeHardwares returns the hardware profiles of the nodes by their addresses.
+// Nodes joined before hardware profiles are reported have no profile in the result.
+func GetNodeHardwares(ctx context.Context, db *gorm.DB, addresses []string) (map[string]*NodeHardware, error) {
+	res := make(map[string]*NodeHardware)
+	if len(addresses) == 0 {
+		return res, nil
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var hardwares []NodeHardware
+	if err := db.WithContext(dbCtx).Model(&NodeHardware{}).Where("node_address IN ?", addresses).Find(&hardwares).Error; err != nil {
+		return nil, err
+	}
+	for i := range hardwares {
+		res[hardwares[i].NodeAddress] = &hardwares[i]
+	}
+	return res, nil
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SpotCheckStatus uint8
+
+const (
+	SpotCheckPending SpotCheckStatus = iota
+	// the check task agrees with the result of the node, the payment is released to the node
+	SpotCheckPassed
+	// the check task disagrees with the result of the node, the payment is refunded to the creator
+	SpotCheckFailed
+	// the check task fails to produce a result, the payment is released to the node
+	SpotCheckInconclusive
+)
+
+var ErrSpotCheckStatusChanged = errors.New("spot check status changed")
+
+// SpotCheck holds the payment of a successful task until the task is rerun on a trusted node and the results compared
+type SpotCheck struct {
+	gorm.Model
+	TaskIDCommitment      string          `json:"task_id_commitment" gorm:"size:191;uniqueIndex"`
+	SelectedNode          string          `json:"selected_node" gorm:"index"`
+	CheckTaskIDCommitment string          `json:"check_task_id_commitment" gorm:"index"`
+	Rate                  float64         `json:"rate"`
+	Payment               BigInt          `json:"payment" gorm:"type:string;size:255"`
+	Status                SpotCheckStatus `json:"status" gorm:"index"`
+	ResolvedTime          sql.NullTime    `json:"resolved_time" gorm:"null;default:null"`
+}
+
+func (spotCheck *SpotCheck) Create(ctx context.Context, db *gorm.DB) error {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return db.WithContext(dbCtx).Create(spotCheck).Error
+}
+
+// Update updates the spot check only if its status is not changed by others
+func (spotCheck *SpotCheck) Update(ctx context.Context, db *gorm.DB, values map[string]interface{}) error {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result := db.WithContext(dbCtx).Model(spotCheck).Where("status = ?", spotCheck.Status).Updates(values)
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return ErrSpotCheckStatusChanged
+	}
+	return nil
+}
+
+func GetSpotCheck(ctx context.Context, db *gorm.DB, taskIDCommitment string) (*SpotCheck, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var spotCheck SpotCheck
+	if err := db.WithContext(dbCtx).Model(&SpotCheck{}).Where("task_id_commitment = ?", taskIDCommitment).First(&spotCheck).Error; err != nil {
+		return nil, err
+	}
+	return &spotCheck, nil
+}
+
+func GetPendingSpotChecks(ctx context.Context, db *gorm.DB, startID uint, limit int) ([]SpotCheck, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var spotChecks []SpotCheck
+	if err := db.WithContext(dbCtx).Model(&SpotCheck{}).
+		Where("status = ?", SpotCheckPending).
+		Where("id > ?", startID).
+		Order("id").
+		Limit(limit).
+		Find(&spotChecks).Error; err != nil {
+		return nil, err
+	}
+	return spotChecks, nil
+}
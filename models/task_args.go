@@ -1,107 +1,280 @@
 package models
 
 import (
+	"context"
 	"crynux_relay/config"
 	"encoding/json"
 	"errors"
-	"net/url"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/santhosh-tekuri/jsonschema/v5"
-	_ "github.com/santhosh-tekuri/jsonschema/v5/httploader"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
-var sdInferenceTaskSchema *jsonschema.Schema
-var gptInferenceTaskSchema *jsonschema.Schema
-var sdFinetuneLoraTaskSchema *jsonschema.Schema
+const (
+	TaskSchemaSourceEmbedded = "embedded"
+	TaskSchemaSourceURL      = "url"
+	TaskSchemaSourceFile     = "file"
+	TaskSchemaSourceDatabase = "database"
+)
 
-func ValidateTaskArgsJsonStr(jsonStr string, taskType TaskType) (validationError, err error) {
-	if taskType == TaskTypeSD {
-		return validateSDTaskArgs(jsonStr)
-	} else if taskType == TaskTypeLLM {
-		return validateGPTTaskArgs(jsonStr)
-	} else {
-		return validateSDFinetuneLoraTaskArgs(jsonStr)
-	}
+var (
+	ErrTaskSchemaNotFound  = errors.New("task schema not found")
+	errUnsupportedTaskType = errors.New("unsupported task type")
+)
+
+// Resolved schemas are kept for a while, so that creating tasks does not read the schema directory and the database
+// every time. Schemas uploaded to other relay instances or changed in the directory take effect after the ttl.
+const resolvedTaskSchemaTTL = time.Minute
+
+type resolvedTaskSchemaEntry struct {
+	// nil means no schema applies to the task version
+	schema    *ResolvedTaskSchema
+	expiresAt time.Time
 }
 
-func validateSDTaskArgs(jsonStr string) (validationError, err error) {
-	if sdInferenceTaskSchema == nil {
-		schemaJson := config.GetConfig().TaskSchema.StableDiffusionInference
+var (
+	resolvedTaskSchemas = newLRUCache[*resolvedTaskSchemaEntry](256)
+	compiledTaskSchemas = newLRUCache[*jsonschema.Schema](64)
 
-		if !isValidUrl(schemaJson) {
-			return nil, errors.New("invalid URL for task json schema")
-		}
+	urlTaskSchemasMu sync.Mutex
+	urlTaskSchemas   = make(map[string]string)
+)
 
-		sdInferenceTaskSchema, err = jsonschema.Compile(schemaJson)
+// ResolvedTaskSchema is the task args schema which applies to a task version
+type ResolvedTaskSchema struct {
+	TaskType TaskType
+	Version  string
+	Source   string
+	Content  string
+	// identifies the content of the schema, used to cache the compiled schema
+	cacheKey string
+}
 
-		if err != nil {
-			return nil, err
+type taskSchemaCandidate struct {
+	version [3]uint64
+	source  string
+	load    func() (string, string, error)
+}
+
+// ResolveTaskSchema returns the schema with the highest version not higher than the task version.
+// Schemas are looked up in the embedded defaults, the configured urls, the schema directory and the database,
+// and a schema in the later source wins when the versions are the same.
+func ResolveTaskSchema(ctx context.Context, db *gorm.DB, taskType TaskType, taskVersion string) (*ResolvedTaskSchema, error) {
+	spec, ok := GetTaskTypeSpec(taskType)
+	if !ok {
+		return nil, errUnsupportedTaskType
+	}
+	version, err := ParseVersion(taskVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("%d:%s", taskType, formatVersion(version))
+	if entry, ok := resolvedTaskSchemas.Get(key); ok && time.Now().Before(entry.expiresAt) {
+		if entry.schema == nil {
+			return nil, ErrTaskSchemaNotFound
 		}
+		return entry.schema, nil
 	}
 
-	var v interface{}
-	if err := json.Unmarshal([]byte(jsonStr), &v); err != nil {
+	schema, err := resolveTaskSchema(ctx, db, spec, version)
+	if err != nil && !errors.Is(err, ErrTaskSchemaNotFound) {
 		return nil, err
 	}
-
-	return sdInferenceTaskSchema.Validate(v), nil
+	resolvedTaskSchemas.Add(key, &resolvedTaskSchemaEntry{
+		schema:    schema,
+		expiresAt: time.Now().Add(resolvedTaskSchemaTTL),
+	})
+	return schema, err
 }
 
-func validateGPTTaskArgs(jsonStr string) (validationError, err error) {
-	if gptInferenceTaskSchema == nil {
-		schemaJson := config.GetConfig().TaskSchema.GPTInference
+// InvalidateTaskSchemaCache drops the resolved schemas, it is called after the schemas in the database are changed
+func InvalidateTaskSchemaCache() {
+	resolvedTaskSchemas.Purge()
+}
 
-		if !isValidUrl(schemaJson) {
-			return nil, errors.New("invalid URL for task json schema")
+func resolveTaskSchema(ctx context.Context, db *gorm.DB, spec *TaskTypeSpec, version [3]uint64) (*ResolvedTaskSchema, error) {
+	var best *taskSchemaCandidate
+	consider := func(c *taskSchemaCandidate) {
+		if compareVersion(c.version, version) > 0 {
+			return
 		}
+		if best == nil || compareVersion(c.version, best.version) >= 0 {
+			best = c
+		}
+	}
 
-		gptInferenceTaskSchema, err = jsonschema.Compile(schemaJson)
+	if len(spec.DefaultSchema) > 0 {
+		consider(&taskSchemaCandidate{
+			source: TaskSchemaSourceEmbedded,
+			load: func() (string, string, error) {
+				return spec.DefaultSchema, "embedded:" + spec.Name, nil
+			},
+		})
+	}
 
-		if err != nil {
+	if spec.SchemaSource != nil {
+		if url := spec.SchemaSource(); len(url) > 0 {
+			consider(&taskSchemaCandidate{
+				source: TaskSchemaSourceURL,
+				load: func() (string, string, error) {
+					content, err := loadTaskSchemaURL(url)
+					if err != nil {
+						return "", "", err
+					}
+					return content, "url:" + url, nil
+				},
+			})
+		}
+	}
+
+	if dir := config.GetConfig().TaskSchema.Dir; len(dir) > 0 {
+		typeDir := filepath.Join(dir, spec.Name)
+		entries, err := os.ReadDir(typeDir)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
 			return nil, err
 		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+				continue
+			}
+			v, err := ParseVersion(strings.TrimSuffix(name, ".json"))
+			if err != nil {
+				continue
+			}
+			filename := filepath.Join(typeDir, name)
+			consider(&taskSchemaCandidate{
+				version: v,
+				source:  TaskSchemaSourceFile,
+				load: func() (string, string, error) {
+					info, err := os.Stat(filename)
+					if err != nil {
+						return "", "", err
+					}
+					content, err := os.ReadFile(filename)
+					if err != nil {
+						return "", "", err
+					}
+					return string(content), fmt.Sprintf("file:%s:%d", filename, info.ModTime().UnixNano()), nil
+				},
+			})
+		}
 	}
 
-	var v interface{}
-	if err := json.Unmarshal([]byte(jsonStr), &v); err != nil {
+	schemas, err := GetActiveTaskSchemas(ctx, db, spec.TaskType)
+	if err != nil {
 		return nil, err
 	}
+	for i := range schemas {
+		schema := &schemas[i]
+		v, err := ParseVersion(schema.Version)
+		if err != nil {
+			continue
+		}
+		consider(&taskSchemaCandidate{
+			version: v,
+			source:  TaskSchemaSourceDatabase,
+			load: func() (string, string, error) {
+				return schema.Content, fmt.Sprintf("database:%d:%d", schema.ID, schema.UpdatedAt.UnixNano()), nil
+			},
+		})
+	}
 
-	return gptInferenceTaskSchema.Validate(v), nil
+	if best == nil {
+		return nil, ErrTaskSchemaNotFound
+	}
+	content, cacheKey, err := best.load()
+	if err != nil {
+		return nil, err
+	}
+	return &ResolvedTaskSchema{
+		TaskType: spec.TaskType,
+		Version:  formatVersion(best.version),
+		Source:   best.source,
+		Content:  content,
+		cacheKey: cacheKey,
+	}, nil
 }
 
-func validateSDFinetuneLoraTaskArgs(jsonStr string) (validationError, err error) {
-	if sdFinetuneLoraTaskSchema == nil {
-		schemaJson := config.GetConfig().TaskSchema.StableDiffusionFinetuneLora
+// loadTaskSchemaURL downloads the schema from the url once, the content is kept until the relay restarts
+func loadTaskSchemaURL(url string) (string, error) {
+	urlTaskSchemasMu.Lock()
+	defer urlTaskSchemasMu.Unlock()
 
-		if !isValidUrl(schemaJson) {
-			return nil, errors.New("invalid URL for task json schema")
-		}
+	if content, ok := urlTaskSchemas[url]; ok {
+		return content, nil
+	}
 
-		sdFinetuneLoraTaskSchema, err = jsonschema.Compile(schemaJson)
+	log.Warnf("TaskSchema: loading task schema from %s, the task_schema url options are deprecated", url)
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("load task schema from %s: %s", url, resp.Status)
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	urlTaskSchemas[url] = string(content)
+	return string(content), nil
+}
 
-		if err != nil {
-			return nil, err
-		}
+// CompileTaskSchema compiles the schema content, schemas cannot reference remote resources
+func CompileTaskSchema(content string) (*jsonschema.Schema, error) {
+	compiler := jsonschema.NewCompiler()
+	compiler.LoadURL = func(s string) (_ io.ReadCloser, err error) {
+		return nil, fmt.Errorf("loading %s is not allowed", s)
 	}
+	if err := compiler.AddResource("task_schema.json", strings.NewReader(content)); err != nil {
+		return nil, err
+	}
+	return compiler.Compile("task_schema.json")
+}
 
-	var v interface{}
-	if err := json.Unmarshal([]byte(jsonStr), &v); err != nil {
+func getCompiledTaskSchema(resolved *ResolvedTaskSchema) (*jsonschema.Schema, error) {
+	if schema, ok := compiledTaskSchemas.Get(resolved.cacheKey); ok {
+		return schema, nil
+	}
+	schema, err := CompileTaskSchema(resolved.Content)
+	if err != nil {
 		return nil, err
 	}
-	return sdFinetuneLoraTaskSchema.Validate(v), nil
+	compiledTaskSchemas.Add(resolved.cacheKey, schema)
+	return schema, nil
 }
 
-func isValidUrl(toTest string) bool {
-	_, err := url.ParseRequestURI(toTest)
+func ValidateTaskArgsJsonStr(ctx context.Context, db *gorm.DB, jsonStr string, taskType TaskType, taskVersion string) (validationError, err error) {
+	resolved, err := ResolveTaskSchema(ctx, db, taskType, taskVersion)
+	if errors.Is(err, errUnsupportedTaskType) || errors.Is(err, errInvalidVersion) || errors.Is(err, ErrTaskSchemaNotFound) {
+		return err, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := getCompiledTaskSchema(resolved)
 	if err != nil {
-		return false
+		return nil, err
 	}
 
-	u, err := url.Parse(toTest)
-	if err != nil || u.Scheme == "" || u.Host == "" {
-		return false
+	var v interface{}
+	if err := json.Unmarshal([]byte(jsonStr), &v); err != nil {
+		return nil, err
 	}
 
-	return true
+	return schema.Validate(v), nil
 }
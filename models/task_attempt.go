@@ -0,0 +1,82 @@
+package models
+
+import (
+	"context"
+	"crynux_relay/utils"
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type TaskAttemptOutcome uint8
+
+const (
+	TaskAttemptRunning TaskAttemptOutcome = iota
+	TaskAttemptSucceeded
+	TaskAttemptRequeued
+	TaskAttemptAborted
+	TaskAttemptInvalidated
+)
+
+// InferenceTaskAttempt records each node a task has been dispatched to
+type InferenceTaskAttempt struct {
+	gorm.Model
+	TaskIDCommitment string             `json:"task_id_commitment" gorm:"index"`
+	Attempt          uint64             `json:"attempt"`
+	NodeAddress      string             `json:"node_address" gorm:"index"`
+	StartTime        sql.NullTime       `json:"start_time" gorm:"null;default:null"`
+	EndTime          sql.NullTime       `json:"end_time" gorm:"null;default:null"`
+	Outcome          TaskAttemptOutcome `json:"outcome"`
+	AbortReason      TaskAbortReason    `json:"abort_reason"`
+}
+
+func (attempt *InferenceTaskAttempt) Create(ctx context.Context, db *gorm.DB) error {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return db.WithContext(dbCtx).Create(attempt).Error
+}
+
+// FinishTaskAttempt sets the outcome of the running attempt of the task
+func FinishTaskAttempt(ctx context.Context, db *gorm.DB, taskIDCommitment string, attempt uint64, outcome TaskAttemptOutcome, abortReason TaskAbortReason) error {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return db.WithContext(dbCtx).Model(&InferenceTaskAttempt{}).
+		Where("task_id_commitment = ?", taskIDCommitment).
+		Where("attempt = ?", attempt).
+		Where("outcome = ?", TaskAttemptRunning).
+		Updates(map[string]interface{}{
+			"end_time":     sql.NullTime{Time: utils.Now(), Valid: true},
+			"outcome":      outcome,
+			"abort_reason": abortReason,
+		}).Error
+}
+
+func GetTaskAttempts(ctx context.Context, db *gorm.DB, taskIDCommitment string) ([]InferenceTaskAttempt, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var attempts []InferenceTaskAttempt
+	if err := db.WithContext(dbCtx).Model(&InferenceTaskAttempt{}).
+		Where("task_id_commitment = ?", taskIDCommitment).
+		Order("attempt").
+		Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// GetNodeRequeuedTaskAttempts returns the latest attempts on the node which were requeued
+func GetNodeRequeuedTaskAttempts(ctx context.Context, db *gorm.DB, nodeAddress string, limit int) ([]InferenceTaskAttempt, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var attempts []InferenceTaskAttempt
+	if err := db.WithContext(dbCtx).Model(&InferenceTaskAttempt{}).
+		Where("node_address = ?", nodeAddress).
+		Where("outcome = ?", TaskAttemptRequeued).
+		Order("id DESC").
+		Limit(limit).
+		Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type TaskDependency struct {
+	gorm.Model
+	TaskIDCommitment       string `json:"task_id_commitment" gorm:"index"`
+	ParentTaskIDCommitment string `json:"parent_task_id_commitment" gorm:"index"`
+}
+
+func GetTaskParentIDCommitments(ctx context.Context, db *gorm.DB, taskIDCommitment string) ([]string, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var deps []TaskDependency
+	if err := db.WithContext(dbCtx).Model(&TaskDependency{}).Where("task_id_commitment = ?", taskIDCommitment).Order("id").Find(&deps).Error; err != nil {
+		return nil, err
+	}
+	res := make([]string, len(deps))
+	for i, dep := range deps {
+		res[i] = dep.ParentTaskIDCommitment
+	}
+	return res, nil
+}
+
+func HasWaitingChildTask(ctx context.Context, db *gorm.DB, taskIDCommitments []string) (bool, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var count int64
+	if err := db.WithContext(dbCtx).Model(&TaskDependency{}).
+		Joins("JOIN inference_tasks ON inference_tasks.task_id_commitment = task_dependencies.task_id_commitment").
+		Where("task_dependencies.parent_task_id_commitment IN ?", taskIDCommitments).
+		Where("inference_tasks.status = ?", TaskWaiting).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type TaskDisputeStatus uint8
+
+const (
+	TaskDisputeOpen TaskDisputeStatus = iota
+	// the verification tasks agree on a different result, the node is slashed
+	TaskDisputeUpheld
+	// a verification task agrees with the result of the node, the deposit goes to the node
+	TaskDisputeRejected
+	// the verification tasks cannot decide, the deposit is returned to the creator
+	TaskDisputeInconclusive
+)
+
+var ErrTaskDisputeStatusChanged = errors.New("task dispute status changed")
+
+// TaskDispute is opened by the creator of a successful task which is not group validated.
+// The task is rerun on other nodes by the verification tasks to check the result of the selected node.
+type TaskDispute struct {
+	gorm.Model
+	TaskIDCommitment              string            `json:"task_id_commitment" gorm:"size:191;uniqueIndex"`
+	Creator                       string            `json:"creator"`
+	SelectedNode                  string            `json:"selected_node" gorm:"index"`
+	Deposit                       BigInt            `json:"deposit" gorm:"type:string;size:255"`
+	VerificationTaskIDCommitments StringArray       `json:"verification_task_id_commitments" gorm:"type:text"`
+	Status                        TaskDisputeStatus `json:"status" gorm:"index"`
+	// task fee taken back from the node when the dispute is upheld
+	ClawbackAmount BigInt       `json:"clawback_amount" gorm:"type:string;size:255"`
+	ResolvedTime   sql.NullTime `json:"resolved_time" gorm:"null;default:null"`
+}
+
+func (dispute *TaskDispute) Create(ctx context.Context, db *gorm.DB) error {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return db.WithContext(dbCtx).Create(dispute).Error
+}
+
+// Update updates the dispute only if its status is not changed by others
+func (dispute *TaskDispute) Update(ctx context.Context, db *gorm.DB, values map[string]interface{}) error {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result := db.WithContext(dbCtx).Model(dispute).Where("status = ?", dispute.Status).Updates(values)
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return ErrTaskDisputeStatusChanged
+	}
+	return nil
+}
+
+func GetTaskDispute(ctx context.Context, db *gorm.DB, taskIDCommitment string) (*TaskDispute, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var dispute TaskDispute
+	if err := db.WithContext(dbCtx).Model(&TaskDispute{}).Where("task_id_commitment = ?", taskIDCommitment).First(&dispute).Error; err != nil {
+		return nil, err
+	}
+	return &dispute, nil
+}
+
+func GetOpenTaskDisputes(ctx context.Context, db *gorm.DB, startID uint, limit int) ([]TaskDispute, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var disputes []TaskDispute
+	if err := db.WithContext(dbCtx).Model(&TaskDispute{}).
+		Where("status = ?", TaskDisputeOpen).
+		Where("id > ?", startID).
+		Order("id").
+		Limit(limit).
+		Find(&disputes).Error; err != nil {
+		return nil, err
+	}
+	return disputes, nil
+}
+
+// GetDisputeVerificationTasks returns the tasks rerun for the dispute of the task
+func GetDisputeVerificationTasks(ctx context.Context, db *gorm.DB, taskIDCommitment string) ([]InferenceTask, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var tasks []InferenceTask
+	if err := db.WithContext(dbCtx).Model(&InferenceTask{}).
+		Where("dispute_task_id_commitment = ?", taskIDCommitment).
+		Order("id").
+		Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TaskSchema is a task args json schema uploaded at runtime.
+// An active schema applies to the tasks of its task type whose version is not lower than the schema version.
+type TaskSchema struct {
+	gorm.Model
+	TaskType TaskType `json:"task_type" gorm:"uniqueIndex:idx_task_schema_type_version"`
+	Version  string   `json:"version" gorm:"size:191;uniqueIndex:idx_task_schema_type_version"`
+	Content  string   `json:"content" gorm:"type:text"`
+	Active   bool     `json:"active"`
+}
+
+func (s *TaskSchema) Save(ctx context.Context, db *gorm.DB) error {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return db.WithContext(dbCtx).Save(s).Error
+}
+
+func GetTaskSchema(ctx context.Context, db *gorm.DB, taskType TaskType, version string) (*TaskSchema, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	schema := &TaskSchema{}
+	if err := db.WithContext(dbCtx).Model(schema).Where("task_type = ? AND version = ?", taskType, version).First(schema).Error; err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+func GetActiveTaskSchemas(ctx context.Context, db *gorm.DB, taskType TaskType) ([]TaskSchema, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var schemas []TaskSchema
+	if err := db.WithContext(dbCtx).Model(&TaskSchema{}).Where("task_type = ? AND active = ?", taskType, true).Find(&schemas).Error; err != nil {
+		return nil, err
+	}
+	return schemas, nil
+}
+
+var errInvalidVersion = errors.New("invalid version")
+
+// ParseVersion parses a version string in the form of major.minor.patch
+func ParseVersion(version string) ([3]uint64, error) {
+	var res [3]uint64
+	parts := strings.Split(version, ".")
+	if len(parts) != 3 {
+		return res, errInvalidVersion
+	}
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return res, errInvalidVersion
+		}
+		res[i] = n
+	}
+	return res, nil
+}
+
+func compareVersion(v1, v2 [3]uint64) int {
+	for i := 0; i < 3; i++ {
+		if v1[i] < v2[i] {
+			return -1
+		}
+		if v1[i] > v2[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+func formatVersion(v [3]uint64) string {
+	return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+}
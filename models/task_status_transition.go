@@ -0,0 +1,57 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type TaskStatusEvent string
+
+const (
+	TaskStatusEventCreate        TaskStatusEvent = "create"
+	TaskStatusEventRelease       TaskStatusEvent = "release"
+	TaskStatusEventStart         TaskStatusEvent = "start"
+	TaskStatusEventRequeue       TaskStatusEvent = "requeue"
+	TaskStatusEventReportScore   TaskStatusEvent = "report_score"
+	TaskStatusEventReportError   TaskStatusEvent = "report_error"
+	TaskStatusEventValidate      TaskStatusEvent = "validate"
+	TaskStatusEventGroupValidate TaskStatusEvent = "group_validate"
+	TaskStatusEventInvalidate    TaskStatusEvent = "invalidate"
+	TaskStatusEventGroupRefund   TaskStatusEvent = "group_refund"
+	TaskStatusEventUploadResult  TaskStatusEvent = "upload_result"
+	TaskStatusEventAbort         TaskStatusEvent = "abort"
+)
+
+// TaskStatusTransition is the audit log of a status change of a task.
+// The creation of a task is logged with the same from and to status.
+type TaskStatusTransition struct {
+	gorm.Model
+	TaskIDCommitment string          `json:"task_id_commitment" gorm:"index"`
+	Event            TaskStatusEvent `json:"event"`
+	FromStatus       TaskStatus      `json:"from_status"`
+	ToStatus         TaskStatus      `json:"to_status"`
+	// address of who causes the transition: the creator, the selected node or the relay
+	Actor  string `json:"actor"`
+	Reason string `json:"reason"`
+}
+
+func (transition *TaskStatusTransition) Create(ctx context.Context, db *gorm.DB) error {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return db.WithContext(dbCtx).Create(transition).Error
+}
+
+func GetTaskStatusTransitions(ctx context.Context, db *gorm.DB, taskIDCommitment string) ([]TaskStatusTransition, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var transitions []TaskStatusTransition
+	if err := db.WithContext(dbCtx).Model(&TaskStatusTransition{}).
+		Where("task_id_commitment = ?", taskIDCommitment).
+		Order("id").
+		Find(&transitions).Error; err != nil {
+		return nil, err
+	}
+	return transitions, nil
+}
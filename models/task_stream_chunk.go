@@ -0,0 +1,53 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type TaskStreamChunk struct {
+	gorm.Model
+	TaskIDCommitment string `json:"task_id_commitment" gorm:"size:191;uniqueIndex:idx_task_stream_chunk_sequence"`
+	Sequence         uint64 `json:"sequence" gorm:"uniqueIndex:idx_task_stream_chunk_sequence"`
+	Content          string `json:"content" gorm:"type:text"`
+}
+
+func (chunk *TaskStreamChunk) Create(ctx context.Context, db *gorm.DB) error {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return db.WithContext(dbCtx).Create(chunk).Error
+}
+
+// GetNextTaskStreamSequence returns the sequence of the next chunk of the task stream
+func GetNextTaskStreamSequence(ctx context.Context, db *gorm.DB, taskIDCommitment string) (uint64, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var count int64
+	if err := db.WithContext(dbCtx).Model(&TaskStreamChunk{}).Where("task_id_commitment = ?", taskIDCommitment).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return uint64(count), nil
+}
+
+func GetTaskStreamChunks(ctx context.Context, db *gorm.DB, taskIDCommitment string, startSequence uint64, limit int) ([]TaskStreamChunk, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var chunks []TaskStreamChunk
+	if err := db.WithContext(dbCtx).Model(&TaskStreamChunk{}).
+		Where("task_id_commitment = ?", taskIDCommitment).
+		Where("sequence >= ?", startSequence).
+		Order("sequence").
+		Limit(limit).
+		Find(&chunks).Error; err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
+
+func DeleteTaskStreamChunks(ctx context.Context, db *gorm.DB, taskIDCommitment string) error {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return db.WithContext(dbCtx).Unscoped().Where("task_id_commitment = ?", taskIDCommitment).Delete(&TaskStreamChunk{}).Error
+}
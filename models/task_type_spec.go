@@ -0,0 +1,148 @@
+package models
+
+import (
+	"crynux_relay/config"
+	_ "embed"
+	"sort"
+	"sync"
+)
+
+// TaskTypeSpec declares how tasks of a task type are validated, dispatched and stored.
+// A new task type is added by registering its spec.
+type TaskTypeSpec struct {
+	TaskType TaskType
+	Name     string
+	// DefaultSchema is the json schema of the task args used when no schema is configured for the task version
+	DefaultSchema string
+	// SchemaSource returns the configured url of the json schema of the task args, which replaces the default schema.
+	// Deprecated: kept for the old task_schema url options.
+	SchemaSource func() string
+	// file extension and mime type of the result files
+	ResultFileExt  string
+	ResultMimeType string
+	// DefaultValidator is the name of the validator used when no validation rule matches the task
+	DefaultValidator string
+	// NodeRequirement is the requirement expression on the node hardware to run tasks of the type, empty means all nodes are able
+	NodeRequirement string
+	// IncreaseIncentiveTaskCount increases the task count of the type in the node incentive
+	IncreaseIncentiveTaskCount func(nodeIncentive *NodeIncentive)
+	// RetentionHours returns how long the task data is kept after the task ends, 0 means forever
+	RetentionHours func() uint64
+	// Checkpoint means the task can take an input checkpoint and produces a result checkpoint
+	Checkpoint bool
+	// Streaming means the node can stream the result to the creator while running
+	Streaming bool
+}
+
+// The default schemas are vendored from the task repos, run go generate to update them.
+//
+//go:generate curl -sSfL -o schemas/stable_diffusion_inference.json https://raw.githubusercontent.com/crynux-ai/stable-diffusion-task/main/schema/stable-diffusion-inference-task.json
+//go:generate curl -sSfL -o schemas/gpt_inference.json https://raw.githubusercontent.com/crynux-ai/gpt-task/main/schema/gpt-inference-task.json
+var (
+	//go:embed schemas/stable_diffusion_inference.json
+	sdInferenceTaskSchema string
+	//go:embed schemas/gpt_inference.json
+	gptInferenceTaskSchema string
+	//go:embed schemas/stable_diffusion_finetune_lora.json
+	sdFinetuneLoraTaskSchema string
+)
+
+var (
+	taskTypeSpecsMu sync.RWMutex
+	taskTypeSpecs   = make(map[TaskType]*TaskTypeSpec)
+)
+
+func RegisterTaskType(spec *TaskTypeSpec) {
+	taskTypeSpecsMu.Lock()
+	defer taskTypeSpecsMu.Unlock()
+	taskTypeSpecs[spec.TaskType] = spec
+}
+
+func GetTaskTypeSpec(taskType TaskType) (*TaskTypeSpec, bool) {
+	taskTypeSpecsMu.RLock()
+	defer taskTypeSpecsMu.RUnlock()
+	spec, ok := taskTypeSpecs[taskType]
+	return spec, ok
+}
+
+// GetTaskTypes returns all registered task types in ascending order
+func GetTaskTypes() []TaskType {
+	taskTypeSpecsMu.RLock()
+	defer taskTypeSpecsMu.RUnlock()
+	res := make([]TaskType, 0, len(taskTypeSpecs))
+	for taskType := range taskTypeSpecs {
+		res = append(res, taskType)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i] < res[j]
+	})
+	return res
+}
+
+func IsStreamingTaskType(taskType TaskType) bool {
+	spec, ok := GetTaskTypeSpec(taskType)
+	return ok && spec.Streaming
+}
+
+func IsCheckpointTaskType(taskType TaskType) bool {
+	spec, ok := GetTaskTypeSpec(taskType)
+	return ok && spec.Checkpoint
+}
+
+func init() {
+	RegisterTaskType(&TaskTypeSpec{
+		TaskType:      TaskTypeSD,
+		Name:          "stable_diffusion_inference",
+		DefaultSchema: sdInferenceTaskSchema,
+		SchemaSource: func() string {
+			return config.GetConfig().TaskSchema.StableDiffusionInference
+		},
+		ResultFileExt:    ".png",
+		ResultMimeType:   "image/png",
+		DefaultValidator: "phash",
+		IncreaseIncentiveTaskCount: func(nodeIncentive *NodeIncentive) {
+			nodeIncentive.SDTaskCount += 1
+		},
+		RetentionHours: func() uint64 {
+			return config.GetConfig().Retention.StableDiffusionInference
+		},
+	})
+	RegisterTaskType(&TaskTypeSpec{
+		TaskType:      TaskTypeLLM,
+		Name:          "gpt_inference",
+		DefaultSchema: gptInferenceTaskSchema,
+		SchemaSource: func() string {
+			return config.GetConfig().TaskSchema.GPTInference
+		},
+		ResultFileExt:    ".json",
+		ResultMimeType:   "application/json",
+		DefaultValidator: "sha256",
+		// LLM tasks are not run on Darwin nodes
+		NodeRequirement: "platform != darwin",
+		IncreaseIncentiveTaskCount: func(nodeIncentive *NodeIncentive) {
+			nodeIncentive.LLMTaskCount += 1
+		},
+		RetentionHours: func() uint64 {
+			return config.GetConfig().Retention.GPTInference
+		},
+		Streaming: true,
+	})
+	RegisterTaskType(&TaskTypeSpec{
+		TaskType:      TaskTypeSDFTLora,
+		Name:          "stable_diffusion_finetune_lora",
+		DefaultSchema: sdFinetuneLoraTaskSchema,
+		SchemaSource: func() string {
+			return config.GetConfig().TaskSchema.StableDiffusionFinetuneLora
+		},
+		ResultFileExt:    ".png",
+		ResultMimeType:   "image/png",
+		DefaultValidator: "phash",
+		IncreaseIncentiveTaskCount: func(nodeIncentive *NodeIncentive) {
+			nodeIncentive.SDFTLoraTaskCount += 1
+		},
+		RetentionHours: func() uint64 {
+			return config.GetConfig().Retention.StableDiffusionFinetuneLora
+		},
+		Checkpoint: true,
+	})
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"crynux_relay/config"
+	"crynux_relay/validator"
+	"errors"
+	"fmt"
+)
+
+var ErrValidatorNotFound = errors.New("validator not found")
+
+// ResolveTaskValidator returns the validator and threshold for tasks of the task type and models.
+// Rules matching one of the model ids take precedence over the rules for the whole task type,
+// and the default validator of the task type is used when no rule matches.
+func ResolveTaskValidator(taskType TaskType, modelIDs []string) (validator.Validator, float64, error) {
+	appConfig := config.GetConfig()
+
+	var name string
+	var threshold *float64
+	found := false
+	for _, modelID := range modelIDs {
+		for _, rule := range appConfig.Validation.Rules {
+			if TaskType(rule.TaskType) == taskType && rule.ModelID != "" && rule.ModelID == modelID {
+				name, threshold, found = rule.Validator, rule.Threshold, true
+				break
+			}
+		}
+		if found {
+			break
+		}
+	}
+	if !found {
+		for _, rule := range appConfig.Validation.Rules {
+			if TaskType(rule.TaskType) == taskType && rule.ModelID == "" {
+				name, threshold, found = rule.Validator, rule.Threshold, true
+				break
+			}
+		}
+	}
+	if !found {
+		spec, ok := GetTaskTypeSpec(taskType)
+		if !ok {
+			return nil, 0, errUnsupportedTaskType
+		}
+		name = spec.DefaultValidator
+	}
+
+	v, ok := validator.Get(name)
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: %s", ErrValidatorNotFound, name)
+	}
+	if threshold != nil {
+		return v, *threshold, nil
+	}
+	// hamming distance based validators fall back to the global distance threshold
+	return v, float64(appConfig.Task.DistanceThreshold), nil
+}
+
+// CheckValidationRules makes sure the validator of every validation rule compares the scores nodes report.
+// Nodes hash the results of a task type by its default validator, so a rule can only change the threshold
+// of the default validator, as any other validator would compare the scores in a format they are not hashed in.
+func CheckValidationRules() error {
+	for _, rule := range config.GetConfig().Validation.Rules {
+		spec, ok := GetTaskTypeSpec(TaskType(rule.TaskType))
+		if !ok {
+			return fmt.Errorf("validation rule: %w: %d", errUnsupportedTaskType, rule.TaskType)
+		}
+		if rule.Validator != spec.DefaultValidator {
+			return fmt.Errorf("validation rule: validator %s cannot compare the scores of task type %d, which are hashed by %s",
+				rule.Validator, rule.TaskType, spec.DefaultValidator)
+		}
+	}
+	return nil
+}
+
+// GetTaskValidator returns the validator recorded on the task.
+// Tasks created before validators are recorded are resolved by the current config.
+func GetTaskValidator(task *InferenceTask) (validator.Validator, float64, error) {
+	if task.Validator == "" {
+		return ResolveTaskValidator(task.TaskType, task.ModelIDs)
+	}
+	v, ok := validator.Get(task.Validator)
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: %s", ErrValidatorNotFound, task.Validator)
+	}
+	return v, task.ValidatorThreshold, nil
+}
+
+// GetTaskScoreValidator returns the validator of the hash algorithm which nodes compute the scores of the task type with,
+// that is the default validator of the task type. The uploaded result files are checked against the score by it,
+// while the validator of the task is only used to compare the scores of the tasks in a validation group.
+func GetTaskScoreValidator(taskType TaskType) (validator.Validator, error) {
+	spec, ok := GetTaskTypeSpec(taskType)
+	if !ok {
+		return nil, errUnsupportedTaskType
+	}
+	v, ok := validator.Get(spec.DefaultValidator)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrValidatorNotFound, spec.DefaultValidator)
+	}
+	return v, nil
+}
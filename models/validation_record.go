@@ -0,0 +1,89 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ValidationComparison is the result of comparing the score of a task against another task in its group
+type ValidationComparison struct {
+	TaskIDCommitment string `json:"task_id_commitment"`
+	Same             bool   `json:"same"`
+	// distance between each pair of result hashes, empty when the scores cannot be compared
+	Distances []uint64 `json:"distances"`
+}
+
+type ValidationComparisons []ValidationComparison
+
+func (comparisons *ValidationComparisons) Scan(val interface{}) error {
+	var b []byte
+	switch v := val.(type) {
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	case nil:
+		return nil
+	default:
+		return errors.New(fmt.Sprint("Unable to parse value to ValidationComparisons: ", val))
+	}
+	return json.Unmarshal(b, (*[]ValidationComparison)(comparisons))
+}
+
+func (comparisons ValidationComparisons) Value() (driver.Value, error) {
+	b, err := json.Marshal([]ValidationComparison(comparisons))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// ValidationRecord keeps the evidence of how the validation result of a task is decided
+type ValidationRecord struct {
+	gorm.Model
+	TaskID           string `json:"task_id" gorm:"index"`
+	TaskIDCommitment string `json:"task_id_commitment" gorm:"index"`
+	SelectedNode     string `json:"selected_node"`
+	Grouped          bool   `json:"grouped"`
+	// order of the task in its group by execution time, aborted tasks are ordered last
+	ExecutionOrder uint64 `json:"execution_order"`
+	// execution time in milliseconds, 0 when the task has not finished
+	ExecutionTime uint64                `json:"execution_time"`
+	Status        TaskStatus            `json:"status"`
+	Score         string                `json:"score" gorm:"type:text"`
+	Validator     string                `json:"validator"`
+	Threshold     float64               `json:"threshold"`
+	Comparisons   ValidationComparisons `json:"comparisons" gorm:"type:text"`
+	QOSScore      sql.NullInt64         `json:"qos_score"`
+	Verdict       TaskStatus            `json:"verdict"`
+}
+
+func (record *ValidationRecord) Create(ctx context.Context, db *gorm.DB) error {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return db.WithContext(dbCtx).Create(record).Error
+}
+
+// GetValidationRecords returns the records of all tasks of the creator validated together under the task id.
+// Task ids are chosen by creators, so records of the same task id from other creators are not returned.
+func GetValidationRecords(ctx context.Context, db *gorm.DB, taskID, creator string) ([]ValidationRecord, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var records []ValidationRecord
+	if err := db.WithContext(dbCtx).Model(&ValidationRecord{}).
+		Joins("JOIN inference_tasks ON inference_tasks.task_id_commitment = validation_records.task_id_commitment").
+		Where("validation_records.task_id = ?", taskID).
+		Where("inference_tasks.creator = ?", creator).
+		Order("validation_records.execution_order, validation_records.id").
+		Find(&records).Error; err != nil {
+		return nil, err
+	}
+	return records, nil
+}
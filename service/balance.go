@@ -8,44 +8,36 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
-	"sync"
+	"sort"
 	"time"
 
-	log "github.com/sirupsen/logrus"
-
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
-type BalanceCache struct {
-	balances map[string]*big.Int
-	mu       sync.RWMutex
-}
-
-var balanceCache = &BalanceCache{
-	balances: make(map[string]*big.Int),
-}
-
-func InitBalanceCache(ctx context.Context, db *gorm.DB) error {
-	var events []models.TransferEvent
-	if err := db.Where("status = ?", models.TransferEventStatusPending).Find(&events).Error; err != nil {
-		return err
-	}
+var (
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	errBalanceChanged      = errors.New("balance changed during transfer")
+	errEventsChanged       = errors.New("transfer events changed during processing")
+)
 
-	for _, event := range events {
-		fromAmount, err := getBalanceFromCache(ctx, db, event.FromAddress)
+// ApplyPendingTransferEvents applies the transfer events left pending by the relays which
+// kept the balances in memory and synced them to the database in the background.
+// Transfers are applied to the database directly now, so it only needs to run once at startup.
+func ApplyPendingTransferEvents(ctx context.Context, db *gorm.DB) error {
+	for {
+		events, err := getPendingTransferEvents(ctx, db, 100)
 		if err != nil {
 			return err
 		}
-		toAmount, err := getBalanceFromCache(ctx, db, event.ToAddress)
-		if err != nil {
+		if len(events) == 0 {
+			return nil
+		}
+		// the events applied by another instance at the same time are skipped in the next round
+		if err := processPendingTransferEvents(ctx, db, events); err != nil && !errors.Is(err, errEventsChanged) {
 			return err
 		}
-
-		fromAmount.Sub(fromAmount, &event.Amount.Int)
-		toAmount.Add(toAmount, &event.Amount.Int)
 	}
-	return nil
 }
 
 func getPendingTransferEvents(ctx context.Context, db *gorm.DB, limit int) ([]models.TransferEvent, error) {
@@ -59,51 +51,6 @@ func getPendingTransferEvents(ctx context.Context, db *gorm.DB, limit int) ([]mo
 	return events, nil
 }
 
-func getBalanceFromCache(ctx context.Context, db *gorm.DB, address string) (*big.Int, error) {
-	balanceCache.mu.RLock()
-	balance, exists := balanceCache.balances[address]
-	balanceCache.mu.RUnlock()
-
-	if exists {
-		return balance, nil
-	}
-
-	balanceCache.mu.Lock()
-	defer balanceCache.mu.Unlock()
-
-	if balance, exists := balanceCache.balances[address]; exists {
-		return balance, nil
-	}
-
-	var dbBalance models.Balance
-	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
-
-	if err := db.WithContext(dbCtx).Where("address = ?", address).Attrs(models.Balance{Balance: models.BigInt{Int: *big.NewInt(0)}}).FirstOrInit(&dbBalance).Error; err != nil {
-		return nil, err
-	}
-
-	balanceCache.balances[address] = &dbBalance.Balance.Int
-
-	return balanceCache.balances[address], nil
-}
-
-func StartBalanceSync(ctx context.Context, db *gorm.DB) {
-	ticker := time.NewTicker(10 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			if err := syncBalancesToDB(ctx, db); err != nil {
-				log.Errorf("Failed to sync balances: %v", err)
-			}
-		}
-	}
-}
-
 func mergeTransferEvents(events []models.TransferEvent) map[string]*big.Int {
 	mergedEvents := make(map[string]*big.Int)
 	for _, event := range events {
@@ -176,53 +123,19 @@ func processPendingTransferEvents(ctx context.Context, db *gorm.DB, events []mod
 			}
 		}
 
-		if err := tx.Model(&models.TransferEvent{}).Where("id IN (?)", eventIDs).Where("status = ?", models.TransferEventStatusPending).Update("status", models.TransferEventStatusProcessed).Error; err != nil {
-			return err
+		res := tx.Model(&models.TransferEvent{}).Where("id IN (?)", eventIDs).Where("status = ?", models.TransferEventStatusPending).Update("status", models.TransferEventStatusProcessed)
+		if res.Error != nil {
+			return res.Error
+		}
+		// another instance has applied some of the events at the same time
+		if res.RowsAffected != int64(len(eventIDs)) {
+			return errEventsChanged
 		}
 
 		return nil
 	})
 }
 
-func syncBalancesToDB(ctx context.Context, db *gorm.DB) error {
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-			err := func() error {
-				for {
-					events, err := getPendingTransferEvents(ctx, db, 100)
-					if err != nil {
-						return err
-					}
-
-					if len(events) == 0 {
-						break
-					}
-
-					if err := processPendingTransferEvents(ctx, db, events); err != nil {
-						return err
-					}
-				}
-				return nil
-			}()
-
-			if err != nil {
-				log.Errorf("Failed to sync balances: %v", err)
-			}
-
-			// Wait for 2 seconds before next iteration
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-time.After(2 * time.Second):
-				continue
-			}
-		}
-	}
-}
-
 func CreateGenesisAccount(ctx context.Context, db *gorm.DB) error {
 	appConfig := config.GetConfig()
 	address := appConfig.Blockchain.Account.Address
@@ -239,42 +152,124 @@ func CreateGenesisAccount(ctx context.Context, db *gorm.DB) error {
 	}).Error
 }
 
-func Transfer(ctx context.Context, db *gorm.DB, from, to string, amount *big.Int) (func (), error) {
-	fromBalance, err := getBalanceFromCache(ctx, db, from)
-	if err != nil {
-		return nil, err
-	}
-	if fromBalance.Cmp(amount) < 0 {
-		return nil, errors.New("insufficient balance")
-	}
-	toBalance, err := getBalanceFromCache(ctx, db, to)
-	if err != nil {
-		return nil, err
+// transferRetries is how many times a transfer is retried after a balance changes between the read and the update
+const transferRetries = 3
+
+// Transfer moves the amount from one balance to another in the database.
+// The balance rows are locked and each update is conditioned on the balance read,
+// so that concurrent transfers from any relay instance cannot overdraw a balance.
+// A transfer is retried if a balance is changed by another transfer in the meantime.
+func Transfer(ctx context.Context, db *gorm.DB, from, to string, amount *big.Int) error {
+	var err error
+	for i := 0; i <= transferRetries; i++ {
+		err = transfer(ctx, db, from, to, amount)
+		if !errors.Is(err, errBalanceChanged) {
+			return err
+		}
 	}
+	return err
+}
+
+func transfer(ctx context.Context, db *gorm.DB, from, to string, amount *big.Int) error {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
 
-	event := &models.TransferEvent{
-		FromAddress: from,
-		ToAddress:   to,
-		Amount:      models.BigInt{Int: *new(big.Int).Set(amount)},
-		CreatedAt:   time.Now(),
-		Status:      models.TransferEventStatusPending,
+	// the transaction is a savepoint in the transaction of the caller, so a retry starts from a clean state
+	return db.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+		if amount.Sign() != 0 {
+			balances, err := lockBalances(tx, from, to)
+			if err != nil {
+				return err
+			}
+			if err := addBalance(tx, balances[from], new(big.Int).Neg(amount)); err != nil {
+				return err
+			}
+			if err := addBalance(tx, balances[to], amount); err != nil {
+				return err
+			}
+		}
+		return tx.Create(&models.TransferEvent{
+			FromAddress: from,
+			ToAddress:   to,
+			Amount:      models.BigInt{Int: *new(big.Int).Set(amount)},
+			CreatedAt:   time.Now(),
+			Status:      models.TransferEventStatusProcessed,
+		}).Error
+	})
+}
+
+// lockBalances locks the balance rows of the sender and the receiver in the order of their addresses,
+// so that transfers in opposite directions between the same accounts, like a task fee charge and a refund,
+// wait for each other instead of deadlock. The balance of the receiver is created if it does not exist.
+func lockBalances(tx *gorm.DB, from, to string) (map[string]*models.Balance, error) {
+	addresses := []string{from, to}
+	sort.Strings(addresses)
+
+	balances := make(map[string]*models.Balance)
+	for _, address := range addresses {
+		if _, ok := balances[address]; ok {
+			continue
+		}
+		balance, err := lockBalance(tx, address)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if address != to {
+				return nil, ErrInsufficientBalance
+			}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "address"}},
+				DoNothing: true,
+			}).Create(&models.Balance{
+				Address: address,
+				Balance: models.BigInt{Int: *big.NewInt(0)},
+			}).Error; err != nil {
+				return nil, err
+			}
+			balance, err = lockBalance(tx, address)
+		}
+		if err != nil {
+			return nil, err
+		}
+		balances[address] = balance
 	}
+	return balances, nil
+}
 
-	if err := db.Create(event).Error; err != nil {
+func lockBalance(tx *gorm.DB, address string) (*models.Balance, error) {
+	var balance models.Balance
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("address = ?", address).First(&balance).Error; err != nil {
 		return nil, err
 	}
+	return &balance, nil
+}
 
-	amountCopy := new(big.Int).Set(amount)
-	commitFunc := func() {
-		balanceCache.mu.Lock()
-		defer balanceCache.mu.Unlock()
-		fromBalance.Sub(fromBalance, amountCopy)
-		toBalance.Add(toBalance, amountCopy)
+// addBalance adds the amount to the locked balance, the sender and the receiver may be the same balance
+func addBalance(tx *gorm.DB, balance *models.Balance, amount *big.Int) error {
+	newBalance := new(big.Int).Add(&balance.Balance.Int, amount)
+	if newBalance.Sign() < 0 {
+		return ErrInsufficientBalance
 	}
-
-	return commitFunc, nil
+	// the balance is stored as a string, so it is computed here and only written if nobody changed it since the read
+	res := tx.Model(&models.Balance{}).
+		Where("id = ?", balance.ID).
+		Where("balance = ?", balance.Balance.String()).
+		Update("balance", models.BigInt{Int: *newBalance})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errBalanceChanged
+	}
+	balance.Balance = models.BigInt{Int: *newBalance}
+	return nil
 }
 
 func GetBalance(ctx context.Context, db *gorm.DB, address string) (*big.Int, error) {
-	return getBalanceFromCache(ctx, db, address)
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var balance models.Balance
+	if err := db.WithContext(dbCtx).Where("address = ?", address).Attrs(models.Balance{Balance: models.BigInt{Int: *big.NewInt(0)}}).FirstOrInit(&balance).Error; err != nil {
+		return nil, err
+	}
+	return &balance.Balance.Int, nil
 }
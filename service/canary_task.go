@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"crynux_relay/utils"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"gorm.io/gorm"
+)
+
+// getCanaryCreator picks the creator of a canary or spot check task at random from the configured creators,
+// which are checked to be set and not the relay account when they are enabled
+func getCanaryCreator() (string, error) {
+	creators := config.GetConfig().Canary.Creators
+	if len(creators) == 0 {
+		return "", errors.New("canary creators not set")
+	}
+	index, err := rand.Int(rand.Reader, big.NewInt(int64(len(creators))))
+	if err != nil {
+		return "", err
+	}
+	return creators[index.Int64()], nil
+}
+
+func newCanaryTask(index int) (*models.InferenceTask, error) {
+	appConfig := config.GetConfig()
+	if len(appConfig.Canary.Tasks) == 0 {
+		return nil, errors.New("canary tasks not set")
+	}
+	canaryConfig := appConfig.Canary.Tasks[index%len(appConfig.Canary.Tasks)]
+
+	taskFee, ok := new(big.Int).SetString(canaryConfig.TaskFee, 10)
+	if !ok {
+		return nil, errors.New("invalid canary task fee")
+	}
+	taskType := models.TaskType(canaryConfig.TaskType)
+	v, threshold, err := models.ResolveTaskValidator(taskType, canaryConfig.ModelIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	randomBytes := make([]byte, 96)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return nil, err
+	}
+	taskIDBytes, nonceBytes, samplingSeedBytes := randomBytes[:32], randomBytes[32:64], randomBytes[64:]
+	taskIDCommitment := crypto.Keccak256Hash(taskIDBytes, nonceBytes)
+	creator, err := getCanaryCreator()
+	if err != nil {
+		return nil, err
+	}
+
+	// the canary task looks the same as a task created by the api to the nodes,
+	// and its task id is known from the start as it is validated by the relay
+	return &models.InferenceTask{
+		TaskArgs:           canaryConfig.TaskArgs,
+		TaskIDCommitment:   taskIDCommitment.Hex(),
+		Creator:            creator,
+		SamplingSeed:       hexutil.Encode(samplingSeedBytes),
+		Nonce:              hexutil.Encode(nonceBytes),
+		Status:             models.TaskQueued,
+		TaskType:           taskType,
+		TaskVersion:        canaryConfig.TaskVersion,
+		Timeout:            canaryConfig.Timeout,
+		MinVRAM:            canaryConfig.MinVRAM,
+		RequiredGPU:        canaryConfig.RequiredGPU,
+		RequiredGPUVRAM:    canaryConfig.RequiredGPUVRAM,
+		TaskFee:            models.BigInt{Int: *taskFee},
+		TaskSize:           canaryConfig.TaskSize,
+		ModelIDs:           canaryConfig.ModelIDs,
+		TaskID:             hexutil.Encode(taskIDBytes),
+		MaxAttempts:        1,
+		Validator:          v.Name(),
+		ValidatorThreshold: threshold,
+		Canary:             true,
+		CreateTime:         sql.NullTime{Time: utils.Now(), Valid: true},
+	}, nil
+}
+
+// topUpCanaryCreator transfers the missing task fee from the relay account to the canary creator
+func topUpCanaryCreator(ctx context.Context, db *gorm.DB, creator string, taskFee *big.Int) error {
+	appConfig := config.GetConfig()
+	if creator == appConfig.Blockchain.Account.Address {
+		return nil
+	}
+	balance, err := GetBalance(ctx, db, creator)
+	if err != nil {
+		return err
+	}
+	if balance.Cmp(taskFee) >= 0 {
+		return nil
+	}
+	amount := new(big.Int).Sub(taskFee, balance)
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := Transfer(ctx, tx, appConfig.Blockchain.Account.Address, creator, amount); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// CreateCanaryTask creates the canary task at the index of the configured canary task pool
+func CreateCanaryTask(ctx context.Context, db *gorm.DB, index int) (*models.InferenceTask, error) {
+	appConfig := config.GetConfig()
+	task, err := newCanaryTask(index)
+	if err != nil {
+		return nil, err
+	}
+	if err := topUpCanaryCreator(ctx, db, task.Creator, &task.TaskFee.Int); err != nil {
+		return nil, err
+	}
+
+	canary := &models.CanaryTask{
+		TaskIDCommitment: task.TaskIDCommitment,
+		ReferenceScore:   appConfig.Canary.Tasks[index%len(appConfig.Canary.Tasks)].Score,
+		Status:           models.CanaryTaskPending,
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := CreateTask(ctx, tx, task, nil); err != nil {
+			return err
+		}
+		return canary.Create(ctx, tx)
+	}); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// ResolveCanaryTask compares the result of the canary task with its reference score after the node reports it.
+// The task is validated if they match, otherwise the task is aborted, or invalidated and the node is slashed when configured.
+// Either way the outcome is fed into the qos score of the node.
+// No canary specific events are emitted, so that canary tasks cannot be told apart from the event log.
+func ResolveCanaryTask(ctx context.Context, db *gorm.DB, originCanary *models.CanaryTask) error {
+	canary := *originCanary
+	if canary.Status != models.CanaryTaskPending {
+		return nil
+	}
+
+	task, err := models.GetTaskByIDCommitment(ctx, db, canary.TaskIDCommitment)
+	if err != nil {
+		return err
+	}
+
+	if task.Status == models.TaskEndAborted {
+		if err := canary.Update(ctx, db, map[string]interface{}{
+			"status":        models.CanaryTaskAborted,
+			"selected_node": task.SelectedNode,
+			"resolved_time": sql.NullTime{Time: utils.Now(), Valid: true},
+		}); err != nil {
+			return err
+		}
+		*originCanary = canary
+		return nil
+	}
+	if task.Status != models.TaskScoreReady && task.Status != models.TaskErrorReported {
+		return nil
+	}
+
+	reference := &models.InferenceTask{
+		TaskIDCommitment:   task.TaskIDCommitment,
+		TaskType:           task.TaskType,
+		Status:             models.TaskScoreReady,
+		Score:              canary.ReferenceScore,
+		Validator:          task.Validator,
+		ValidatorThreshold: task.ValidatorThreshold,
+	}
+	comparison := compareTaskScore(reference, task)
+
+	node, err := models.GetNodeByAddress(ctx, db, task.SelectedNode)
+	if err != nil {
+		return err
+	}
+
+	record := newValidationRecord(task, task.TaskID, false)
+	record.Comparisons = models.ValidationComparisons{comparison}
+
+	appConfig := config.GetConfig()
+	status := models.CanaryTaskPassed
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if comparison.Same {
+			if err := SetTaskStatusValidated(ctx, tx, task); err != nil {
+				return err
+			}
+			if err := updateNodeQosScore(ctx, tx, node, MAX_TASK_QOS_SCORE); err != nil {
+				return err
+			}
+			record.QOSScore = sql.NullInt64{Int64: int64(MAX_TASK_QOS_SCORE), Valid: true}
+		} else {
+			status = models.CanaryTaskFailed
+			task.QOSScore = sql.NullInt64{Int64: 0, Valid: true}
+			task.AbortReason = models.TaskAbortIncorrectResult
+			task.ValidatedTime = sql.NullTime{Time: utils.Now(), Valid: true}
+			if appConfig.Canary.Slash {
+				if err := SetTaskStatusEndInvalidated(ctx, tx, task); err != nil {
+					return err
+				}
+			} else {
+				if err := SetTaskStatusEndAborted(ctx, tx, task, appConfig.Blockchain.Account.Address); err != nil {
+					return err
+				}
+			}
+			record.QOSScore = task.QOSScore
+		}
+
+		record.Verdict = task.Status
+		if err := record.Create(ctx, tx); err != nil {
+			return err
+		}
+		return canary.Update(ctx, tx, map[string]interface{}{
+			"status":        status,
+			"selected_node": task.SelectedNode,
+			"resolved_time": sql.NullTime{Time: utils.Now(), Valid: true},
+		})
+	}); err != nil {
+		return err
+	}
+	*originCanary = canary
+	return nil
+}
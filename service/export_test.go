@@ -0,0 +1,4 @@
+package service
+
+// exported for the tests in service_test
+var SelectNodeForInferenceTask = selectNodeForInferenceTask
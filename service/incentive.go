@@ -3,6 +3,8 @@ package service
 import (
 	"context"
 	"crynux_relay/models"
+	"crynux_relay/utils"
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
@@ -12,7 +14,12 @@ func addNodeIncentive(ctx context.Context, db *gorm.DB, nodeAddress string, ince
 	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	t := time.Now().UTC().Truncate(24 * time.Hour)
+	spec, ok := models.GetTaskTypeSpec(taskType)
+	if !ok {
+		return errors.New("unsupported task type")
+	}
+
+	t := utils.Now().UTC().Truncate(24 * time.Hour)
 	nodeIncentive := models.NodeIncentive{Time: t, NodeAddress: nodeAddress}
 	if err := db.WithContext(ctx).Model(&nodeIncentive).Where(&nodeIncentive).First(&nodeIncentive).Error; err != nil {
 		if err != gorm.ErrRecordNotFound {
@@ -22,26 +29,14 @@ func addNodeIncentive(ctx context.Context, db *gorm.DB, nodeAddress string, ince
 	if nodeIncentive.ID > 0 {
 		nodeIncentive.Incentive += incentive
 		nodeIncentive.TaskCount += 1
-		if taskType == models.TaskTypeSD {
-			nodeIncentive.SDTaskCount += 1
-		} else if taskType == models.TaskTypeLLM {
-			nodeIncentive.LLMTaskCount += 1
-		} else if taskType == models.TaskTypeSDFTLora {
-			nodeIncentive.SDFTLoraTaskCount += 1
-		}
+		spec.IncreaseIncentiveTaskCount(&nodeIncentive)
 		if err := db.WithContext(dbCtx).Save(&nodeIncentive).Error; err != nil {
 			return err
 		}
 	} else {
 		nodeIncentive.Incentive = incentive
 		nodeIncentive.TaskCount = 1
-		if taskType == models.TaskTypeSD {
-			nodeIncentive.SDTaskCount = 1
-		} else if taskType == models.TaskTypeLLM {
-			nodeIncentive.LLMTaskCount = 1
-		} else if taskType == models.TaskTypeSDFTLora {
-			nodeIncentive.SDFTLoraTaskCount = 1
-		}
+		spec.IncreaseIncentiveTaskCount(&nodeIncentive)
 		if err := db.WithContext(dbCtx).Create(&nodeIncentive).Error; err != nil {
 			return err
 		}
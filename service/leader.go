@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const leaderLeaseName = "leader"
+
+// RunAsLeader runs fn while this relay instance holds the leader lease, and blocks until ctx is done.
+// The lease is renewed every third of its ttl, and the context of fn is cancelled as soon as a renewal fails,
+// so that fn stops before another instance can take the expired lease.
+// fn should return after its context is done.
+func RunAsLeader(ctx context.Context, db *gorm.DB, fn func(ctx context.Context)) {
+	appConfig := config.GetConfig()
+	holder := appConfig.Leader.InstanceID
+	ttl := time.Duration(appConfig.Leader.LeaseSeconds) * time.Second
+
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	// stop cancels fn and waits for it to return, it is nil when this instance is not the leader
+	var stop func()
+	stepDown := func() {
+		if stop != nil {
+			stop()
+			stop = nil
+			log.Infof("Leader: instance %s is not the leader anymore", holder)
+		}
+	}
+
+	for {
+		acquired, err := models.AcquireLease(ctx, db, leaderLeaseName, holder, ttl)
+		if err != nil {
+			log.Errorf("Leader: acquire lease error: %v", err)
+		}
+		if acquired && stop == nil {
+			log.Infof("Leader: instance %s becomes the leader", holder)
+			leaderCtx, cancel := context.WithCancel(ctx)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				fn(leaderCtx)
+			}()
+			stop = func() {
+				cancel()
+				<-done
+			}
+		} else if !acquired {
+			stepDown()
+		}
+
+		select {
+		case <-ctx.Done():
+			stepDown()
+			releaseCtx, releaseCancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := models.ReleaseLease(releaseCtx, db, leaderLeaseName, holder); err != nil {
+				log.Errorf("Leader: release lease error: %v", err)
+			}
+			releaseCancel()
+			return
+		case <-ticker.C:
+		}
+	}
+}
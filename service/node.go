@@ -4,25 +4,30 @@ import (
 	"context"
 	"crynux_relay/config"
 	"crynux_relay/models"
+	"crynux_relay/utils"
 	"database/sql"
 	"errors"
 	"math/big"
-	"time"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
-func SetNodeStatusJoin(ctx context.Context, db *gorm.DB, node *models.Node, modelIDs []string) error {
+// SetNodeStatusJoin takes the stake from the node and makes it available.
+// The hardware profile is made from the gpu name of the node if the node does not report one.
+func SetNodeStatusJoin(ctx context.Context, db *gorm.DB, node *models.Node, modelIDs []string, hardware *models.NodeHardware) error {
 	appConfig := config.GetConfig()
 
 	return db.Transaction(func(tx *gorm.DB) error {
-		commitFunc, err := Transfer(ctx, tx, node.Address, appConfig.Blockchain.Account.Address, &node.StakeAmount.Int)
-		if err != nil {
+		if err := Transfer(ctx, tx, node.Address, appConfig.Blockchain.Account.Address, &node.StakeAmount.Int); err != nil {
 			return err
 		}
 		node.Status = models.NodeStatusAvailable
-		node.JoinTime = time.Now()
+		node.JoinTime = utils.Now()
+		// the first join time is kept when the node quits and joins again, until the node is slashed
+		if !node.FirstJoinTime.Valid {
+			node.FirstJoinTime = sql.NullTime{Time: node.JoinTime, Valid: true}
+		}
 		if err := node.Save(ctx, tx); err != nil {
 			return err
 		}
@@ -34,6 +39,16 @@ func SetNodeStatusJoin(ctx context.Context, db *gorm.DB, node *models.Node, mode
 		if err := models.CreateNodeModels(ctx, tx, nodeModels); err != nil {
 			return err
 		}
+		if hardware == nil {
+			hardware = models.NewNodeHardwareFromGPUName(node.Address, node.GPUName, node.GPUVram)
+		}
+		hardware.NodeAddress = node.Address
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "node_address"}},
+			UpdateAll: true,
+		}).Create(hardware).Error; err != nil {
+			return err
+		}
 		networkNodeData := models.NetworkNodeData{
 			Address:   node.Address,
 			CardModel: node.GPUName,
@@ -48,7 +63,6 @@ func SetNodeStatusJoin(ctx context.Context, db *gorm.DB, node *models.Node, mode
 			return err
 		}
 		UpdateMaxStaking(&node.StakeAmount.Int)
-		commitFunc()
 		return nil
 	})
 }
@@ -57,7 +71,6 @@ func SetNodeStatusQuit(ctx context.Context, db *gorm.DB, node *models.Node, slas
 	appConfig := config.GetConfig()
 
 	err := db.Transaction(func(tx *gorm.DB) error {
-		var commitFunc func()
 		// delete all node local models
 		err := tx.Where("node_address = ?", node.Address).Delete(&models.NodeModel{}).Error
 		if err != nil {
@@ -65,26 +78,27 @@ func SetNodeStatusQuit(ctx context.Context, db *gorm.DB, node *models.Node, slas
 		}
 
 		if !slashed {
-			commitFunc, err = Transfer(ctx, tx, appConfig.Blockchain.Account.Address, node.Address, &node.StakeAmount.Int)
-			if err != nil {
+			if err := Transfer(ctx, tx, appConfig.Blockchain.Account.Address, node.Address, &node.StakeAmount.Int); err != nil {
 				return err
 			}
 		}
 
-		if err := node.Update(ctx, tx, map[string]interface{}{
+		values := map[string]interface{}{
 			"status":                     models.NodeStatusQuit,
 			"qos_score":                  0,
 			"current_task_id_commitment": sql.NullString{Valid: false},
 			"stake_amount":               models.BigInt{Int: *big.NewInt(0)},
-		}); err != nil {
+		}
+		// a slashed node starts over as a new node when it joins again
+		if slashed {
+			values["first_join_time"] = sql.NullTime{}
+		}
+		if err := node.Update(ctx, tx, values); err != nil {
 			return err
 		}
 		if err := RefreshMaxStaking(ctx, tx); err != nil {
 			return err
 		}
-		if commitFunc != nil {
-			commitFunc()
-		}	
 		return nil
 	})
 	if err != nil {
@@ -152,7 +166,7 @@ func nodeFinishTask(ctx context.Context, db *gorm.DB, node *models.Node) error {
 	if !(node.Status == models.NodeStatusBusy || node.Status == models.NodeStatusPendingPause || node.Status == models.NodeStatusPendingQuit) {
 		return errors.New("illegal node status")
 	}
-	kickout, err := shouldKickoutNode(ctx, node)
+	kickout, err := shouldKickoutNode(ctx, db, node)
 	if err != nil {
 		return err
 	}
@@ -182,24 +196,45 @@ func nodeFinishTask(ctx context.Context, db *gorm.DB, node *models.Node) error {
 	return nil
 }
 
+// nodeSlash forfeits the stake of the node and makes it quit.
+// A node can also be slashed after it finishes the task, when the dispute of the task is upheld.
+// The unfinished task of a busy node is requeued to another node if it has attempts left, otherwise it is aborted.
 func nodeSlash(ctx context.Context, db *gorm.DB, node *models.Node) error {
-	if !(node.Status == models.NodeStatusBusy || node.Status == models.NodeStatusPendingPause || node.Status == models.NodeStatusPendingQuit) {
+	if node.Status == models.NodeStatusQuit {
 		return errors.New("illegal node status")
 	}
+	currentTaskIDCommitment := node.CurrentTaskIDCommitment
 	return db.Transaction(func(tx *gorm.DB) error {
-		if err := SetNodeStatusQuit(ctx, db, node, true); err != nil {
+		if err := SetNodeStatusQuit(ctx, tx, node, true); err != nil {
 			return err
 		}
-		return emitEvent(ctx, db, &models.NodeSlashedEvent{NodeAddress: node.Address})
+		if err := emitEvent(ctx, tx, &models.NodeSlashedEvent{NodeAddress: node.Address}); err != nil {
+			return err
+		}
+		if !currentTaskIDCommitment.Valid {
+			return nil
+		}
+		task, err := models.GetTaskByIDCommitment(ctx, tx, currentTaskIDCommitment.String)
+		if err != nil {
+			return err
+		}
+		// the task the node is slashed for has ended already
+		if _, err := getTaskTransition(task, models.TaskStatusEventAbort); err != nil {
+			return nil
+		}
+		task.AbortReason = models.TaskAbortNodeSlashed
+		task.ValidatedTime = sql.NullTime{Time: utils.Now(), Valid: true}
+		return AbortTask(ctx, tx, task, config.GetConfig().Blockchain.Account.Address)
 	})
 }
 
 func updateNodeQosScore(ctx context.Context, db *gorm.DB, node *models.Node, qos uint64) error {
-	qosScore, err := getNodeTaskQosScore(node, qos)
+	qosScore, qosScorePool, err := getNodeTaskQosScore(ctx, db, node, qos)
 	if err != nil {
 		return err
 	}
 	return node.Update(ctx, db, map[string]interface{}{
-		"qos_score": qosScore,
+		"qos_score":      qosScore,
+		"qos_score_pool": qosScorePool,
 	})
 }
@@ -0,0 +1,467 @@
+package service
+
+import (
+	"crynux_relay/models"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const maxNodeRequirementLength = 512
+
+type requirementFieldKind uint8
+
+const (
+	requirementString requirementFieldKind = iota
+	requirementNumber
+	requirementVersion
+	requirementList
+)
+
+type requirementField struct {
+	kind requirementFieldKind
+	get  func(hardware *models.NodeHardware) interface{}
+}
+
+var requirementFields = map[string]requirementField{
+	"vendor":    {requirementString, func(hardware *models.NodeHardware) interface{} { return hardware.Vendor }},
+	"model":     {requirementString, func(hardware *models.NodeHardware) interface{} { return hardware.GPUModel }},
+	"platform":  {requirementString, func(hardware *models.NodeHardware) interface{} { return hardware.Platform }},
+	"vram":      {requirementNumber, func(hardware *models.NodeHardware) interface{} { return hardware.VRAM }},
+	"count":     {requirementNumber, func(hardware *models.NodeHardware) interface{} { return hardware.GPUCount }},
+	"disk":      {requirementNumber, func(hardware *models.NodeHardware) interface{} { return hardware.DiskSpace }},
+	"driver":    {requirementVersion, func(hardware *models.NodeHardware) interface{} { return hardware.DriverVersion }},
+	"cuda":      {requirementVersion, func(hardware *models.NodeHardware) interface{} { return hardware.CUDAVersion }},
+	"precision": {requirementList, func(hardware *models.NodeHardware) interface{} { return []string(hardware.Precisions) }},
+	"task_type": {requirementList, func(hardware *models.NodeHardware) interface{} { return []string(hardware.TaskTypes) }},
+}
+
+var requirementFieldAliases = map[string]string{
+	"os":         "platform",
+	"gpu":        "model",
+	"gpu_count":  "count",
+	"disk_space": "disk",
+	"precisions": "precision",
+	"task_types": "task_type",
+}
+
+// NodeRequirement is a parsed requirement expression on the node hardware, like "platform != darwin && cuda >= 12".
+//
+// An expression is comparisons of a field with a value, combined by &&, ||, ! and parentheses.
+// The operators are ==, !=, >, >=, < and <=, and the fields are:
+//   - vendor, model, platform: compared case insensitively, only by == and !=
+//   - vram, disk (in GB), count: compared as numbers
+//   - driver, cuda: compared as versions, like 12.4
+//   - precision, task_type: lists, == tests whether the list contains the value, and != the opposite
+//
+// A comparison with a value the node does not report, like cuda on a Darwin node, is always false.
+type NodeRequirement struct {
+	src  string
+	expr requirementExpr
+}
+
+func (r *NodeRequirement) String() string {
+	return r.src
+}
+
+func (r *NodeRequirement) Match(hardware *models.NodeHardware) bool {
+	return r.expr.eval(hardware)
+}
+
+func ParseNodeRequirement(s string) (*NodeRequirement, error) {
+	if len(s) > maxNodeRequirementLength {
+		return nil, fmt.Errorf("requirement is longer than %d characters", maxNodeRequirementLength)
+	}
+	tokens, err := tokenizeRequirement(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("empty requirement")
+	}
+	p := &requirementParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return &NodeRequirement{src: s, expr: expr}, nil
+}
+
+type requirementTokenKind uint8
+
+const (
+	tokenWord requirementTokenKind = iota
+	tokenString
+	tokenOp
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLeftParen
+	tokenRightParen
+)
+
+type requirementToken struct {
+	kind requirementTokenKind
+	text string
+}
+
+func isRequirementWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("._-+", c) >= 0
+}
+
+func tokenizeRequirement(s string) ([]requirementToken, error) {
+	var tokens []requirementToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, requirementToken{tokenLeftParen, "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, requirementToken{tokenRightParen, ")"})
+			i++
+		case strings.HasPrefix(s[i:], "&&"):
+			tokens = append(tokens, requirementToken{tokenAnd, "&&"})
+			i += 2
+		case strings.HasPrefix(s[i:], "||"):
+			tokens = append(tokens, requirementToken{tokenOr, "||"})
+			i += 2
+		case strings.HasPrefix(s[i:], "=="), strings.HasPrefix(s[i:], "!="), strings.HasPrefix(s[i:], ">="), strings.HasPrefix(s[i:], "<="):
+			tokens = append(tokens, requirementToken{tokenOp, s[i : i+2]})
+			i += 2
+		case c == '>' || c == '<':
+			tokens = append(tokens, requirementToken{tokenOp, s[i : i+1]})
+			i++
+		case c == '=':
+			tokens = append(tokens, requirementToken{tokenOp, "=="})
+			i++
+		case c == '!':
+			tokens = append(tokens, requirementToken{tokenNot, "!"})
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				return nil, errors.New("unterminated string")
+			}
+			tokens = append(tokens, requirementToken{tokenString, s[i+1 : i+1+end]})
+			i += end + 2
+		case isRequirementWordChar(c):
+			j := i
+			for j < len(s) && isRequirementWordChar(s[j]) {
+				j++
+			}
+			word := s[i:j]
+			switch strings.ToLower(word) {
+			case "and":
+				tokens = append(tokens, requirementToken{tokenAnd, word})
+			case "or":
+				tokens = append(tokens, requirementToken{tokenOr, word})
+			case "not":
+				tokens = append(tokens, requirementToken{tokenNot, word})
+			default:
+				tokens = append(tokens, requirementToken{tokenWord, word})
+			}
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q", c)
+		}
+	}
+	return tokens, nil
+}
+
+type requirementExpr interface {
+	eval(hardware *models.NodeHardware) bool
+}
+
+type requirementAnd []requirementExpr
+
+func (e requirementAnd) eval(hardware *models.NodeHardware) bool {
+	for _, sub := range e {
+		if !sub.eval(hardware) {
+			return false
+		}
+	}
+	return true
+}
+
+type requirementOr []requirementExpr
+
+func (e requirementOr) eval(hardware *models.NodeHardware) bool {
+	for _, sub := range e {
+		if sub.eval(hardware) {
+			return true
+		}
+	}
+	return false
+}
+
+type requirementNot struct {
+	expr requirementExpr
+}
+
+func (e requirementNot) eval(hardware *models.NodeHardware) bool {
+	return !e.expr.eval(hardware)
+}
+
+type requirementComparison struct {
+	field   requirementField
+	op      string
+	value   string
+	number  uint64
+	version []uint64
+}
+
+func (e *requirementComparison) eval(hardware *models.NodeHardware) bool {
+	switch e.field.kind {
+	case requirementString:
+		v := e.field.get(hardware).(string)
+		if len(v) == 0 {
+			return false
+		}
+		return strings.EqualFold(v, e.value) == (e.op == "==")
+	case requirementNumber:
+		v := e.field.get(hardware).(uint64)
+		if v == 0 {
+			return false
+		}
+		return compareRequirement(compareUint64(v, e.number), e.op)
+	case requirementVersion:
+		v, err := parseRequirementVersion(e.field.get(hardware).(string))
+		if err != nil {
+			return false
+		}
+		return compareRequirement(compareVersions(v, e.version), e.op)
+	case requirementList:
+		list := e.field.get(hardware).([]string)
+		if len(list) == 0 {
+			return false
+		}
+		contains := false
+		for _, item := range list {
+			if strings.EqualFold(item, e.value) {
+				contains = true
+				break
+			}
+		}
+		return contains == (e.op == "==")
+	}
+	return false
+}
+
+func compareUint64(a, b uint64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func compareVersions(a, b []uint64) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y uint64
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if c := compareUint64(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareRequirement(c int, op string) bool {
+	switch op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	}
+	return false
+}
+
+func isRequirementDigits(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func parseRequirementVersion(s string) ([]uint64, error) {
+	if len(s) == 0 {
+		return nil, errors.New("empty version")
+	}
+	parts := strings.Split(s, ".")
+	res := make([]uint64, len(parts))
+	for i, part := range parts {
+		v, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q", s)
+		}
+		res[i] = v
+	}
+	return res, nil
+}
+
+type requirementParser struct {
+	tokens []requirementToken
+	pos    int
+}
+
+func (p *requirementParser) next() (requirementToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return requirementToken{}, false
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	return token, true
+}
+
+func (p *requirementParser) peek(kind requirementTokenKind) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == kind
+}
+
+func (p *requirementParser) parseOr() (requirementExpr, error) {
+	expr, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	exprs := requirementOr{expr}
+	for p.peek(tokenOr) {
+		p.pos++
+		expr, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return exprs, nil
+}
+
+func (p *requirementParser) parseAnd() (requirementExpr, error) {
+	expr, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	exprs := requirementAnd{expr}
+	for p.peek(tokenAnd) {
+		p.pos++
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return exprs, nil
+}
+
+func (p *requirementParser) parseUnary() (requirementExpr, error) {
+	token, ok := p.next()
+	if !ok {
+		return nil, errors.New("unexpected end of requirement")
+	}
+	switch token.kind {
+	case tokenNot:
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return requirementNot{expr}, nil
+	case tokenLeftParen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peek(tokenRightParen) {
+			return nil, errors.New("missing )")
+		}
+		p.pos++
+		return expr, nil
+	case tokenWord:
+		return p.parseComparison(token.text)
+	}
+	return nil, fmt.Errorf("unexpected %q", token.text)
+}
+
+func (p *requirementParser) parseComparison(name string) (requirementExpr, error) {
+	fieldName := strings.ToLower(name)
+	if alias, ok := requirementFieldAliases[fieldName]; ok {
+		fieldName = alias
+	}
+	field, ok := requirementFields[fieldName]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", name)
+	}
+
+	op, ok := p.next()
+	if !ok || op.kind != tokenOp {
+		return nil, fmt.Errorf("missing operator after %q", name)
+	}
+	value, ok := p.next()
+	if !ok || (value.kind != tokenWord && value.kind != tokenString) {
+		return nil, fmt.Errorf("missing value after %q", name+" "+op.text)
+	}
+
+	e := &requirementComparison{field: field, op: op.text, value: value.text}
+	switch field.kind {
+	case requirementString, requirementList:
+		if op.text != "==" && op.text != "!=" {
+			return nil, fmt.Errorf("field %q can only be compared by == and !=", name)
+		}
+		if fieldName == "task_type" {
+			// task types can be given by number or by name
+			if isRequirementDigits(value.text) {
+				n, err := strconv.ParseUint(value.text, 10, 64)
+				if err != nil || n > 255 {
+					return nil, fmt.Errorf("unknown task type %q", value.text)
+				}
+				spec, ok := models.GetTaskTypeSpec(models.TaskType(n))
+				if !ok {
+					return nil, fmt.Errorf("unknown task type %q", value.text)
+				}
+				e.value = spec.Name
+			}
+		}
+	case requirementNumber:
+		n, err := strconv.ParseUint(value.text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("field %q should be compared with a number", name)
+		}
+		e.number = n
+	case requirementVersion:
+		v, err := parseRequirementVersion(value.text)
+		if err != nil {
+			return nil, fmt.Errorf("field %q should be compared with a version", name)
+		}
+		e.version = v
+	}
+	return e, nil
+}
@@ -0,0 +1,104 @@
+package service_test
+
+import (
+	"crynux_relay/models"
+	"crynux_relay/service"
+	"testing"
+)
+
+func TestNodeRequirement(t *testing.T) {
+	linux := &models.NodeHardware{
+		Vendor:        "nvidia",
+		GPUModel:      "NVIDIA GeForce RTX 4090",
+		VRAM:          24,
+		GPUCount:      2,
+		Platform:      "linux",
+		DriverVersion: "535.104.05",
+		CUDAVersion:   "12.2",
+		Precisions:    []string{"fp32", "fp16", "bf16"},
+		DiskSpace:     500,
+	}
+	darwin := models.NewNodeHardwareFromGPUName("0x01", "Apple M2 Max Type+Darwin", 32)
+
+	cases := []struct {
+		requirement string
+		linux       bool
+		darwin      bool
+	}{
+		{"platform != darwin", true, false},
+		{"os == Darwin", false, true},
+		{"cuda >= 12", true, false},
+		{"cuda >= 12.3", false, false},
+		{"cuda < 12.3 && driver >= 535", true, false},
+		{"!(cuda >= 12)", false, true},
+		{"vram >= 24", true, true},
+		{"vram > 24 || count >= 2", true, true},
+		{"vram > 24 && count >= 2", false, false},
+		{"vendor == apple or platform == linux and disk >= 100", true, true},
+		{"(vendor == apple or platform == linux) and disk >= 100", true, false},
+		{"precision == fp16 && precisions != int8", true, false},
+		{`model == "NVIDIA GeForce RTX 4090"`, true, false},
+		{"gpu == 'Apple M2 Max Type'", false, true},
+	}
+	for _, c := range cases {
+		requirement, err := service.ParseNodeRequirement(c.requirement)
+		if err != nil {
+			t.Fatalf("Parse requirement %q error: %v", c.requirement, err)
+		}
+		if res := requirement.Match(linux); res != c.linux {
+			t.Fatalf("Requirement %q on linux node should be %v", c.requirement, c.linux)
+		}
+		if res := requirement.Match(darwin); res != c.darwin {
+			t.Fatalf("Requirement %q on darwin node should be %v", c.requirement, c.darwin)
+		}
+	}
+}
+
+func TestNodeRequirementTaskType(t *testing.T) {
+	hardware := &models.NodeHardware{TaskTypes: []string{"stable_diffusion_inference"}}
+
+	for requirement, expected := range map[string]bool{
+		"task_type == stable_diffusion_inference": true,
+		"task_type == 0":             true,
+		"task_types == 1":            false,
+		"task_type != gpt_inference": true,
+	} {
+		r, err := service.ParseNodeRequirement(requirement)
+		if err != nil {
+			t.Fatalf("Parse requirement %q error: %v", requirement, err)
+		}
+		if r.Match(hardware) != expected {
+			t.Fatalf("Requirement %q should be %v", requirement, expected)
+		}
+	}
+
+	if !hardware.SupportsTaskType(models.TaskTypeSD) || hardware.SupportsTaskType(models.TaskTypeLLM) {
+		t.Fatalf("Wrong supported task types")
+	}
+	if !(&models.NodeHardware{}).SupportsTaskType(models.TaskTypeLLM) {
+		t.Fatalf("Node not reporting task types should support all task types")
+	}
+}
+
+func TestInvalidNodeRequirement(t *testing.T) {
+	for _, requirement := range []string{
+		"",
+		"platform",
+		"platform !=",
+		"platform != darwin &&",
+		"(cuda >= 12",
+		"cuda >= 12)",
+		"gpu_temperature < 80",
+		"platform > darwin",
+		"vram >= lots",
+		"cuda >= twelve",
+		"task_type == 100",
+		"task_type == 300",
+		"model == 'RTX",
+		"vram >= 24 ; drop",
+	} {
+		if _, err := service.ParseNodeRequirement(requirement); err == nil {
+			t.Fatalf("Invalid requirement %q is parsed", requirement)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package service
+
+import (
+	"context"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"crynux_relay/utils"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// NodeWeight is the weight of a candidate node in a selection, and the factors the weight is made of
+type NodeWeight struct {
+	Address string
+	Weight  float64
+	Factors map[string]float64
+}
+
+type NodeSelection struct {
+	Node    *models.Node
+	Weights []NodeWeight
+}
+
+// NodeSelector picks the node to run the task from the candidate nodes, which all meet the requirements of the task
+type NodeSelector interface {
+	Name() string
+	Select(ctx context.Context, task *models.InferenceTask, nodes []models.Node) (*NodeSelection, error)
+}
+
+var (
+	nodeSelector     NodeSelector
+	nodeSelectorOnce sync.Once
+)
+
+func getNodeSelector() NodeSelector {
+	nodeSelectorOnce.Do(func() {
+		switch config.GetConfig().NodeSelection.Selector {
+		case "lowest_latency":
+			nodeSelector = newLowestLatencySelector(config.GetDB())
+		case "model_locality":
+			nodeSelector = &modelLocalitySelector{}
+		case "round_robin":
+			nodeSelector = &roundRobinSelector{}
+		default:
+			nodeSelector = &weightedSelector{}
+		}
+	})
+	return nodeSelector
+}
+
+func logNodeSelection(task *models.InferenceTask, selector NodeSelector, selection *NodeSelection) {
+	if !log.IsLevelEnabled(log.DebugLevel) {
+		return
+	}
+	weights := make([]string, 0, len(selection.Weights))
+	for _, w := range selection.Weights {
+		factors := make([]string, 0, len(w.Factors))
+		for name, factor := range w.Factors {
+			factors = append(factors, fmt.Sprintf("%s=%g", name, factor))
+		}
+		sort.Strings(factors)
+		weights = append(weights, fmt.Sprintf("%s:%g(%s)", w.Address, w.Weight, strings.Join(factors, ",")))
+	}
+	log.Debugf("SelectNode: node %s is selected for task %s by %s selector, candidates: %s",
+		selection.Node.Address, task.TaskIDCommitment, selector.Name(), strings.Join(weights, " "))
+}
+
+func getNodeModelIDs(node *models.Node) ([]string, []string) {
+	localModelIDs := make([]string, 0)
+	inUseModelIDs := make([]string, 0)
+	for _, model := range node.Models {
+		localModelIDs = append(localModelIDs, model.ModelID)
+		if model.InUse {
+			inUseModelIDs = append(inUseModelIDs, model.ModelID)
+		}
+	}
+	return localModelIDs, inUseModelIDs
+}
+
+func getNodeProbWeight(node *models.Node) NodeWeight {
+	stakingScore, qosScore, prob := CalculateSelectingProb(&node.StakeAmount.Int, GetMaxStaking(), node.QOSScore, GetMaxQosScore())
+	return NodeWeight{
+		Address: node.Address,
+		Weight:  prob,
+		Factors: map[string]float64{
+			"staking": stakingScore,
+			"qos":     qosScore,
+		},
+	}
+}
+
+func selectNodeByWeights(nodes []models.Node, weights []NodeWeight) *NodeSelection {
+	scores := make([]float64, len(weights))
+	total := 0.0
+	for i := range weights {
+		scores[i] = weights[i].Weight
+		total += scores[i]
+	}
+	// nodes are sampled uniformly if none of them has a weight
+	if total == 0 {
+		for i := range scores {
+			scores[i] = 1
+		}
+	}
+	node := selectNodesByScore(nodes, scores, 1)[0]
+	return &NodeSelection{Node: &node, Weights: weights}
+}
+
+// LocalityBonus is how much the weight of a node is raised by the weighted selector when it has all the task models in use
+var LocalityBonus float64 = 1
+
+// weightedSelector samples nodes by the selecting probability of their staking and qos score.
+// Nodes with local task models are preferred, and their weights are raised by the locality bonus times the share of local task models,
+// or by the full locality bonus if the models in use are the same as the task models.
+type weightedSelector struct{}
+
+func (s *weightedSelector) Name() string {
+	return "weighted"
+}
+
+func (s *weightedSelector) Select(ctx context.Context, task *models.InferenceTask, nodes []models.Node) (*NodeSelection, error) {
+	weights := make([]NodeWeight, len(nodes))
+	for i := range nodes {
+		weights[i] = getNodeProbWeight(&nodes[i])
+	}
+
+	changedNodes := make([]models.Node, 0)
+	changedWeights := make([]NodeWeight, 0)
+	for i, node := range nodes {
+		localModelIDs, inUseModelIDs := getNodeModelIDs(&node)
+
+		// add additional qos score to nodes with local task models
+		cnt := matchModels(localModelIDs, task.ModelIDs)
+		if cnt > 0 {
+			changedNodes = append(changedNodes, node)
+			locality := 1 + LocalityBonus*float64(cnt)/float64(len(task.ModelIDs))
+			if isSameModels(inUseModelIDs, task.ModelIDs) {
+				locality = 1 + LocalityBonus
+			}
+			weight := weights[i]
+			weight.Weight *= locality
+			weight.Factors["locality"] = locality
+			changedWeights = append(changedWeights, weight)
+		}
+	}
+
+	if len(changedNodes) > 0 {
+		nodes = changedNodes
+		weights = changedWeights
+	}
+	return selectNodeByWeights(nodes, weights), nil
+}
+
+// modelLocalitySelector only selects from the nodes with the best model locality:
+// nodes using the task models, then nodes with all task models local, then nodes with the most task models local.
+// Nodes of the same locality are sampled by the selecting probability.
+type modelLocalitySelector struct{}
+
+func (s *modelLocalitySelector) Name() string {
+	return "model_locality"
+}
+
+func (s *modelLocalitySelector) Select(ctx context.Context, task *models.InferenceTask, nodes []models.Node) (*NodeSelection, error) {
+	localities := make([]float64, len(nodes))
+	bestLocality := 0.0
+	for i := range nodes {
+		localModelIDs, inUseModelIDs := getNodeModelIDs(&nodes[i])
+		locality := float64(matchModels(localModelIDs, task.ModelIDs))
+		if len(task.ModelIDs) > 0 && isSameModels(inUseModelIDs, task.ModelIDs) {
+			locality += 1
+		}
+		localities[i] = locality
+		if locality > bestLocality {
+			bestLocality = locality
+		}
+	}
+
+	var bestNodes []models.Node
+	var weights []NodeWeight
+	for i := range nodes {
+		if localities[i] == bestLocality {
+			weight := getNodeProbWeight(&nodes[i])
+			weight.Factors["locality"] = localities[i]
+			bestNodes = append(bestNodes, nodes[i])
+			weights = append(weights, weight)
+		}
+	}
+	return selectNodeByWeights(bestNodes, weights), nil
+}
+
+// roundRobinSelector selects the candidate nodes in turn by address, regardless of their scores. It is meant for testing.
+type roundRobinSelector struct {
+	mu    sync.Mutex
+	count int
+}
+
+func (s *roundRobinSelector) Name() string {
+	return "round_robin"
+}
+
+func (s *roundRobinSelector) Select(ctx context.Context, task *models.InferenceTask, nodes []models.Node) (*NodeSelection, error) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Address < nodes[j].Address
+	})
+	s.mu.Lock()
+	idx := s.count % len(nodes)
+	s.count++
+	s.mu.Unlock()
+
+	weights := make([]NodeWeight, len(nodes))
+	for i := range nodes {
+		weights[i] = NodeWeight{Address: nodes[i].Address}
+	}
+	weights[idx].Weight = 1
+	return &NodeSelection{Node: &nodes[idx], Weights: weights}, nil
+}
+
+// lowestLatencySelector selects from the nodes whose gpu has the lowest mean execution time of the task type in the last day.
+// Gpus without history are taken as the mean of all gpus. Nodes of the same gpu are sampled by the selecting probability.
+type lowestLatencySelector struct {
+	db *gorm.DB
+
+	mu         sync.Mutex
+	latencies  map[models.TaskType]map[string]float64
+	updateTime map[models.TaskType]time.Time
+}
+
+func newLowestLatencySelector(db *gorm.DB) *lowestLatencySelector {
+	return &lowestLatencySelector{
+		db:         db,
+		latencies:  make(map[models.TaskType]map[string]float64),
+		updateTime: make(map[models.TaskType]time.Time),
+	}
+}
+
+func (s *lowestLatencySelector) Name() string {
+	return "lowest_latency"
+}
+
+func gpuKey(gpuName string, gpuVram uint64) string {
+	return fmt.Sprintf("%s+%d", gpuName, gpuVram)
+}
+
+// getGPULatencies returns the mean execution seconds of the task type by gpu, cached for a minute
+func (s *lowestLatencySelector) getGPULatencies(ctx context.Context, taskType models.TaskType) (map[string]float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if latencies, ok := s.latencies[taskType]; ok && utils.Now().Sub(s.updateTime[taskType]) < time.Minute {
+		return latencies, nil
+	}
+
+	type result struct {
+		GPUName        string
+		GPUVram        uint64
+		StartTime      time.Time
+		ScoreReadyTime time.Time
+	}
+	var results []result
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := s.db.WithContext(dbCtx).Model(&models.InferenceTask{}).
+		Select("nodes.gpu_name, nodes.gpu_vram, inference_tasks.start_time, inference_tasks.score_ready_time").
+		Joins("INNER JOIN nodes ON nodes.address = inference_tasks.selected_node").
+		Where("inference_tasks.task_type = ?", taskType).
+		Where("inference_tasks.score_ready_time >= ?", utils.Now().Add(-24*time.Hour)).
+		Where("inference_tasks.start_time IS NOT NULL").
+		Order("inference_tasks.id DESC").
+		Limit(1000).
+		Scan(&results).Error; err != nil {
+		return nil, err
+	}
+
+	sums := make(map[string]float64)
+	counts := make(map[string]int)
+	for _, r := range results {
+		key := gpuKey(r.GPUName, r.GPUVram)
+		sums[key] += r.ScoreReadyTime.Sub(r.StartTime).Seconds()
+		counts[key]++
+	}
+	latencies := make(map[string]float64)
+	for key, sum := range sums {
+		latencies[key] = sum / float64(counts[key])
+	}
+	s.latencies[taskType] = latencies
+	s.updateTime[taskType] = utils.Now()
+	return latencies, nil
+}
+
+func (s *lowestLatencySelector) Select(ctx context.Context, task *models.InferenceTask, nodes []models.Node) (*NodeSelection, error) {
+	latencies, err := s.getGPULatencies(ctx, task.TaskType)
+	if err != nil {
+		return nil, err
+	}
+	meanLatency := 0.0
+	for _, latency := range latencies {
+		meanLatency += latency
+	}
+	if len(latencies) > 0 {
+		meanLatency /= float64(len(latencies))
+	}
+
+	nodeLatencies := make([]float64, len(nodes))
+	for i := range nodes {
+		latency, ok := latencies[gpuKey(nodes[i].GPUName, nodes[i].GPUVram)]
+		if !ok {
+			latency = meanLatency
+		}
+		nodeLatencies[i] = latency
+	}
+	lowest := nodeLatencies[0]
+	for _, latency := range nodeLatencies {
+		if latency < lowest {
+			lowest = latency
+		}
+	}
+
+	var fastNodes []models.Node
+	var weights []NodeWeight
+	for i := range nodes {
+		if nodeLatencies[i] == lowest {
+			weight := getNodeProbWeight(&nodes[i])
+			weight.Factors["latency"] = nodeLatencies[i]
+			fastNodes = append(fastNodes, nodes[i])
+			weights = append(weights, weight)
+		}
+	}
+	return selectNodeByWeights(fastNodes, weights), nil
+}
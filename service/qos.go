@@ -2,68 +2,83 @@ package service
 
 import (
 	"context"
-	"crynux_relay/config"
 	"crynux_relay/models"
-	"sync"
+	"sort"
+	"strconv"
 	"time"
-)
 
-const (
-	TASK_SCORE_POOL_SIZE uint64 = 3
-	NODE_QOS_SCORE_POOL_SIZE uint64 = 50
-	KickoutThreshold     uint64 = 2 // if node has 2 tasks timeout in recent 3 tasks, it will be kicked out
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// the qos parameters are variables so that they can be tuned by the simulator.
+// They do not scale with the validation group size: TASK_SCORE_POOL_SIZE and KickoutThreshold count the recent tasks
+// of a single node, whatever the size of their groups, and the task qos score halves by the order in the group,
+// so the 4th and later finished tasks of a large group all get the min score 1.
 var (
-	TASK_SCORE_REWARDS [3]uint64 = [3]uint64{10, 5, 2}
-	nodeQoSScorePool   NodeQosScorePool = NodeQosScorePool{
-		pool: make(map[string][]uint64),
-	}
+	TASK_SCORE_POOL_SIZE     uint64 = 3
+	NODE_QOS_SCORE_POOL_SIZE uint64 = 50
+	KickoutThreshold         uint64 = 2 // if node has 2 tasks timeout in recent 3 tasks, it will be kicked out
+	MAX_TASK_QOS_SCORE       uint64 = 10
 )
 
+// getTaskQosScore returns the qos score of the task finished in the order in its validation group,
+// the score halves for each later order and is at least 1
 func getTaskQosScore(order int) uint64 {
-	return TASK_SCORE_REWARDS[order]
+	score := MAX_TASK_QOS_SCORE >> order
+	if score == 0 {
+		score = 1
+	}
+	return score
 }
 
-type NodeQosScorePool struct {
-	mu sync.RWMutex
-	pool map[string][]uint64
-}
+// getNodeTaskQosScore adds the qos score of a task to the recent task qos scores of the node and returns their average.
+// The recent scores are kept in the node row, which is locked until the transaction ends,
+// so that the scores added by all relay instances are kept.
+func getNodeTaskQosScore(ctx context.Context, db *gorm.DB, node *models.Node, qos uint64) (float64, models.StringArray, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var current models.Node
+	if err := db.WithContext(dbCtx).Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "qos_score", "qos_score_pool").First(&current, node.ID).Error; err != nil {
+		return 0, nil, err
+	}
 
-func getNodeTaskQosScore(node *models.Node, qos uint64) (float64, error) {
-	nodeQoSScorePool.mu.RLock()
-	qosScorePool, ok := nodeQoSScorePool.pool[node.Address]
-	nodeQoSScorePool.mu.RUnlock()
-	if !ok {
-		qosScorePool = make([]uint64, 0)
-		if node.QOSScore > 0 {
-			for i := 0; i < int(NODE_QOS_SCORE_POOL_SIZE) - 1; i++ {
-				qosScorePool = append(qosScorePool, uint64(node.QOSScore))
-			}
+	qosScorePool := make([]uint64, 0, NODE_QOS_SCORE_POOL_SIZE)
+	for _, s := range current.QOSScorePool {
+		score, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return 0, nil, err
+		}
+		qosScorePool = append(qosScorePool, score)
+	}
+	// nodes scored before the recent scores are kept start from their current score
+	if len(qosScorePool) == 0 && current.QOSScore > 0 {
+		for i := 0; i < int(NODE_QOS_SCORE_POOL_SIZE)-1; i++ {
+			qosScorePool = append(qosScorePool, uint64(current.QOSScore))
 		}
 	}
 	qosScorePool = append(qosScorePool, qos)
 	if len(qosScorePool) > int(NODE_QOS_SCORE_POOL_SIZE) {
-		qosScorePool = qosScorePool[1:]
+		qosScorePool = qosScorePool[len(qosScorePool)-int(NODE_QOS_SCORE_POOL_SIZE):]
 	}
 
-	nodeQoSScorePool.mu.Lock()
-	nodeQoSScorePool.pool[node.Address] = qosScorePool
-	nodeQoSScorePool.mu.Unlock()
 	var sum uint64 = 0
-	for _, score := range qosScorePool {
+	pool := make(models.StringArray, len(qosScorePool))
+	for i, score := range qosScorePool {
 		sum += score
+		pool[i] = strconv.FormatUint(score, 10)
 	}
-	return float64(sum) / float64(len(qosScorePool)), nil
+	return float64(sum) / float64(len(qosScorePool)), pool, nil
 }
 
-func shouldKickoutNode(ctx context.Context, node *models.Node) (bool, error) {
+func shouldKickoutNode(ctx context.Context, db *gorm.DB, node *models.Node) (bool, error) {
 	dbCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	var tasks []models.InferenceTask
 
-	err := config.GetDB().WithContext(dbCtx).Unscoped().Model(&models.InferenceTask{}).
+	err := db.WithContext(dbCtx).Unscoped().Model(&models.InferenceTask{}).
 		Where("selected_node = ?", node.Address).
 		Order("id DESC").
 		Limit(int(TASK_SCORE_POOL_SIZE)).
@@ -72,12 +87,39 @@ func shouldKickoutNode(ctx context.Context, node *models.Node) (bool, error) {
 		return false, err
 	}
 
-	timeoutCount := 0
+	// requeued tasks are not selected by the node anymore, their attempts on the node are counted instead
+	attempts, err := models.GetNodeRequeuedTaskAttempts(ctx, db, node.Address, int(TASK_SCORE_POOL_SIZE))
+	if err != nil {
+		return false, err
+	}
+
+	type recentTask struct {
+		startTime time.Time
+		timeout   bool
+	}
+	var recentTasks []recentTask
 	for _, task := range tasks {
 		if task.StartTime.Valid && node.JoinTime.Before(task.StartTime.Time) {
-			if task.Status == models.TaskEndAborted && task.AbortReason == models.TaskAbortTimeout && !task.ScoreReadyTime.Valid {
-				timeoutCount++
-			}
+			timeout := task.Status == models.TaskEndAborted && task.AbortReason == models.TaskAbortTimeout && !task.ScoreReadyTime.Valid
+			recentTasks = append(recentTasks, recentTask{startTime: task.StartTime.Time, timeout: timeout})
+		}
+	}
+	for _, attempt := range attempts {
+		if attempt.StartTime.Valid && node.JoinTime.Before(attempt.StartTime.Time) {
+			recentTasks = append(recentTasks, recentTask{startTime: attempt.StartTime.Time, timeout: attempt.AbortReason == models.TaskAbortTimeout})
+		}
+	}
+	sort.Slice(recentTasks, func(i, j int) bool {
+		return recentTasks[i].startTime.After(recentTasks[j].startTime)
+	})
+	if len(recentTasks) > int(TASK_SCORE_POOL_SIZE) {
+		recentTasks = recentTasks[:TASK_SCORE_POOL_SIZE]
+	}
+
+	timeoutCount := 0
+	for _, task := range recentTasks {
+		if task.timeout {
+			timeoutCount++
 		}
 	}
 	if timeoutCount >= int(KickoutThreshold) {
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"math"
+	"sync"
+	"time"
+)
+
+// QueuePolicy decides which queued task gets a node first
+type QueuePolicy interface {
+	// Before reports whether the queued task a should get a node before the queued task b
+	Before(a, b *models.InferenceTask, now time.Time) bool
+	// TaskStarted accounts the task started on a node to its creator
+	TaskStarted(task *models.InferenceTask)
+}
+
+// NewQueuePolicy returns the queue policy set in the config
+func NewQueuePolicy() QueuePolicy {
+	appConfig := config.GetConfig()
+	if appConfig.Queue.Policy == "fair_share" {
+		weights := make(map[string]float64)
+		for _, creatorWeight := range appConfig.Queue.CreatorWeights {
+			weights[creatorWeight.Address] = creatorWeight.Weight
+		}
+		aging := time.Duration(appConfig.Queue.AgingSeconds) * time.Second
+		return NewFairSharePolicy(weights, appConfig.Queue.DefaultWeight, aging)
+	}
+	return NewFeeFirstPolicy()
+}
+
+// feeFirstPolicy dispatches tasks with higher task fee first, and earlier tasks first for the same task fee
+type feeFirstPolicy struct{}
+
+func NewFeeFirstPolicy() QueuePolicy {
+	return &feeFirstPolicy{}
+}
+
+func (p *feeFirstPolicy) Before(a, b *models.InferenceTask, now time.Time) bool {
+	if flag := a.TaskFee.Cmp(&b.TaskFee.Int); flag != 0 {
+		return flag > 0
+	}
+	return a.ID < b.ID
+}
+
+func (p *feeFirstPolicy) TaskStarted(task *models.InferenceTask) {}
+
+// fairSharePolicy is a start time fair queuing over creators. Each started task costs its creator 1 / weight of virtual time,
+// and the task whose creator has the earliest finish tag goes first, so that creators get nodes in proportion to their weights
+// no matter how many tasks they have queued.
+// The virtual time follows the start tag of the last started task, so an idle creator cannot save up its share.
+// A waiting task moves its finish tag 1 earlier every aging duration, so that it is not starved by creators with large weights.
+type fairSharePolicy struct {
+	weights       map[string]float64
+	defaultWeight float64
+	aging         time.Duration
+
+	mu          sync.Mutex
+	virtualTime float64
+	finishTags  map[string]float64
+}
+
+func NewFairSharePolicy(weights map[string]float64, defaultWeight float64, aging time.Duration) QueuePolicy {
+	return &fairSharePolicy{
+		weights:       weights,
+		defaultWeight: defaultWeight,
+		aging:         aging,
+		finishTags:    make(map[string]float64),
+	}
+}
+
+func (p *fairSharePolicy) weight(creator string) float64 {
+	if weight, ok := p.weights[creator]; ok {
+		return weight
+	}
+	return p.defaultWeight
+}
+
+func (p *fairSharePolicy) startTag(creator string) float64 {
+	return math.Max(p.finishTags[creator], p.virtualTime)
+}
+
+func (p *fairSharePolicy) priority(task *models.InferenceTask, now time.Time) float64 {
+	tag := p.startTag(task.Creator) + 1/p.weight(task.Creator)
+	if p.aging > 0 {
+		if waiting := now.Sub(task.QueueStartTime()); waiting > 0 {
+			tag -= float64(waiting) / float64(p.aging)
+		}
+	}
+	return tag
+}
+
+func (p *fairSharePolicy) Before(a, b *models.InferenceTask, now time.Time) bool {
+	p.mu.Lock()
+	priorityA, priorityB := p.priority(a, now), p.priority(b, now)
+	p.mu.Unlock()
+
+	if priorityA != priorityB {
+		return priorityA < priorityB
+	}
+	if flag := a.TaskFee.Cmp(&b.TaskFee.Int); flag != 0 {
+		return flag > 0
+	}
+	return a.ID < b.ID
+}
+
+func (p *fairSharePolicy) TaskStarted(task *models.InferenceTask) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	start := p.startTag(task.Creator)
+	p.finishTags[task.Creator] = start + 1/p.weight(task.Creator)
+	p.virtualTime = start
+
+	// creators whose finish tags are behind the virtual time are the same as new creators
+	if len(p.finishTags) > 1024 {
+		for creator, tag := range p.finishTags {
+			if tag <= p.virtualTime {
+				delete(p.finishTags, creator)
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package service_test
+
+import (
+	"crynux_relay/models"
+	"crynux_relay/service"
+	"database/sql"
+	"math/big"
+	"sort"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func newQueuedTask(id uint, creator string, fee int64, createTime time.Time) *models.InferenceTask {
+	return &models.InferenceTask{
+		Model:      gorm.Model{ID: id},
+		Creator:    creator,
+		Status:     models.TaskQueued,
+		TaskFee:    models.BigInt{Int: *big.NewInt(fee)},
+		CreateTime: sql.NullTime{Time: createTime, Valid: true},
+	}
+}
+
+// dispatchAll starts the queued tasks one by one in the order of the policy, and returns the creators in the started order
+func dispatchAll(policy service.QueuePolicy, tasks []*models.InferenceTask, now time.Time) []string {
+	var creators []string
+	for len(tasks) > 0 {
+		sort.SliceStable(tasks, func(i, j int) bool {
+			return policy.Before(tasks[i], tasks[j], now)
+		})
+		policy.TaskStarted(tasks[0])
+		creators = append(creators, tasks[0].Creator)
+		tasks = tasks[1:]
+	}
+	return creators
+}
+
+func TestFeeFirstPolicy(t *testing.T) {
+	now := time.Now()
+	policy := service.NewFeeFirstPolicy()
+	tasks := []*models.InferenceTask{
+		newQueuedTask(1, "a", 100, now),
+		newQueuedTask(2, "b", 300, now),
+		newQueuedTask(3, "c", 200, now),
+		newQueuedTask(4, "d", 300, now),
+	}
+	creators := dispatchAll(policy, tasks, now)
+	expected := []string{"b", "d", "c", "a"}
+	for i := range expected {
+		if creators[i] != expected[i] {
+			t.Fatalf("Wrong dispatch order: %v", creators)
+		}
+	}
+}
+
+func TestFairSharePolicy(t *testing.T) {
+	now := time.Now()
+
+	// creator a queues many tasks with higher fee before b, but b is not starved
+	policy := service.NewFairSharePolicy(nil, 1, 0)
+	var tasks []*models.InferenceTask
+	for i := 0; i < 4; i++ {
+		tasks = append(tasks, newQueuedTask(uint(i+1), "a", 1000, now))
+	}
+	for i := 0; i < 2; i++ {
+		tasks = append(tasks, newQueuedTask(uint(i+5), "b", 1, now))
+	}
+	creators := dispatchAll(policy, tasks, now)
+	if creators[0] != "a" || creators[1] != "b" || creators[2] != "a" || creators[3] != "b" {
+		t.Fatalf("Creators are not interleaved: %v", creators)
+	}
+
+	// creator a with weight 2 gets two nodes for each node of b
+	policy = service.NewFairSharePolicy(map[string]float64{"a": 2}, 1, 0)
+	tasks = nil
+	for i := 0; i < 6; i++ {
+		tasks = append(tasks, newQueuedTask(uint(2*i+1), "a", 1, now))
+		tasks = append(tasks, newQueuedTask(uint(2*i+2), "b", 1, now))
+	}
+	creators = dispatchAll(policy, tasks, now)
+	count := 0
+	for _, creator := range creators[:6] {
+		if creator == "a" {
+			count++
+		}
+	}
+	if count != 4 {
+		t.Fatalf("Wrong share of weighted creator: %v", creators)
+	}
+
+	// an idle creator cannot save up its share
+	policy = service.NewFairSharePolicy(nil, 1, 0)
+	tasks = nil
+	for i := 0; i < 3; i++ {
+		tasks = append(tasks, newQueuedTask(uint(i+1), "a", 1, now))
+	}
+	dispatchAll(policy, tasks, now)
+	tasks = []*models.InferenceTask{
+		newQueuedTask(4, "a", 1, now),
+		newQueuedTask(5, "a", 1, now),
+		newQueuedTask(6, "b", 1, now),
+		newQueuedTask(7, "b", 1, now),
+	}
+	creators = dispatchAll(policy, tasks, now)
+	if creators[0] != "b" || creators[1] != "a" {
+		t.Fatalf("Wrong order after idle: %v", creators)
+	}
+
+	// a task waiting long enough goes before the tasks of a creator with a large weight
+	policy = service.NewFairSharePolicy(map[string]float64{"a": 100}, 1, time.Minute)
+	old := newQueuedTask(1, "b", 1, now.Add(-10*time.Minute))
+	policy.TaskStarted(newQueuedTask(2, "b", 1, now))
+	fresh := newQueuedTask(3, "a", 1, now)
+	if !policy.Before(old, fresh, now) {
+		t.Fatal("Waiting task is not aged")
+	}
+	if policy.Before(newQueuedTask(4, "b", 1, now), fresh, now) {
+		t.Fatal("New task goes before task of creator with large weight")
+	}
+}
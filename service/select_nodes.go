@@ -4,7 +4,6 @@ import (
 	"context"
 	"crynux_relay/config"
 	"crynux_relay/models"
-	"strings"
 	"time"
 
 	"gonum.org/v1/gonum/stat/sampleuv"
@@ -86,6 +85,64 @@ func filterNodesByVram(ctx context.Context, minVram uint64, taskVersionNumbers [
 	return allNodes, nil
 }
 
+// getTaskNodeRequirements parses the requirement of the task, and the requirement of its task type if withTaskType
+func getTaskNodeRequirements(task *models.InferenceTask, withTaskType bool) ([]*NodeRequirement, error) {
+	var requirements []*NodeRequirement
+	if len(task.NodeRequirement) > 0 {
+		requirement, err := ParseNodeRequirement(task.NodeRequirement)
+		if err != nil {
+			return nil, err
+		}
+		requirements = append(requirements, requirement)
+	}
+	if spec, ok := models.GetTaskTypeSpec(task.TaskType); withTaskType && ok && len(spec.NodeRequirement) > 0 {
+		requirement, err := ParseNodeRequirement(spec.NodeRequirement)
+		if err != nil {
+			return nil, err
+		}
+		requirements = append(requirements, requirement)
+	}
+	return requirements, nil
+}
+
+// filterNodesByHardware returns the nodes whose hardware supports the task type and meets all the requirements.
+// The hardware of nodes not reporting a hardware profile is made from their gpu name.
+func filterNodesByHardware(ctx context.Context, db *gorm.DB, task *models.InferenceTask, nodes []models.Node, requirements []*NodeRequirement) ([]models.Node, error) {
+	if len(nodes) == 0 {
+		return nodes, nil
+	}
+	addresses := make([]string, len(nodes))
+	for i, node := range nodes {
+		addresses[i] = node.Address
+	}
+	hardwares, err := models.GetNodeHardwares(ctx, db, addresses)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []models.Node
+	for _, node := range nodes {
+		hardware, ok := hardwares[node.Address]
+		if !ok {
+			hardware = models.NewNodeHardwareFromGPUName(node.Address, node.GPUName, node.GPUVram)
+		}
+		if !hardware.SupportsTaskType(task.TaskType) {
+			continue
+		}
+		matched := true
+		for _, requirement := range requirements {
+			if !requirement.Match(hardware) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			res = append(res, node)
+		}
+	}
+	return res, nil
+}
+
 func matchModels(nodeModelIDs, taskModelIDs []string) int {
 	nodeModelIDSet := make(map[string]struct{})
 	for _, nodeModelID := range nodeModelIDs {
@@ -135,65 +192,47 @@ func selectNodeForInferenceTask(ctx context.Context, task *models.InferenceTask)
 		if err != nil {
 			return nil, err
 		}
-		if task.TaskType == models.TaskTypeLLM {
-			var newNodes []models.Node
-			for _, node := range nodes {
-				names := strings.SplitN(node.GPUName, "+", 2)
-				if len(names) == 2 {
-					platform := strings.TrimSpace(names[1])
-					if platform != "Darwin" {
-						newNodes = append(newNodes, node)
-					}
-				}
-			}
-			nodes = newNodes
-		}
 	}
-	if len(nodes) == 0 {
-		return nil, nil
+	// the creator picks the gpu explicitly when a gpu is required, so the requirement of the task type is only applied when not
+	requirements, err := getTaskNodeRequirements(task, len(task.RequiredGPU) == 0)
+	if err != nil {
+		return nil, err
 	}
-	maxStaking := GetMaxStaking()
-	maxQosScore := GetMaxQosScore()
-	scores := make([]float64, len(nodes))
-	for i, node := range nodes {
-		_, _, prob := CalculateSelectingProb(&node.StakeAmount.Int, maxStaking, node.QOSScore, maxQosScore)
-		scores[i] = prob
+	nodes, err = filterNodesByHardware(ctx, config.GetDB(), task, nodes, requirements)
+	if err != nil {
+		return nil, err
 	}
-
-	changedNodes := make([]models.Node, 0)
-	changedScores := make([]float64, 0)
-	for i, node := range nodes {
-		localModelIDs := make([]string, 0)
-		inUseModelIDs := make([]string, 0)
-		for _, model := range node.Models {
-			localModelIDs = append(localModelIDs, model.ModelID)
-			if model.InUse {
-				inUseModelIDs = append(inUseModelIDs, model.ModelID)
+	// nodes failed to run the task before, or excluded by the dispute or spot check the task verifies, are not selected
+	failedNodes, err := getTaskFailedNodes(ctx, config.GetDB(), task)
+	if err != nil {
+		return nil, err
+	}
+	if len(failedNodes) > 0 {
+		var newNodes []models.Node
+		for _, node := range nodes {
+			if !failedNodes[node.Address] {
+				newNodes = append(newNodes, node)
 			}
 		}
-
-		// add additional qos score to nodes with local task models
-		cnt := matchModels(localModelIDs, task.ModelIDs)
-		if cnt > 0 {
-			changedNodes = append(changedNodes, node)
-			changedScore := scores[i]
-			if isSameModels(inUseModelIDs, task.ModelIDs) {
-				changedScore *= 2
-			} else {
-				changedScore *= (1 + float64(cnt) / float64(len(task.ModelIDs)))
-			}
-			changedScores = append(changedScores, changedScore)
+		nodes = newNodes
+	}
+	// spot check tasks are only run by trusted nodes
+	if len(task.SpotCheckTaskIDCommitment) > 0 {
+		nodes, err = filterTrustedNodes(ctx, config.GetDB(), nodes)
+		if err != nil {
+			return nil, err
 		}
-
 	}
-
-	if len(changedNodes) > 0 {
-		nodes = changedNodes
-		scores = changedScores
+	if len(nodes) == 0 {
+		return nil, nil
 	}
-
-	node := selectNodesByScore(nodes, scores, 1)[0]
-	return &node, nil
+	selector := getNodeSelector()
+	selection, err := selector.Select(ctx, task, nodes)
+	if err != nil {
+		return nil, err
+	}
+	logNodeSelection(task, selector, selection)
+	return selection.Node, nil
 }
 
 func selectNodesForDownloadTask(ctx context.Context, task *models.InferenceTask, modelID string, n int) ([]models.Node, error) {
@@ -211,6 +250,14 @@ func selectNodesForDownloadTask(ctx context.Context, task *models.InferenceTask,
 			return nil, err
 		}
 	}
+	requirements, err := getTaskNodeRequirements(task, false)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err = filterNodesByHardware(ctx, config.GetDB(), task, nodes, requirements)
+	if err != nil {
+		return nil, err
+	}
 	if len(nodes) == 0 {
 		return nil, nil
 	}
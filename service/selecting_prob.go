@@ -12,9 +12,18 @@ import (
 	"gorm.io/gorm"
 )
 
+// every relay instance keeps its own max staking, which is raised by the nodes joining the instance
+// and refreshed from the database periodically to catch up with the nodes joining or quitting on other instances
 var (
-	globalMaxStaking  = &MaxStaking{staking: big.NewInt(0)}
-	globalMaxQosScore = float64(TASK_SCORE_REWARDS[0])
+	globalMaxStaking = &MaxStaking{staking: big.NewInt(0)}
+)
+
+// the weights of the staking score and the qos score in the selecting probability s*q/(Ws*q + Wq*s),
+// that is their weighted harmonic mean divided by the sum of the weights.
+// With the default weights of 1 it is s*q/(s+q), half the harmonic mean, as reported by the node apis.
+var (
+	StakingScoreWeight float64 = 1
+	QosScoreWeight     float64 = 1
 )
 
 func InitSelectingProb(ctx context.Context, db *gorm.DB) error {
@@ -31,7 +40,7 @@ func CalculateSelectingProb(staking, maxStaking *big.Int, qosScore, maxQosScore
 	if stakingProb == 0 || qosProb == 0 {
 		prob = 0
 	} else {
-		prob = stakingProb * qosProb / (stakingProb + qosProb)
+		prob = stakingProb * qosProb / (StakingScoreWeight*qosProb + QosScoreWeight*stakingProb)
 	}
 	return stakingProb, qosProb, prob
 }
@@ -58,7 +67,7 @@ func GetMaxStaking() *big.Int {
 }
 
 func GetMaxQosScore() float64 {
-	return globalMaxQosScore
+	return float64(MAX_TASK_QOS_SCORE)
 }
 
 func UpdateMaxStaking(staking *big.Int) {
@@ -79,10 +88,10 @@ func RefreshMaxStaking(ctx context.Context, db *gorm.DB) error {
 	}
 
 	if res.StakeAmount.Int.Sign() > 0 {
-		globalMaxStaking.update(&res.StakeAmount.Int)
+		globalMaxStaking.set(&res.StakeAmount.Int)
 	} else {
 		appConfig := config.GetConfig()
-		globalMaxStaking.update(utils.EtherToWei(big.NewInt(int64(appConfig.Task.StakeAmount))))
+		globalMaxStaking.set(utils.EtherToWei(big.NewInt(int64(appConfig.Task.StakeAmount))))
 	}
 
 	return nil
@@ -106,8 +115,15 @@ func (g *MaxStaking) update(staking *big.Int) {
 	}
 }
 
+// set replaces the max staking with the one in the database, which could be lower after nodes quit
+func (g *MaxStaking) set(staking *big.Int) {
+	g.Lock()
+	defer g.Unlock()
+	g.staking.Set(staking)
+}
+
 func (g *MaxStaking) get() *big.Int {
 	g.RLock()
 	defer g.RUnlock()
-	return g.staking
-}
\ No newline at end of file
+	return new(big.Int).Set(g.staking)
+}
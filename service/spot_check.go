@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"crynux_relay/utils"
+	"database/sql"
+	"errors"
+	"math"
+	"math/big"
+	"math/rand"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SpotCheckParams struct {
+	BaseRate    float64
+	MaxRate     float64
+	HalfLife    time.Duration
+	LowQOSScore float64
+}
+
+func getSpotCheckParams() SpotCheckParams {
+	appConfig := config.GetConfig()
+	return SpotCheckParams{
+		BaseRate:    appConfig.SpotCheck.BaseRate,
+		MaxRate:     appConfig.SpotCheck.MaxRate,
+		HalfLife:    time.Duration(appConfig.SpotCheck.HalfLifeHours) * time.Hour,
+		LowQOSScore: appConfig.SpotCheck.LowQOSScore,
+	}
+}
+
+// SpotCheckRate returns the probability that a successful task of a node is spot checked.
+// The rate of a new node is max rate, and it halves every half life as the node ages, down to base rate.
+// A qos score below the low qos score raises the rate in proportion to the shortfall,
+// and a node with recently invalidated tasks is checked at max rate.
+func SpotCheckRate(params SpotCheckParams, nodeAge time.Duration, qosScore float64, recentlyInvalidated bool) float64 {
+	if recentlyInvalidated {
+		return params.MaxRate
+	}
+	if nodeAge < 0 {
+		nodeAge = 0
+	}
+	rate := params.MaxRate
+	if params.HalfLife > 0 {
+		rate = params.MaxRate * math.Pow(0.5, float64(nodeAge)/float64(params.HalfLife))
+	}
+	if params.LowQOSScore > 0 && qosScore < params.LowQOSScore {
+		qosRate := params.MaxRate * (params.LowQOSScore - math.Max(qosScore, 0)) / params.LowQOSScore
+		rate = math.Max(rate, qosRate)
+	}
+	return math.Min(math.Max(rate, params.BaseRate), params.MaxRate)
+}
+
+// getNodeAge returns how long the node has been in the network since it joined for the first time,
+// so that the history of the node is kept when it quits and joins again
+func getNodeAge(node *models.Node) time.Duration {
+	if node.FirstJoinTime.Valid {
+		return utils.Now().Sub(node.FirstJoinTime.Time)
+	}
+	return utils.Now().Sub(node.JoinTime)
+}
+
+func getNodeSpotCheckRate(ctx context.Context, db *gorm.DB, node *models.Node) (float64, error) {
+	appConfig := config.GetConfig()
+	since := utils.Now().Add(-time.Duration(appConfig.SpotCheck.InvalidatedLookbackHours) * time.Hour)
+	invalidatedCount, err := models.GetNodeInvalidatedTaskCount(ctx, db, node.Address, since)
+	if err != nil {
+		return 0, err
+	}
+	return SpotCheckRate(getSpotCheckParams(), getNodeAge(node), node.QOSScore, invalidatedCount > 0), nil
+}
+
+// needSpotCheck decides whether the payment of the single validated task is held for a spot check, and returns the rate used.
+// Tasks issued by the relay and tasks rerun for disputes are never spot checked.
+func needSpotCheck(ctx context.Context, db *gorm.DB, task *models.InferenceTask, node *models.Node) (bool, float64, error) {
+	if !config.GetConfig().SpotCheck.Enabled || task.Canary || len(task.DisputeTaskIDCommitment) > 0 {
+		return false, 0, nil
+	}
+	rate, err := getNodeSpotCheckRate(ctx, db, node)
+	if err != nil {
+		return false, 0, err
+	}
+	return rand.Float64() < rate, rate, nil
+}
+
+// filterTrustedNodes keeps the nodes checked at base rate, which are trusted to run spot check tasks
+func filterTrustedNodes(ctx context.Context, db *gorm.DB, nodes []models.Node) ([]models.Node, error) {
+	appConfig := config.GetConfig()
+	addresses := make([]string, len(nodes))
+	for i, node := range nodes {
+		addresses[i] = node.Address
+	}
+	since := utils.Now().Add(-time.Duration(appConfig.SpotCheck.InvalidatedLookbackHours) * time.Hour)
+	invalidatedNodes, err := models.GetInvalidatedNodes(ctx, db, addresses, since)
+	if err != nil {
+		return nil, err
+	}
+
+	params := getSpotCheckParams()
+	var res []models.Node
+	for _, node := range nodes {
+		rate := SpotCheckRate(params, getNodeAge(&node), node.QOSScore, invalidatedNodes[node.Address])
+		if rate <= params.BaseRate {
+			res = append(res, node)
+		}
+	}
+	return res, nil
+}
+
+// createSpotCheckTask reruns the task of the spot check on a trusted node.
+// The check task is funded by the relay and looks like a normal task to the nodes.
+func createSpotCheckTask(ctx context.Context, db *gorm.DB, spotCheck *models.SpotCheck) error {
+	task, err := models.GetTaskByIDCommitment(ctx, db, spotCheck.TaskIDCommitment)
+	if err != nil {
+		return err
+	}
+	checkTask, err := newRerunTask(task)
+	if err != nil {
+		return err
+	}
+	checkTask.Creator, err = getCanaryCreator()
+	if err != nil {
+		return err
+	}
+	checkTask.MaxAttempts = 1
+	checkTask.Canary = true
+	checkTask.SpotCheckTaskIDCommitment = task.TaskIDCommitment
+
+	if err := topUpCanaryCreator(ctx, db, checkTask.Creator, &checkTask.TaskFee.Int); err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := CreateTask(ctx, tx, checkTask, nil); err != nil {
+			return err
+		}
+		return spotCheck.Update(ctx, tx, map[string]interface{}{
+			"check_task_id_commitment": checkTask.TaskIDCommitment,
+		})
+	})
+}
+
+// ResolveSpotCheck creates the check task of the spot check, and compares its result with the checked task after it finishes.
+// The held payment is released to the node unless the check task disagrees with it,
+// in which case the payment is refunded to the creator and the node is slashed.
+func ResolveSpotCheck(ctx context.Context, db *gorm.DB, originSpotCheck *models.SpotCheck) error {
+	spotCheck := *originSpotCheck
+	if spotCheck.Status != models.SpotCheckPending {
+		return nil
+	}
+	if len(spotCheck.CheckTaskIDCommitment) == 0 {
+		if err := createSpotCheckTask(ctx, db, &spotCheck); err != nil {
+			return err
+		}
+		*originSpotCheck = spotCheck
+		return nil
+	}
+
+	checkTask, err := models.GetTaskByIDCommitment(ctx, db, spotCheck.CheckTaskIDCommitment)
+	if err != nil {
+		return err
+	}
+	if !isVerificationTaskFinished(checkTask) {
+		return nil
+	}
+	task, err := models.GetTaskByIDCommitment(ctx, db, spotCheck.TaskIDCommitment)
+	if err != nil {
+		return err
+	}
+
+	status := models.SpotCheckInconclusive
+	if checkTask.Status == models.TaskScoreReady {
+		if compareScore(task, checkTask).Same {
+			status = models.SpotCheckPassed
+		} else {
+			status = models.SpotCheckFailed
+		}
+	}
+
+	appConfig := config.GetConfig()
+	return db.Transaction(func(tx *gorm.DB) error {
+		if checkTask.Status == models.TaskScoreReady {
+			if err := SetTaskStatusValidated(ctx, tx, checkTask); err != nil {
+				return err
+			}
+		} else if checkTask.Status == models.TaskErrorReported {
+			checkTask.AbortReason = models.TaskAbortIncorrectResult
+			checkTask.ValidatedTime = sql.NullTime{Time: utils.Now(), Valid: true}
+			if err := SetTaskStatusEndAborted(ctx, tx, checkTask, appConfig.Blockchain.Account.Address); err != nil {
+				return err
+			}
+		}
+
+		if status == models.SpotCheckFailed {
+			if err := Transfer(ctx, tx, appConfig.Blockchain.Account.Address, task.Creator, &spotCheck.Payment.Int); err != nil {
+				return err
+			}
+			node, err := models.GetNodeByAddress(ctx, tx, spotCheck.SelectedNode)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if err == nil && node.Status != models.NodeStatusQuit {
+				if err := nodeSlash(ctx, tx, node); err != nil {
+					return err
+				}
+			}
+		} else {
+			if err := Transfer(ctx, tx, appConfig.Blockchain.Account.Address, spotCheck.SelectedNode, &spotCheck.Payment.Int); err != nil {
+				return err
+			}
+			incentive, _ := utils.WeiToEther(&spotCheck.Payment.Int).Float64()
+			if err := addNodeIncentive(ctx, tx, spotCheck.SelectedNode, incentive, task.TaskType); err != nil {
+				return err
+			}
+		}
+
+		if err := spotCheck.Update(ctx, tx, map[string]interface{}{
+			"status":        status,
+			"resolved_time": sql.NullTime{Time: utils.Now(), Valid: true},
+		}); err != nil {
+			return err
+		}
+		*originSpotCheck = spotCheck
+		return nil
+	})
+}
+
+func newSpotCheck(task *models.InferenceTask, rate float64, payment *big.Int) *models.SpotCheck {
+	return &models.SpotCheck{
+		TaskIDCommitment: task.TaskIDCommitment,
+		SelectedNode:     task.SelectedNode,
+		Rate:             rate,
+		Payment:          models.BigInt{Int: *new(big.Int).Set(payment)},
+		Status:           models.SpotCheckPending,
+	}
+}
@@ -0,0 +1,345 @@
+package service_test
+
+import (
+	"context"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"crynux_relay/service"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestSpotCheckRate(t *testing.T) {
+	params := service.SpotCheckParams{
+		BaseRate:    0.01,
+		MaxRate:     0.5,
+		HalfLife:    72 * time.Hour,
+		LowQOSScore: 5,
+	}
+	day := 24 * time.Hour
+
+	if rate := service.SpotCheckRate(params, 0, 10, false); rate != 0.5 {
+		t.Fatalf("Wrong rate of new node: %f", rate)
+	}
+	if rate := service.SpotCheckRate(params, 3*day, 10, false); math.Abs(rate-0.25) > 1e-9 {
+		t.Fatalf("Wrong rate after one half life: %f", rate)
+	}
+	if rate := service.SpotCheckRate(params, 365*day, 10, false); rate != 0.01 {
+		t.Fatalf("Wrong rate of old node: %f", rate)
+	}
+
+	last := 1.0
+	for age := time.Duration(0); age < 60*day; age += day {
+		rate := service.SpotCheckRate(params, age, 10, false)
+		if rate > last {
+			t.Fatal("Rate does not decay with node age")
+		}
+		last = rate
+	}
+
+	if rate := service.SpotCheckRate(params, 365*day, 2.5, false); math.Abs(rate-0.25) > 1e-9 {
+		t.Fatalf("Wrong rate of low qos node: %f", rate)
+	}
+	if rate := service.SpotCheckRate(params, 365*day, 0, false); rate != 0.5 {
+		t.Fatalf("Wrong rate of zero qos node: %f", rate)
+	}
+	if rate := service.SpotCheckRate(params, 365*day, 10, true); rate != 0.5 {
+		t.Fatalf("Wrong rate of recently invalidated node: %f", rate)
+	}
+}
+
+var (
+	spotCheckGoodScore = "0x" + strings.Repeat("00", 8)
+	spotCheckBadScore  = "0x" + strings.Repeat("ff", 8)
+	spotCheckTaskFee   = big.NewInt(1e18)
+)
+
+// setupSpotCheckDB loads a config with spot checks of every task of new nodes, and an empty sqlite database
+func setupSpotCheckDB(t *testing.T) {
+	dir := t.TempDir()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "key")
+	if err := os.WriteFile(keyFile, []byte(hex.EncodeToString(crypto.FromECDSA(key))), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := fmt.Sprintf(`
+db:
+  driver: "sqlite"
+  connection: "%s/db.sqlite"
+  log:
+    level: "error"
+    output: "stderr"
+blockchain:
+  account:
+    address: "%s"
+    private_key_file: "%s"
+    genesis_token_amount: 1000000
+task:
+  distance_threshold: 5
+spot_check:
+  enabled: true
+  max_rate: 1
+canary:
+  creators:
+  - "0x00000000000000000000000000000000000000c1"
+  - "0x00000000000000000000000000000000000000c2"
+  - "0x00000000000000000000000000000000000000c3"
+`, dir, crypto.PubkeyToAddress(key.PublicKey).Hex(), keyFile)
+	if err := os.WriteFile(filepath.Join(dir, "config.yml"), []byte(cfg), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.InitConfig(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.InitDB(config.GetConfig()); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.GetDB().AutoMigrate(&models.Balance{}, &models.Event{}, &models.InferenceTask{}, &models.Node{},
+		&models.NodeModel{}, &models.NodeIncentive{}, &models.NodeHardware{}, &models.InferenceTaskAttempt{},
+		&models.TaskDependency{}, &models.TaskDispute{}, &models.SpotCheck{}, &models.TaskStatusTransition{},
+		&models.TransferEvent{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.CreateGenesisAccount(context.Background(), config.GetDB()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newSpotCheckNode adds an available node which joined the age ago
+func newSpotCheckNode(t *testing.T, age time.Duration) *models.Node {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := &models.Node{
+		Address:       crypto.PubkeyToAddress(key.PublicKey).Hex(),
+		Status:        models.NodeStatusAvailable,
+		GPUName:       "NVIDIA GeForce RTX 4090",
+		GPUVram:       24,
+		MajorVersion:  1,
+		QOSScore:      float64(service.MAX_TASK_QOS_SCORE),
+		JoinTime:      time.Now(),
+		FirstJoinTime: sql.NullTime{Time: time.Now().Add(-age), Valid: true},
+		StakeAmount:   models.BigInt{Int: *big.NewInt(0)},
+	}
+	db := config.GetDB()
+	if err := db.Create(node).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.NodeModel{NodeAddress: node.Address, ModelID: "m", InUse: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return node
+}
+
+func getNode(t *testing.T, address string) *models.Node {
+	node, err := models.GetNodeByAddress(context.Background(), config.GetDB(), address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return node
+}
+
+func getBalance(t *testing.T, address string) *big.Int {
+	balance, err := service.GetBalance(context.Background(), config.GetDB(), address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return balance
+}
+
+// runTask runs a single task of the creator on the node until it succeeds
+func runTask(t *testing.T, creator string, node *models.Node, score string) *models.InferenceTask {
+	ctx := context.Background()
+	db := config.GetDB()
+	randomBytes := make([]byte, 64)
+	if _, err := rand.Read(randomBytes); err != nil {
+		t.Fatal(err)
+	}
+	task := &models.InferenceTask{
+		TaskArgs:         "{}",
+		TaskIDCommitment: crypto.Keccak256Hash(randomBytes).Hex(),
+		TaskID:           hexutil.Encode(randomBytes[:32]),
+		Nonce:            hexutil.Encode(randomBytes[32:]),
+		SamplingSeed:     hexutil.Encode(randomBytes[:32]),
+		Creator:          creator,
+		Status:           models.TaskQueued,
+		TaskType:         models.TaskTypeSD,
+		TaskVersion:      "1.0.0",
+		Timeout:          60,
+		TaskFee:          models.BigInt{Int: *new(big.Int).Set(spotCheckTaskFee)},
+		ModelIDs:         []string{"m"},
+		MaxAttempts:      1,
+		CreateTime:       sql.NullTime{Time: time.Now(), Valid: true},
+	}
+	v, threshold, err := models.ResolveTaskValidator(task.TaskType, task.ModelIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task.Validator = v.Name()
+	task.ValidatorThreshold = threshold
+	if err := service.CreateTask(ctx, db, task, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.SetTaskStatusStarted(ctx, db, task, getNode(t, node.Address)); err != nil {
+		t.Fatal(err)
+	}
+	task.Score = score
+	if err := service.SetTaskStatusScoreReady(ctx, db, task); err != nil {
+		t.Fatal(err)
+	}
+	if task.Status == models.TaskScoreReady {
+		if err := service.SetTaskStatusValidated(ctx, db, task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if task.Status == models.TaskValidated {
+		if err := service.SetTaskStatusEndSuccess(ctx, db, task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return task
+}
+
+// resolveSpotCheck creates the check task of the spot check, runs it on the selected node and resolves the spot check
+func resolveSpotCheck(t *testing.T, task *models.InferenceTask, trusted *models.Node, score string) *models.SpotCheck {
+	ctx := context.Background()
+	db := config.GetDB()
+	spotCheck, err := models.GetSpotCheck(ctx, db, task.TaskIDCommitment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := service.ResolveSpotCheck(ctx, db, spotCheck); err != nil {
+		t.Fatal(err)
+	}
+	checkTask, err := models.GetTaskByIDCommitment(ctx, db, spotCheck.CheckTaskIDCommitment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkTask.Creator == task.Creator || checkTask.SpotCheckTaskIDCommitment != task.TaskIDCommitment {
+		t.Fatalf("Wrong check task of creator %s", checkTask.Creator)
+	}
+
+	// only the trusted node is selected, the checked node and new nodes are not
+	for i := 0; i < 10; i++ {
+		node, err := service.SelectNodeForInferenceTask(ctx, checkTask)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if node == nil || node.Address != trusted.Address {
+			t.Fatalf("Check task is not run by the trusted node: %v", node)
+		}
+	}
+
+	if err := service.SetTaskStatusStarted(ctx, db, checkTask, getNode(t, trusted.Address)); err != nil {
+		t.Fatal(err)
+	}
+	checkTask.Score = score
+	if err := service.SetTaskStatusScoreReady(ctx, db, checkTask); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.ResolveSpotCheck(ctx, db, spotCheck); err != nil {
+		t.Fatal(err)
+	}
+	spotCheck, err = models.GetSpotCheck(ctx, db, task.TaskIDCommitment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spotCheck
+}
+
+func TestSpotCheckPassed(t *testing.T) {
+	setupSpotCheckDB(t)
+	creator := "0x00000000000000000000000000000000000000a1"
+	relay := config.GetConfig().Blockchain.Account.Address
+	if err := service.Transfer(context.Background(), config.GetDB(), relay, creator, big.NewInt(0).Mul(spotCheckTaskFee, big.NewInt(10))); err != nil {
+		t.Fatal(err)
+	}
+	checked := newSpotCheckNode(t, 0)
+	trusted := newSpotCheckNode(t, 365*24*time.Hour)
+	newSpotCheckNode(t, time.Hour)
+
+	task := runTask(t, creator, checked, spotCheckGoodScore)
+	if task.Status != models.TaskEndSuccess {
+		t.Fatalf("Task not succeeded: %d", task.Status)
+	}
+	if balance := getBalance(t, checked.Address); balance.Sign() != 0 {
+		t.Fatalf("Payment of a new node is not held: %s", balance)
+	}
+
+	spotCheck := resolveSpotCheck(t, task, trusted, spotCheckGoodScore)
+	if spotCheck.Status != models.SpotCheckPassed {
+		t.Fatalf("Spot check not passed: %d", spotCheck.Status)
+	}
+	if balance := getBalance(t, checked.Address); balance.Cmp(spotCheckTaskFee) != 0 {
+		t.Fatalf("Held payment is not released: %s", balance)
+	}
+	if node := getNode(t, checked.Address); node.Status != models.NodeStatusAvailable {
+		t.Fatalf("Checked node is not available: %d", node.Status)
+	}
+}
+
+func TestSpotCheckFailed(t *testing.T) {
+	setupSpotCheckDB(t)
+	creator := "0x00000000000000000000000000000000000000a1"
+	relay := config.GetConfig().Blockchain.Account.Address
+	if err := service.Transfer(context.Background(), config.GetDB(), relay, creator, big.NewInt(0).Mul(spotCheckTaskFee, big.NewInt(10))); err != nil {
+		t.Fatal(err)
+	}
+	checked := newSpotCheckNode(t, 0)
+	trusted := newSpotCheckNode(t, 365*24*time.Hour)
+
+	before := getBalance(t, creator)
+	task := runTask(t, creator, checked, spotCheckBadScore)
+	spotCheck := resolveSpotCheck(t, task, trusted, spotCheckGoodScore)
+	if spotCheck.Status != models.SpotCheckFailed {
+		t.Fatalf("Spot check not failed: %d", spotCheck.Status)
+	}
+	if balance := getBalance(t, creator); balance.Cmp(before) != 0 {
+		t.Fatalf("Held payment is not refunded to the creator: %s, before %s", balance, before)
+	}
+	if balance := getBalance(t, checked.Address); balance.Sign() != 0 {
+		t.Fatalf("Held payment is released to the failed node: %s", balance)
+	}
+	if node := getNode(t, checked.Address); node.Status != models.NodeStatusQuit {
+		t.Fatalf("Failed node is not slashed: %d", node.Status)
+	}
+}
+
+func TestSpotCheckNodeAge(t *testing.T) {
+	setupSpotCheckDB(t)
+	// a long standing node restarted just now is still trusted, as the rate decays from its first join
+	restarted := newSpotCheckNode(t, 365*24*time.Hour)
+	restarted.JoinTime = time.Now()
+	if err := config.GetDB().Save(restarted).Error; err != nil {
+		t.Fatal(err)
+	}
+	checked := newSpotCheckNode(t, 0)
+	newSpotCheckNode(t, time.Hour)
+	creator := "0x00000000000000000000000000000000000000a1"
+	relay := config.GetConfig().Blockchain.Account.Address
+	if err := service.Transfer(context.Background(), config.GetDB(), relay, creator, big.NewInt(0).Mul(spotCheckTaskFee, big.NewInt(10))); err != nil {
+		t.Fatal(err)
+	}
+
+	task := runTask(t, creator, checked, spotCheckGoodScore)
+	spotCheck := resolveSpotCheck(t, task, restarted, spotCheckGoodScore)
+	if spotCheck.Status != models.SpotCheckPassed {
+		t.Fatalf("Spot check not passed: %d", spotCheck.Status)
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"crynux_relay/config"
 	"crynux_relay/models"
+	"crynux_relay/utils"
 	"database/sql"
 	"errors"
 	"math/rand"
+	"sort"
 	"sync"
 	"time"
 
@@ -28,47 +30,91 @@ type TaskDispatcher struct {
 	processingTasks  sync.Map
 	dispatchLimiter  chan struct{}
 	startTaskLimiter chan struct{}
+	policy           QueuePolicy
+	// wg tracks the goroutines dispatching and starting tasks, so that the dispatcher can wait for them to stop
+	wg sync.WaitGroup
 }
 
-func NewTaskDispatcher() *TaskDispatcher {
+func NewTaskDispatcher(policy QueuePolicy) *TaskDispatcher {
 	return &TaskDispatcher{
 		nodeQueue:        make(chan string, 100),
 		dispatchLimiter:  make(chan struct{}, 100),
 		startTaskLimiter: make(chan struct{}, 100),
+		policy:           policy,
 	}
 }
 
-func (d *TaskDispatcher) getQueuedTasks(ctx context.Context) {
-	timer := time.NewTimer(2 * time.Second)
-	defer timer.Stop()
+// getSortedQueuedTasks returns the queued tasks in the scan window, sorted by the queue policy.
+// The window takes the earliest tasks of each creator in turns, at most creatorLimit tasks of a creator,
+// so that a creator with many queued tasks cannot fill the window and hide the tasks of others from the policy.
+// The window function requires the database versions checked in config.InitDB.
+func (d *TaskDispatcher) getSortedQueuedTasks(ctx context.Context, scanLimit, creatorLimit int) ([]*models.InferenceTask, error) {
+	tasks := make([]*models.InferenceTask, 0)
+
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	db := config.GetDB().WithContext(dbCtx)
+	queuedTasks := db.Model(&models.InferenceTask{}).
+		Select("*, ROW_NUMBER() OVER (PARTITION BY creator ORDER BY id) AS creator_rank").
+		Where("status = ?", models.TaskQueued).
+		Where("not_before IS NULL OR not_before <= ?", utils.Now())
+	err := db.Unscoped().Table("(?) AS queued_tasks", queuedTasks).
+		Where("creator_rank <= ?", creatorLimit).
+		Order("creator_rank, id").
+		Limit(scanLimit).
+		Find(&tasks).Error
+	if err != nil {
+		return nil, err
+	}
+	now := utils.Now()
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return d.policy.Before(tasks[i], tasks[j], now)
+	})
+	return tasks, nil
+}
+
+// getQueuedTaskDeadline returns the time before which the queued task must be started
+func getQueuedTaskDeadline(task *models.InferenceTask) time.Time {
+	return task.QueueStartTime().Add(3*time.Minute + time.Duration(task.Timeout)*time.Second)
+}
+
+func abortQueuedTask(ctx context.Context, task *models.InferenceTask) {
+	log.Debugf("StartTask: task %s timeout, abort", task.TaskIDCommitment)
+	task.AbortReason = models.TaskAbortTimeout
+	task.ValidatedTime = sql.NullTime{Time: utils.Now(), Valid: true}
+	ctx1, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	appConfig := config.GetConfig()
+	if err := SetTaskStatusEndAborted(ctx1, config.GetDB(), task, appConfig.Blockchain.Account.Address); err != nil {
+		log.Errorf("StartTask: abort task %s error: %v", task.TaskIDCommitment, err)
+	}
+}
 
+func (d *TaskDispatcher) getQueuedTasks(ctx context.Context) {
+	// queued tasks are scanned in a window larger than the dispatch limit,
+	// and the window is sorted by the queue policy before dispatching.
+	// A creator has at most the dispatch limit of tasks in the window, as no more of them can be dispatched in a round.
 	limit := 100
+	scanLimit := 1000
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			tasks, err := func(ctx context.Context) ([]*models.InferenceTask, error) {
-				tasks := make([]*models.InferenceTask, 0)
-
-				dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-				defer cancel()
-				err := config.GetDB().WithContext(dbCtx).Model(&models.InferenceTask{}).
-					Where("status = ?", models.TaskQueued).
-					Order("id").
-					Limit(limit).
-					Find(&tasks).Error
-				if err != nil {
-					return nil, err
-				}
-				return tasks, nil
-			}(ctx)
+			tasks, err := d.getSortedQueuedTasks(ctx, scanLimit, limit)
+			dispatched := 0
 			if err == nil && len(tasks) > 0 {
 				for _, task := range tasks {
+					if dispatched >= limit {
+						break
+					}
 					if _, loaded := d.processingTasks.LoadOrStore(task.ID, struct{}{}); loaded {
 						continue
 					}
+					dispatched++
+					d.wg.Add(1)
 					go func(task *models.InferenceTask) {
+						defer d.wg.Done()
 						d.dispatchLimiter <- struct{}{}
 						defer func() {
 							<-d.dispatchLimiter
@@ -83,44 +129,29 @@ func (d *TaskDispatcher) getQueuedTasks(ctx context.Context) {
 							return
 						}
 
-						deadline := task.CreateTime.Time.Add(3*time.Minute + time.Duration(task.Timeout)*time.Second)
-						if deadline.Before(time.Now()) {
-							log.Debugf("StartTask: task %s timeout, abort", task.TaskIDCommitment)
-							task.AbortReason = models.TaskAbortTimeout
-							task.ValidatedTime = sql.NullTime{Time: time.Now(), Valid: true}
-							ctx1, cancel := context.WithTimeout(ctx, 10*time.Second)
-							defer cancel()
-							appConfig := config.GetConfig()
-							if err := SetTaskStatusEndAborted(ctx1, config.GetDB(), task, appConfig.Blockchain.Account.Address); err != nil {
-								log.Errorf("StartTask: abort task %s error: %v", task.TaskIDCommitment, err)
-							}
+						deadline := getQueuedTaskDeadline(task)
+						if deadline.Before(utils.Now()) {
+							abortQueuedTask(ctx, task)
 							return
 						}
-						ctx1, cancel := context.WithDeadline(ctx, deadline)
+						ctx1, cancel := utils.WithDeadline(ctx, deadline)
 						defer cancel()
 						log.Debugf("StartTask: dispatch task %s", task.TaskIDCommitment)
 						d.Dispatch(ctx1, task)
 					}(task)
 
 				}
-			} else {
+			}
+			if dispatched == 0 {
 				if err != nil {
 					log.Errorf("StartTask: get queued tasks error: %v", err)
 
 				}
 
-				if !timer.Stop() {
-					select {
-					case <-timer.C:
-					default:
-					}
-				}
-				timer.Reset(2 * time.Second)
-
 				select {
 				case <-ctx.Done():
 					return
-				case <-timer.C:
+				case <-utils.After(2 * time.Second):
 				}
 			}
 		}
@@ -132,13 +163,17 @@ func (d *TaskDispatcher) Process(ctx context.Context, task *models.InferenceTask
 		task:      task,
 		node:      node,
 		resChan:   make(chan bool, 1),
-		createdAt: time.Now(),
+		createdAt: utils.Now(),
 		finished:  false,
 	})
 	if !loaded {
 		log.Debugf("StartTask: new dispatched task %s on node %s", task.TaskIDCommitment, node.Address)
 		resChan := dispatchedTask.(*DispatchedTask).resChan
-		d.nodeQueue <- node.Address
+		select {
+		case d.nodeQueue <- node.Address:
+		case <-ctx.Done():
+			return false
+		}
 		log.Debugf("StartTask: waiting for task %s on node %s", task.TaskIDCommitment, node.Address)
 		select {
 		case res := <-resChan:
@@ -156,13 +191,13 @@ func (d *TaskDispatcher) Process(ctx context.Context, task *models.InferenceTask
 				return false
 			}
 			originalTask := dispatchedTask.task
-			if originalTask.TaskFee.Cmp(&task.TaskFee.Int) >= 0 {
+			if !d.policy.Before(task, originalTask, utils.Now()) {
 				dispatchedTask.mu.Unlock()
-				log.Debugf("StartTask: task %s fee is lower than original task fee, skip", task.TaskIDCommitment)
+				log.Debugf("StartTask: task %s goes after original task by queue policy, skip", task.TaskIDCommitment)
 				return false
 			}
-			// if current task fee is higher than original task fee, replace the original task
-			log.Debugf("StartTask: task %s fee is higher than original task fee, replace", task.TaskIDCommitment)
+			// if current task goes before original task, replace the original task
+			log.Debugf("StartTask: task %s goes before original task by queue policy, replace", task.TaskIDCommitment)
 			log.Debugf("StartTask: task %s is replaced by task %s", originalTask.TaskIDCommitment, task.TaskIDCommitment)
 			dispatchedTask.task = task
 			dispatchedTask.resChan <- false
@@ -199,6 +234,15 @@ func (d *TaskDispatcher) Dispatch(ctx context.Context, task *models.InferenceTas
 					return
 				} else {
 					log.Debugf("StartTask: dispatch task %s to node %s failed", task.TaskIDCommitment, selectedNode.Address)
+					// the task may be cancelled by its creator while dispatching
+					if err := task.SyncStatus(ctx, config.GetDB()); err == nil && task.Status != models.TaskQueued {
+						log.Debugf("StartTask: task %s is not queued anymore, stop dispatching", task.TaskIDCommitment)
+						return
+					}
+					// the creator may raise the task fee to get a node sooner
+					if err := task.SyncTaskFee(ctx, config.GetDB()); err != nil {
+						log.Errorf("StartTask: sync task %s fee error: %v", task.TaskIDCommitment, err)
+					}
 				}
 			} else if err != nil {
 				log.Errorf("StartTask: select node for task %s error: %v", task.TaskIDCommitment, err)
@@ -206,7 +250,11 @@ func (d *TaskDispatcher) Dispatch(ctx context.Context, task *models.InferenceTas
 				log.Debugf("StartTask: no available node for task %s", task.TaskIDCommitment)
 			}
 			randomSleep := rand.Intn(500) + 500
-			time.Sleep(time.Duration(randomSleep) * time.Millisecond)
+			select {
+			case <-ctx.Done():
+				return
+			case <-utils.After(time.Duration(randomSleep) * time.Millisecond):
+			}
 		}
 	}
 }
@@ -225,11 +273,24 @@ func (d *TaskDispatcher) processDispatchedTasks(ctx context.Context) error {
 			dispatchedTask, _ := t.(*DispatchedTask)
 			log.Debugf("StartTask: start processing dispatched tasks, task %s started on node %s", dispatchedTask.task.TaskIDCommitment, dispatchedTask.node.Address)
 
-			if time.Now().Before(dispatchedTask.createdAt.Add(time.Second)) {
+			if wait := dispatchedTask.createdAt.Add(time.Second).Sub(utils.Now()); wait > 0 {
 				log.Debugf("StartTask: task %s is still waiting for other tasks, skip", dispatchedTask.task.TaskIDCommitment)
-				d.nodeQueue <- nodeAddress
+				d.wg.Add(1)
+				go func() {
+					defer d.wg.Done()
+					select {
+					case <-ctx.Done():
+					case <-utils.After(wait):
+						select {
+						case d.nodeQueue <- nodeAddress:
+						case <-ctx.Done():
+						}
+					}
+				}()
 			} else {
+				d.wg.Add(1)
 				go func() {
+					defer d.wg.Done()
 					d.startTaskLimiter <- struct{}{}
 					defer func() {
 						<-d.startTaskLimiter
@@ -246,6 +307,7 @@ func (d *TaskDispatcher) processDispatchedTasks(ctx context.Context) error {
 					d.taskMap.Delete(dispatchedTask.node.Address)
 
 					if success {
+						d.policy.TaskStarted(dispatchedTask.task)
 						log.Debugf("StartTask: process dispatched tasks success, task %s started on node %s", dispatchedTask.task.TaskIDCommitment, dispatchedTask.node.Address)
 					} else {
 						if errors.Is(err, errWrongTaskStatus) || errors.Is(err, models.ErrTaskStatusChanged) {
@@ -263,9 +325,24 @@ func (d *TaskDispatcher) processDispatchedTasks(ctx context.Context) error {
 	}
 }
 
-func StartTaskProcesser(ctx context.Context) {
-	taskDispatcher := NewTaskDispatcher()
+// Run dispatches the queued tasks until ctx is done.
+// The dispatcher waits on the clock of the task lifecycle, so the simulator can run it in virtual time.
+func (d *TaskDispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		d.processDispatchedTasks(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		d.getQueuedTasks(ctx)
+	}()
+	wg.Wait()
+	// the tasks being dispatched or started stop after ctx is done as well
+	d.wg.Wait()
+}
 
-	go taskDispatcher.processDispatchedTasks(ctx)
-	go taskDispatcher.getQueuedTasks(ctx)
+func StartTaskProcesser(ctx context.Context) {
+	NewTaskDispatcher(NewQueuePolicy()).Run(ctx)
 }
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"crynux_relay/utils"
+	"database/sql"
+	"errors"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+func startTaskAttempt(ctx context.Context, tx *gorm.DB, task *models.InferenceTask) error {
+	attempt := &models.InferenceTaskAttempt{
+		TaskIDCommitment: task.TaskIDCommitment,
+		Attempt:          task.Attempts,
+		NodeAddress:      task.SelectedNode,
+		StartTime:        task.StartTime,
+		Outcome:          models.TaskAttemptRunning,
+	}
+	return attempt.Create(ctx, tx)
+}
+
+func finishTaskAttempt(ctx context.Context, tx *gorm.DB, task *models.InferenceTask, outcome models.TaskAttemptOutcome) error {
+	// tasks started before attempts are recorded have no attempt
+	if task.Attempts == 0 {
+		return nil
+	}
+	return models.FinishTaskAttempt(ctx, tx, task.TaskIDCommitment, task.Attempts, outcome, task.AbortReason)
+}
+
+// isNodeFault returns whether the task is aborted because of its selected node,
+// so that the task can be run by another node
+func isNodeFault(task *models.InferenceTask) bool {
+	if len(task.SelectedNode) == 0 {
+		return false
+	}
+	if task.Status != models.TaskStarted && task.Status != models.TaskParametersUploaded {
+		return false
+	}
+	return task.AbortReason == models.TaskAbortTimeout || task.AbortReason == models.TaskAbortModelDownloadFailed || task.AbortReason == models.TaskAbortNodeSlashed
+}
+
+func getTaskFailedNodes(ctx context.Context, db *gorm.DB, task *models.InferenceTask) (map[string]bool, error) {
+	res := make(map[string]bool)
+	if len(task.DisputeTaskIDCommitment) > 0 {
+		excludedNodes, err := getDisputeExcludedNodes(ctx, db, task)
+		if err != nil {
+			return nil, err
+		}
+		res = excludedNodes
+	}
+	if len(task.SpotCheckTaskIDCommitment) > 0 {
+		spotCheck, err := models.GetSpotCheck(ctx, db, task.SpotCheckTaskIDCommitment)
+		if err != nil {
+			return nil, err
+		}
+		res[spotCheck.SelectedNode] = true
+	}
+	if task.Attempts == 0 {
+		return res, nil
+	}
+	attempts, err := models.GetTaskAttempts(ctx, db, task.TaskIDCommitment)
+	if err != nil {
+		return nil, err
+	}
+	for _, attempt := range attempts {
+		if attempt.Outcome == models.TaskAttemptRequeued {
+			res[attempt.NodeAddress] = true
+		}
+	}
+	return res, nil
+}
+
+// AbortTask aborts the task and refunds the task fee to the creator. If the task fails by
+// the fault of its selected node and it has attempts left, it is requeued instead.
+func AbortTask(ctx context.Context, db *gorm.DB, originTask *models.InferenceTask, abortIssuer string) error {
+	if isNodeFault(originTask) && originTask.Attempts < originTask.MaxAttempts {
+		return SetTaskStatusRequeued(ctx, db, originTask)
+	}
+	return SetTaskStatusEndAborted(ctx, db, originTask, abortIssuer)
+}
+
+func SetTaskStatusRequeued(ctx context.Context, db *gorm.DB, originTask *models.InferenceTask) error {
+	task := *originTask
+	if !isNodeFault(&task) {
+		return errWrongTaskStatus
+	}
+	if _, err := getTaskTransition(&task, models.TaskStatusEventRequeue); err != nil {
+		return err
+	}
+	failedNode := task.SelectedNode
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := finishTaskAttempt(ctx, tx, &task, models.TaskAttemptRequeued); err != nil {
+			return err
+		}
+
+		if err := transitTaskStatus(ctx, tx, &task, models.TaskStatusEventRequeue, config.GetConfig().Blockchain.Account.Address, originTask.AbortReason.String(), map[string]interface{}{
+			"selected_node":         "",
+			"start_time":            sql.NullTime{},
+			"queued_time":           sql.NullTime{Time: utils.Now(), Valid: true},
+			"model_swtiched":        false,
+			"abort_reason":          models.TaskAbortReasonNone,
+			"progress_percent":      0,
+			"progress_step":         0,
+			"progress_stage":        "",
+			"progress_updated_time": sql.NullTime{},
+		}); err != nil {
+			return err
+		}
+
+		// the next attempt streams from the beginning
+		if models.IsStreamingTaskType(task.TaskType) {
+			if err := models.DeleteTaskStreamChunks(ctx, tx, task.TaskIDCommitment); err != nil {
+				return err
+			}
+		}
+
+		node, err := checkTaskSelectedNode(ctx, tx, originTask)
+		if errors.Is(err, errWrongNodeCurrentTask) {
+			// a slashed node has quit before its task is requeued
+			if originTask.AbortReason != models.TaskAbortNodeSlashed {
+				log.Errorf("TaskRequeued: node current task is wrong, task: %s, node: %s", task.TaskIDCommitment, failedNode)
+			}
+		} else if err != nil {
+			return err
+		} else if err := releaseTaskNode(ctx, tx, originTask, node); err != nil {
+			return err
+		}
+
+		return emitEvent(ctx, tx, &models.TaskRequeuedEvent{
+			TaskIDCommitment: task.TaskIDCommitment,
+			SelectedNode:     failedNode,
+			AbortReason:      originTask.AbortReason,
+			Attempts:         task.Attempts,
+		})
+	}); err != nil {
+		return err
+	}
+	// the requeue resets the fields of the attempt, the task is reloaded to drop those of the failed attempt
+	newTask, err := models.GetTaskByIDCommitment(ctx, db, task.TaskIDCommitment)
+	if err != nil {
+		return err
+	}
+	*originTask = *newTask
+	return nil
+}
+
+// releaseTaskNode frees the selected node of a requeued or aborted task.
+// The qos score of the node is updated only if the task is scored, failures by timeout
+// are not scored but counted in the recent tasks of the node when it is kicked out.
+func releaseTaskNode(ctx context.Context, db *gorm.DB, task *models.InferenceTask, node *models.Node) error {
+	if task.QOSScore.Valid {
+		if err := updateNodeQosScore(ctx, db, node, uint64(task.QOSScore.Int64)); err != nil {
+			return err
+		}
+	}
+	return nodeFinishTask(ctx, db, node)
+}
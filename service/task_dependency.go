@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"crynux_relay/storage"
+	"crynux_relay/utils"
+	"database/sql"
+	"errors"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+func createTaskDependencies(ctx context.Context, tx *gorm.DB, task *models.InferenceTask, dependsOn []string) error {
+	if len(dependsOn) == 0 {
+		return nil
+	}
+	deps := make([]models.TaskDependency, len(dependsOn))
+	for i, parent := range dependsOn {
+		deps[i] = models.TaskDependency{
+			TaskIDCommitment:       task.TaskIDCommitment,
+			ParentTaskIDCommitment: parent,
+		}
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return tx.WithContext(dbCtx).Create(&deps).Error
+}
+
+type parentTaskState int
+
+const (
+	parentTaskPending parentTaskState = iota
+	parentTaskSucceeded
+	parentTaskFailed
+)
+
+// getParentTaskState returns the state of the parent task, and the task which holds
+// the parent results when the parent has succeeded.
+// A parent task refunded in a task group succeeds with the results of the task
+// which is validated in the group.
+func getParentTaskState(ctx context.Context, db *gorm.DB, parent *models.InferenceTask) (parentTaskState, *models.InferenceTask, error) {
+	switch parent.Status {
+	case models.TaskEndSuccess, models.TaskEndGroupSuccess:
+		return parentTaskSucceeded, parent, nil
+	case models.TaskEndAborted, models.TaskEndInvalidated:
+		return parentTaskFailed, nil, nil
+	case models.TaskEndGroupRefund:
+		tasks, err := models.GetTaskGroupByTaskID(ctx, db, parent.TaskID)
+		if err != nil {
+			return parentTaskPending, nil, err
+		}
+		for i, t := range tasks {
+			if t.Status == models.TaskEndGroupSuccess {
+				return parentTaskSucceeded, &tasks[i], nil
+			}
+			if t.Status == models.TaskGroupValidated {
+				return parentTaskPending, nil, nil
+			}
+		}
+		return parentTaskFailed, nil, nil
+	default:
+		return parentTaskPending, nil, nil
+	}
+}
+
+func ProcessWaitingTask(ctx context.Context, db *gorm.DB, task *models.InferenceTask) error {
+	if task.Status != models.TaskWaiting {
+		return errWrongTaskStatus
+	}
+
+	parentIDCommitments, err := models.GetTaskParentIDCommitments(ctx, db, task.TaskIDCommitment)
+	if err != nil {
+		return err
+	}
+	parents, err := models.GetTasksByIDCommitments(ctx, db, parentIDCommitments)
+	if err != nil {
+		return err
+	}
+	parentMap := make(map[string]*models.InferenceTask)
+	for i := range parents {
+		parentMap[parents[i].TaskIDCommitment] = &parents[i]
+	}
+
+	var resultTasks []*models.InferenceTask
+	for _, parentIDCommitment := range parentIDCommitments {
+		parent, ok := parentMap[parentIDCommitment]
+		if !ok {
+			return errors.New("parent task not found: " + parentIDCommitment)
+		}
+		state, resultTask, err := getParentTaskState(ctx, db, parent)
+		if err != nil {
+			return err
+		}
+		if state == parentTaskFailed {
+			log.Infof("TaskDependency: parent task %s of task %s failed, abort", parentIDCommitment, task.TaskIDCommitment)
+			task.AbortReason = models.TaskAbortParentFailed
+			task.ValidatedTime = sql.NullTime{Time: utils.Now(), Valid: true}
+			appConfig := config.GetConfig()
+			return SetTaskStatusEndAborted(ctx, db, task, appConfig.Blockchain.Account.Address)
+		}
+		if state == parentTaskPending {
+			return nil
+		}
+		resultTasks = append(resultTasks, resultTask)
+	}
+
+	if err := copyParentCheckpoint(ctx, task, resultTasks); err != nil {
+		return err
+	}
+	log.Infof("TaskDependency: all parent tasks of task %s succeeded, release", task.TaskIDCommitment)
+	return SetTaskStatusQueued(ctx, db, task)
+}
+
+// copyParentCheckpoint uses the first result checkpoint of the parent tasks as the
+// input checkpoint of the task, unless the creator has uploaded one
+func copyParentCheckpoint(ctx context.Context, task *models.InferenceTask, parents []*models.InferenceTask) error {
+	s := storage.GetStorage()
+
+	dstKey := storage.TaskInputKey(task.TaskIDCommitment, "checkpoint.zip")
+	if _, err := s.Stat(ctx, dstKey); err == nil {
+		return nil
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+
+	for _, parent := range parents {
+		srcKey := storage.TaskResultKey(parent.TaskIDCommitment, "checkpoint.zip")
+		err := storage.Copy(ctx, s, srcKey, dstKey)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		return err
+	}
+	return nil
+}
+
+func SetTaskStatusQueued(ctx context.Context, db *gorm.DB, originTask *models.InferenceTask) error {
+	task := *originTask
+	if _, err := getTaskTransition(&task, models.TaskStatusEventRelease); err != nil {
+		return err
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := transitTaskStatus(ctx, tx, &task, models.TaskStatusEventRelease, config.GetConfig().Blockchain.Account.Address, "", map[string]interface{}{
+			"queued_time": sql.NullTime{Time: utils.Now(), Valid: true},
+		}); err != nil {
+			return err
+		}
+		return emitEvent(ctx, tx, &models.TaskQueuedEvent{
+			TaskIDCommitment: task.TaskIDCommitment,
+		})
+	}); err != nil {
+		return err
+	}
+	*originTask = task
+	return nil
+}
@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"crynux_relay/utils"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"gorm.io/gorm"
+)
+
+// a disputed task is rerun on this number of other nodes
+const disputeVerificationTaskCount = 2
+
+var (
+	// the payment of the task is held for a spot check, which refunds the creator if the node is wrong
+	ErrTaskSpotCheckPending = errors.New("task payment is held for a spot check")
+	// the creator is refunded by the failed spot check of the task already
+	ErrTaskSpotCheckFailed = errors.New("task is refunded by a spot check")
+)
+
+func GetDisputeDeposit() *big.Int {
+	return utils.EtherToWei(new(big.Int).SetUint64(config.GetConfig().Dispute.Deposit))
+}
+
+// GetDisputeCost returns the amount paid by the creator to open a dispute of the task,
+// which is the deposit and the task fee of the verification tasks
+func GetDisputeCost(task *models.InferenceTask) *big.Int {
+	cost := new(big.Int).Mul(&task.TaskFee.Int, big.NewInt(disputeVerificationTaskCount))
+	return cost.Add(cost, GetDisputeDeposit())
+}
+
+// newRerunTask copies the task with a new task id, to run the task again on another node
+func newRerunTask(task *models.InferenceTask) (*models.InferenceTask, error) {
+	taskIDBytes := make([]byte, 32)
+	if _, err := rand.Read(taskIDBytes); err != nil {
+		return nil, err
+	}
+	nonceBytes := make([]byte, 32)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return nil, err
+	}
+	taskIDCommitment := crypto.Keccak256Hash(append(taskIDBytes, nonceBytes...))
+
+	// the rerun task is validated by the relay, so its task id is known from the start
+	return &models.InferenceTask{
+		TaskArgs:           task.TaskArgs,
+		TaskIDCommitment:   taskIDCommitment.Hex(),
+		Creator:            task.Creator,
+		SamplingSeed:       task.SamplingSeed,
+		Nonce:              hexutil.Encode(nonceBytes),
+		Status:             models.TaskQueued,
+		TaskType:           task.TaskType,
+		TaskVersion:        task.TaskVersion,
+		Timeout:            task.Timeout,
+		MinVRAM:            task.MinVRAM,
+		RequiredGPU:        task.RequiredGPU,
+		RequiredGPUVRAM:    task.RequiredGPUVRAM,
+		NodeRequirement:    task.NodeRequirement,
+		TaskFee:            task.TaskFee,
+		TaskSize:           task.TaskSize,
+		ModelIDs:           task.ModelIDs,
+		TaskID:             hexutil.Encode(taskIDBytes),
+		MaxAttempts:        task.MaxAttempts,
+		Validator:          task.Validator,
+		ValidatorThreshold: task.ValidatorThreshold,
+		CreateTime:         sql.NullTime{Time: utils.Now(), Valid: true},
+	}, nil
+}
+
+// OpenTaskDispute takes the dispute cost from the creator and reruns the task on other nodes
+func OpenTaskDispute(ctx context.Context, db *gorm.DB, task *models.InferenceTask) (*models.TaskDispute, error) {
+	if task.Status != models.TaskEndSuccess || len(task.DisputeTaskIDCommitment) > 0 {
+		return nil, errWrongTaskStatus
+	}
+
+	var verificationTasks []*models.InferenceTask
+	var verificationTaskIDCommitments []string
+	for range disputeVerificationTaskCount {
+		verificationTask, err := newRerunTask(task)
+		if err != nil {
+			return nil, err
+		}
+		verificationTask.DisputeTaskIDCommitment = task.TaskIDCommitment
+		verificationTasks = append(verificationTasks, verificationTask)
+		verificationTaskIDCommitments = append(verificationTaskIDCommitments, verificationTask.TaskIDCommitment)
+	}
+
+	dispute := &models.TaskDispute{
+		TaskIDCommitment:              task.TaskIDCommitment,
+		Creator:                       task.Creator,
+		SelectedNode:                  task.SelectedNode,
+		Deposit:                       models.BigInt{Int: *GetDisputeDeposit()},
+		VerificationTaskIDCommitments: verificationTaskIDCommitments,
+		Status:                        models.TaskDisputeOpen,
+		ClawbackAmount:                models.BigInt{Int: *big.NewInt(0)},
+	}
+
+	appConfig := config.GetConfig()
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		// a spot check only leaves the pending status once, so the creator cannot be refunded by both the spot check and the dispute
+		spotCheck, err := models.GetSpotCheck(ctx, tx, task.TaskIDCommitment)
+		if err == nil {
+			if spotCheck.Status == models.SpotCheckPending {
+				return ErrTaskSpotCheckPending
+			}
+			if spotCheck.Status == models.SpotCheckFailed {
+				return ErrTaskSpotCheckFailed
+			}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err := Transfer(ctx, tx, task.Creator, appConfig.Blockchain.Account.Address, GetDisputeCost(task)); err != nil {
+			return err
+		}
+		if err := dispute.Create(ctx, tx); err != nil {
+			return err
+		}
+		for _, verificationTask := range verificationTasks {
+			if err := verificationTask.Create(ctx, tx); err != nil {
+				return err
+			}
+			if err := logTaskCreated(ctx, tx, verificationTask); err != nil {
+				return err
+			}
+		}
+		if err := emitEvent(ctx, tx, &models.TaskDisputeOpenedEvent{
+			TaskIDCommitment:              dispute.TaskIDCommitment,
+			Creator:                       dispute.Creator,
+			SelectedNode:                  dispute.SelectedNode,
+			Deposit:                       dispute.Deposit,
+			VerificationTaskIDCommitments: verificationTaskIDCommitments,
+		}); err != nil {
+			return err
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return dispute, nil
+}
+
+// getDisputeExcludedNodes returns the nodes which should not run the verification task:
+// the disputed node, and the nodes running the other verification tasks of the dispute
+func getDisputeExcludedNodes(ctx context.Context, db *gorm.DB, task *models.InferenceTask) (map[string]bool, error) {
+	res := make(map[string]bool)
+	dispute, err := models.GetTaskDispute(ctx, db, task.DisputeTaskIDCommitment)
+	if err != nil {
+		return nil, err
+	}
+	res[dispute.SelectedNode] = true
+
+	verificationTasks, err := models.GetDisputeVerificationTasks(ctx, db, task.DisputeTaskIDCommitment)
+	if err != nil {
+		return nil, err
+	}
+	for _, verificationTask := range verificationTasks {
+		if verificationTask.TaskIDCommitment != task.TaskIDCommitment && len(verificationTask.SelectedNode) > 0 {
+			res[verificationTask.SelectedNode] = true
+		}
+	}
+	return res, nil
+}
+
+func isVerificationTaskFinished(task *models.InferenceTask) bool {
+	return task.Status == models.TaskScoreReady || task.Status == models.TaskErrorReported || task.Status == models.TaskEndAborted
+}
+
+// decideTaskDispute compares the score of the disputed task with the verification tasks.
+// The dispute is rejected if any verification task agrees with the disputed task,
+// and upheld if all verification tasks agree with each other on a different result.
+func decideTaskDispute(task *models.InferenceTask, verificationTasks []models.InferenceTask) (models.TaskDisputeStatus, []models.ValidationComparison) {
+	comparisons := make([]models.ValidationComparison, len(verificationTasks))
+	var scoredTasks []*models.InferenceTask
+	agreed := false
+	for i := range verificationTasks {
+		verificationTask := &verificationTasks[i]
+		if verificationTask.Status == models.TaskScoreReady {
+			comparisons[i] = compareScore(task, verificationTask)
+			scoredTasks = append(scoredTasks, verificationTask)
+			if comparisons[i].Same {
+				agreed = true
+			}
+		} else {
+			comparisons[i] = models.ValidationComparison{TaskIDCommitment: verificationTask.TaskIDCommitment}
+		}
+	}
+
+	if agreed {
+		return models.TaskDisputeRejected, comparisons
+	}
+	if len(scoredTasks) == len(verificationTasks) {
+		for _, scoredTask := range scoredTasks[1:] {
+			if !compareScore(scoredTasks[0], scoredTask).Same {
+				return models.TaskDisputeInconclusive, comparisons
+			}
+		}
+		return models.TaskDisputeUpheld, comparisons
+	}
+	return models.TaskDisputeInconclusive, comparisons
+}
+
+// ResolveTaskDispute resolves the dispute after all its verification tasks finish running.
+// When the dispute is upheld, the task fee is taken back from the node as much as its balance allows,
+// the node is slashed and the deposit is returned to the creator.
+// When the dispute is rejected, the deposit goes to the node.
+// Otherwise the deposit is returned to the creator.
+func ResolveTaskDispute(ctx context.Context, db *gorm.DB, originDispute *models.TaskDispute) error {
+	dispute := *originDispute
+	if dispute.Status != models.TaskDisputeOpen {
+		return nil
+	}
+
+	verificationTasks, err := models.GetDisputeVerificationTasks(ctx, db, dispute.TaskIDCommitment)
+	if err != nil {
+		return err
+	}
+	if len(verificationTasks) != disputeVerificationTaskCount {
+		return errors.New("verification tasks of the dispute are missing")
+	}
+	for i := range verificationTasks {
+		if !isVerificationTaskFinished(&verificationTasks[i]) {
+			return nil
+		}
+	}
+
+	task, err := models.GetTaskByIDCommitment(ctx, db, dispute.TaskIDCommitment)
+	if err != nil {
+		return err
+	}
+	status, comparisons := decideTaskDispute(task, verificationTasks)
+
+	appConfig := config.GetConfig()
+	if err := db.Transaction(func(tx *gorm.DB) error {
+
+		// verification tasks are validated like single tasks
+		for i := range verificationTasks {
+			verificationTask := &verificationTasks[i]
+			if verificationTask.Status == models.TaskScoreReady {
+				if err := SetTaskStatusValidated(ctx, tx, verificationTask); err != nil {
+					return err
+				}
+			} else if verificationTask.Status == models.TaskErrorReported {
+				verificationTask.AbortReason = models.TaskAbortIncorrectResult
+				verificationTask.ValidatedTime = sql.NullTime{Time: utils.Now(), Valid: true}
+				if err := SetTaskStatusEndAborted(ctx, tx, verificationTask, verificationTask.Creator); err != nil {
+					return err
+				}
+			}
+			if err := emitEvent(ctx, tx, &models.TaskDisputeVerifiedEvent{
+				TaskIDCommitment:             dispute.TaskIDCommitment,
+				VerificationTaskIDCommitment: verificationTask.TaskIDCommitment,
+				VerificationNode:             verificationTask.SelectedNode,
+				VerificationStatus:           verificationTask.Status,
+				Same:                         comparisons[i].Same,
+				Distances:                    comparisons[i].Distances,
+			}); err != nil {
+				return err
+			}
+		}
+
+		clawbackAmount := big.NewInt(0)
+		depositReceiver := dispute.Creator
+		if status == models.TaskDisputeUpheld {
+			balance, err := GetBalance(ctx, tx, dispute.SelectedNode)
+			if err != nil {
+				return err
+			}
+			clawbackAmount.Set(&task.TaskFee.Int)
+			if balance.Cmp(clawbackAmount) < 0 {
+				clawbackAmount.Set(balance)
+			}
+			if clawbackAmount.Sign() > 0 {
+				if err := Transfer(ctx, tx, dispute.SelectedNode, dispute.Creator, clawbackAmount); err != nil {
+					return err
+				}
+			}
+
+			node, err := models.GetNodeByAddress(ctx, tx, dispute.SelectedNode)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if err == nil && node.Status != models.NodeStatusQuit {
+				if err := nodeSlash(ctx, tx, node); err != nil {
+					return err
+				}
+			}
+		} else if status == models.TaskDisputeRejected {
+			depositReceiver = dispute.SelectedNode
+		}
+
+		if err := Transfer(ctx, tx, appConfig.Blockchain.Account.Address, depositReceiver, &dispute.Deposit.Int); err != nil {
+			return err
+		}
+
+		if err := dispute.Update(ctx, tx, map[string]interface{}{
+			"status":          status,
+			"clawback_amount": models.BigInt{Int: *clawbackAmount},
+			"resolved_time":   sql.NullTime{Time: utils.Now(), Valid: true},
+		}); err != nil {
+			return err
+		}
+		if err := emitEvent(ctx, tx, &models.TaskDisputeResolvedEvent{
+			TaskIDCommitment: dispute.TaskIDCommitment,
+			SelectedNode:     dispute.SelectedNode,
+			Status:           status,
+			ClawbackAmount:   models.BigInt{Int: *clawbackAmount},
+			DepositReceiver:  depositReceiver,
+		}); err != nil {
+			return err
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	*originDispute = dispute
+	return nil
+}
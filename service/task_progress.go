@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"crynux_relay/models"
+	"crynux_relay/utils"
+	"database/sql"
+
+	"gorm.io/gorm"
+)
+
+func SetTaskProgress(ctx context.Context, db *gorm.DB, originTask *models.InferenceTask, percent, step uint64, stage string) error {
+	task := *originTask
+	if task.Status != models.TaskStarted && task.Status != models.TaskParametersUploaded {
+		return errWrongTaskStatus
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		// keep the status unchanged, progress of a finished task is dropped
+		if err := task.Update(ctx, tx, map[string]interface{}{
+			"status":                task.Status,
+			"progress_percent":      percent,
+			"progress_step":         step,
+			"progress_stage":        stage,
+			"progress_updated_time": sql.NullTime{Time: utils.Now(), Valid: true},
+		}); err != nil {
+			return err
+		}
+		return emitEvent(ctx, tx, &models.TaskProgressEvent{
+			TaskIDCommitment: task.TaskIDCommitment,
+			SelectedNode:     task.SelectedNode,
+			Percent:          percent,
+			Step:             step,
+			Stage:            stage,
+		})
+	}); err != nil {
+		return err
+	}
+	*originTask = task
+	return nil
+}
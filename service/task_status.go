@@ -19,18 +19,53 @@ var (
 	errWrongNodeCurrentTask = errors.New("node current task is wrong")
 )
 
-func CreateTask(ctx context.Context, db *gorm.DB, task *models.InferenceTask) error {
+func CreateTask(ctx context.Context, db *gorm.DB, task *models.InferenceTask, dependsOn []string) error {
 	appConfig := config.GetConfig()
 
 	return db.Transaction(func(tx *gorm.DB) error {
 		if err := task.Create(ctx, tx); err != nil {
 			return err
 		}
-		commitFunc, err := Transfer(ctx, tx, task.Creator, appConfig.Blockchain.Account.Address, &task.TaskFee.Int)
-		if err != nil {
+		if err := logTaskCreated(ctx, tx, task); err != nil {
+			return err
+		}
+		if err := createTaskDependencies(ctx, tx, task, dependsOn); err != nil {
+			return err
+		}
+		if err := Transfer(ctx, tx, task.Creator, appConfig.Blockchain.Account.Address, &task.TaskFee.Int); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+func CreateTasks(ctx context.Context, db *gorm.DB, tasks []*models.InferenceTask, dependsOn map[string][]string) error {
+	appConfig := config.GetConfig()
+
+	creator := tasks[0].Creator
+	totalFee := big.NewInt(0)
+	for _, task := range tasks {
+		if task.Creator != creator {
+			return errors.New("tasks in a batch must have the same creator")
+		}
+		totalFee.Add(totalFee, &task.TaskFee.Int)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, task := range tasks {
+			if err := task.Create(ctx, tx); err != nil {
+				return err
+			}
+			if err := logTaskCreated(ctx, tx, task); err != nil {
+				return err
+			}
+			if err := createTaskDependencies(ctx, tx, task, dependsOn[task.TaskIDCommitment]); err != nil {
+				return err
+			}
+		}
+		if err := Transfer(ctx, tx, creator, appConfig.Blockchain.Account.Address, totalFee); err != nil {
 			return err
 		}
-		commitFunc()
 		return nil
 	})
 }
@@ -39,8 +74,8 @@ func SetTaskStatusStarted(ctx context.Context, db *gorm.DB, originTask *models.I
 	task := *originTask
 	node := *originNode
 
-	if task.Status != models.TaskQueued {
-		return errWrongTaskStatus
+	if _, err := getTaskTransition(&task, models.TaskStatusEventStart); err != nil {
+		return err
 	}
 	var inUseModelIDs []string
 	for _, model := range node.Models {
@@ -51,14 +86,22 @@ func SetTaskStatusStarted(ctx context.Context, db *gorm.DB, originTask *models.I
 
 	// start inference task
 	err := db.Transaction(func(tx *gorm.DB) error {
-		if err := task.Update(ctx, tx, map[string]interface{}{
+		startTime := sql.NullTime{Time: utils.Now(), Valid: true}
+		attempts := task.Attempts + 1
+		if err := transitTaskStatus(ctx, tx, &task, models.TaskStatusEventStart, config.GetConfig().Blockchain.Account.Address, "", map[string]interface{}{
 			"selected_node":  node.Address,
-			"start_time":     sql.NullTime{Time: time.Now(), Valid: true},
-			"status":         models.TaskStarted,
+			"start_time":     startTime,
 			"model_swtiched": !isSameModels(inUseModelIDs, task.ModelIDs),
+			"attempts":       attempts,
 		}); err != nil {
 			return err
 		}
+		task.SelectedNode = node.Address
+		task.StartTime = startTime
+		task.Attempts = attempts
+		if err := startTaskAttempt(ctx, tx, &task); err != nil {
+			return err
+		}
 
 		if err := nodeStartTask(ctx, tx, &node, task.TaskIDCommitment, task.ModelIDs); err != nil {
 			return err
@@ -130,8 +173,8 @@ func checkTaskSelectedNode(ctx context.Context, db *gorm.DB, task *models.Infere
 
 func SetTaskStatusScoreReady(ctx context.Context, db *gorm.DB, originTask *models.InferenceTask) error {
 	task := *originTask
-	if task.Status != models.TaskStarted {
-		return errWrongTaskStatus
+	if _, err := getTaskTransition(&task, models.TaskStatusEventReportScore); err != nil {
+		return err
 	}
 	_, err := checkTaskSelectedNode(ctx, db, &task)
 	if err != nil {
@@ -139,10 +182,9 @@ func SetTaskStatusScoreReady(ctx context.Context, db *gorm.DB, originTask *model
 	}
 
 	if err := db.Transaction(func(tx *gorm.DB) error {
-		err = task.Update(ctx, tx, map[string]interface{}{
-			"status":           models.TaskScoreReady,
+		err = transitTaskStatus(ctx, tx, &task, models.TaskStatusEventReportScore, task.SelectedNode, "", map[string]interface{}{
 			"score":            task.Score,
-			"score_ready_time": sql.NullTime{Time: time.Now(), Valid: true},
+			"score_ready_time": sql.NullTime{Time: utils.Now(), Valid: true},
 		})
 		if err != nil {
 			return err
@@ -161,18 +203,17 @@ func SetTaskStatusScoreReady(ctx context.Context, db *gorm.DB, originTask *model
 
 func SetTaskStatusErrorReported(ctx context.Context, db *gorm.DB, originTask *models.InferenceTask) error {
 	task := *originTask
-	if task.Status != models.TaskStarted {
-		return errWrongTaskStatus
+	if _, err := getTaskTransition(&task, models.TaskStatusEventReportError); err != nil {
+		return err
 	}
 	_, err := checkTaskSelectedNode(ctx, db, &task)
 	if err != nil {
 		return err
 	}
 	if err := db.Transaction(func(tx *gorm.DB) error {
-		err = task.Update(ctx, tx, map[string]interface{}{
-			"status":           models.TaskErrorReported,
+		err = transitTaskStatus(ctx, tx, &task, models.TaskStatusEventReportError, task.SelectedNode, task.TaskError.String(), map[string]interface{}{
 			"task_error":       task.TaskError,
-			"score_ready_time": sql.NullTime{Time: time.Now(), Valid: true},
+			"score_ready_time": sql.NullTime{Time: utils.Now(), Valid: true},
 		})
 		if err != nil {
 			return err
@@ -191,8 +232,8 @@ func SetTaskStatusErrorReported(ctx context.Context, db *gorm.DB, originTask *mo
 
 func SetTaskStatusValidated(ctx context.Context, db *gorm.DB, originTask *models.InferenceTask) error {
 	task := *originTask
-	if task.Status != models.TaskScoreReady {
-		return errWrongTaskStatus
+	if _, err := getTaskTransition(&task, models.TaskStatusEventValidate); err != nil {
+		return err
 	}
 	_, err := checkTaskSelectedNode(ctx, db, &task)
 	if err != nil {
@@ -200,9 +241,8 @@ func SetTaskStatusValidated(ctx context.Context, db *gorm.DB, originTask *models
 	}
 
 	if err := db.Transaction(func(tx *gorm.DB) error {
-		err = task.Update(ctx, tx, map[string]interface{}{
-			"status":         models.TaskValidated,
-			"validated_time": sql.NullTime{Time: time.Now(), Valid: true},
+		err = transitTaskStatus(ctx, tx, &task, models.TaskStatusEventValidate, task.Creator, "", map[string]interface{}{
+			"validated_time": sql.NullTime{Time: utils.Now(), Valid: true},
 		})
 		if err != nil {
 			return err
@@ -217,8 +257,8 @@ func SetTaskStatusValidated(ctx context.Context, db *gorm.DB, originTask *models
 
 func SetTaskStatusGroupValidated(ctx context.Context, db *gorm.DB, originTask *models.InferenceTask) error {
 	task := *originTask
-	if task.Status != models.TaskScoreReady {
-		return errWrongTaskStatus
+	if _, err := getTaskTransition(&task, models.TaskStatusEventGroupValidate); err != nil {
+		return err
 	}
 	node, err := checkTaskSelectedNode(ctx, db, &task)
 	if err != nil {
@@ -226,9 +266,8 @@ func SetTaskStatusGroupValidated(ctx context.Context, db *gorm.DB, originTask *m
 	}
 
 	if err := db.Transaction(func(tx *gorm.DB) error {
-		if err = task.Update(ctx, tx, map[string]interface{}{
-			"status":         models.TaskGroupValidated,
-			"validated_time": sql.NullTime{Time: time.Now(), Valid: true},
+		if err = transitTaskStatus(ctx, tx, &task, models.TaskStatusEventGroupValidate, task.Creator, "", map[string]interface{}{
+			"validated_time": sql.NullTime{Time: utils.Now(), Valid: true},
 			"qos_score":      task.QOSScore,
 		}); err != nil {
 			return err
@@ -249,8 +288,8 @@ func SetTaskStatusGroupValidated(ctx context.Context, db *gorm.DB, originTask *m
 
 func SetTaskStatusEndInvalidated(ctx context.Context, db *gorm.DB, originTask *models.InferenceTask) error {
 	task := *originTask
-	if task.Status != models.TaskScoreReady && task.Status != models.TaskEndAborted && task.Status != models.TaskErrorReported {
-		return errWrongTaskStatus
+	if _, err := getTaskTransition(&task, models.TaskStatusEventInvalidate); err != nil {
+		return err
 	}
 
 	node, err := checkTaskSelectedNode(ctx, db, &task)
@@ -259,14 +298,16 @@ func SetTaskStatusEndInvalidated(ctx context.Context, db *gorm.DB, originTask *m
 	}
 
 	if err := db.Transaction(func(tx *gorm.DB) error {
-		err = task.Update(ctx, tx, map[string]interface{}{
-			"status":         models.TaskEndInvalidated,
-			"validated_time": sql.NullTime{Time: time.Now(), Valid: true},
+		err = transitTaskStatus(ctx, tx, &task, models.TaskStatusEventInvalidate, task.Creator, models.TaskAbortIncorrectResult.String(), map[string]interface{}{
+			"validated_time": sql.NullTime{Time: utils.Now(), Valid: true},
 			"qos_score":      0,
 		})
 		if err != nil {
 			return err
 		}
+		if err := finishTaskAttempt(ctx, tx, &task, models.TaskAttemptInvalidated); err != nil {
+			return err
+		}
 		nodeSlash(ctx, tx, node)
 		return emitEvent(ctx, tx, &models.TaskEndInvalidatedEvent{TaskIDCommitment: task.TaskIDCommitment, SelectedNode: task.SelectedNode})
 	}); err != nil {
@@ -278,8 +319,8 @@ func SetTaskStatusEndInvalidated(ctx context.Context, db *gorm.DB, originTask *m
 
 func SetTaskStatusEndGroupRefund(ctx context.Context, db *gorm.DB, originTask *models.InferenceTask) error {
 	task := *originTask
-	if task.Status != models.TaskScoreReady {
-		return errWrongTaskStatus
+	if _, err := getTaskTransition(&task, models.TaskStatusEventGroupRefund); err != nil {
+		return err
 	}
 
 	node, err := checkTaskSelectedNode(ctx, db, &task)
@@ -289,8 +330,7 @@ func SetTaskStatusEndGroupRefund(ctx context.Context, db *gorm.DB, originTask *m
 
 	appConfig := config.GetConfig()
 	if err := db.Transaction(func(tx *gorm.DB) error {
-		commitFunc, err := Transfer(ctx, tx, appConfig.Blockchain.Account.Address, task.Creator, &task.TaskFee.Int)
-		if err != nil {
+		if err := Transfer(ctx, tx, appConfig.Blockchain.Account.Address, task.Creator, &task.TaskFee.Int); err != nil {
 			return err
 		}
 		if task.QOSScore.Valid {
@@ -299,14 +339,16 @@ func SetTaskStatusEndGroupRefund(ctx context.Context, db *gorm.DB, originTask *m
 			}
 		}
 
-		err = task.Update(ctx, tx, map[string]interface{}{
-			"status":         models.TaskEndGroupRefund,
-			"validated_time": sql.NullTime{Time: time.Now(), Valid: true},
+		err = transitTaskStatus(ctx, tx, &task, models.TaskStatusEventGroupRefund, task.Creator, "", map[string]interface{}{
+			"validated_time": sql.NullTime{Time: utils.Now(), Valid: true},
 			"qos_score":      task.QOSScore,
 		})
 		if err != nil {
 			return err
 		}
+		if err := finishTaskAttempt(ctx, tx, &task, models.TaskAttemptSucceeded); err != nil {
+			return err
+		}
 		if err := nodeFinishTask(ctx, tx, node); err != nil {
 			return err
 		}
@@ -314,7 +356,6 @@ func SetTaskStatusEndGroupRefund(ctx context.Context, db *gorm.DB, originTask *m
 		if err != nil {
 			return err
 		}
-		commitFunc()
 		return nil
 	}); err != nil {
 		return err
@@ -328,47 +369,44 @@ func SetTaskStatusEndAborted(ctx context.Context, db *gorm.DB, originTask *model
 	if task.Status == models.TaskEndAborted {
 		return nil
 	}
-	if task.Status == models.TaskEndSuccess || task.Status == models.TaskEndInvalidated || task.Status == models.TaskEndGroupSuccess || task.Status == models.TaskEndGroupRefund {
-		return errWrongTaskStatus
+	if _, err := getTaskTransition(&task, models.TaskStatusEventAbort); err != nil {
+		return err
 	}
 	lastStatus := task.Status
 
 	newTask := map[string]interface{}{
-		"status":         models.TaskEndAborted,
 		"abort_reason":   task.AbortReason,
 		"validated_time": task.ValidatedTime,
 		"qos_score":      task.QOSScore,
 	}
 	appConfig := config.GetConfig()
 	if err := db.Transaction(func(tx *gorm.DB) error {
-		commitFunc, err := Transfer(ctx, tx, appConfig.Blockchain.Account.Address, task.Creator, &task.TaskFee.Int)
-		if err != nil {
+		if err := Transfer(ctx, tx, appConfig.Blockchain.Account.Address, task.Creator, &task.TaskFee.Int); err != nil {
 			return err
 		}
 
-		if err := task.Update(ctx, tx, newTask); err != nil {
+		if err := transitTaskStatus(ctx, tx, &task, models.TaskStatusEventAbort, aboutIssuer, task.AbortReason.String(), newTask); err != nil {
 			return err
 		}
 
 		if len(task.SelectedNode) > 0 {
-			node, err := checkTaskSelectedNode(ctx, db, &task)
+			if err := finishTaskAttempt(ctx, tx, &task, models.TaskAttemptAborted); err != nil {
+				return err
+			}
+			node, err := checkTaskSelectedNode(ctx, tx, &task)
 			if errors.Is(err, errWrongNodeCurrentTask) {
-				log.Errorf("TaskEndAborted: node current task is wrong, task: %s, node: %s", task.TaskIDCommitment, task.SelectedNode)
+				// a slashed node has quit before its task is aborted
+				if task.AbortReason != models.TaskAbortNodeSlashed {
+					log.Errorf("TaskEndAborted: node current task is wrong, task: %s, node: %s", task.TaskIDCommitment, task.SelectedNode)
+				}
 			} else if err != nil {
 				return err
-			} else {
-				if task.QOSScore.Valid {
-					if err := updateNodeQosScore(ctx, tx, node, uint64(task.QOSScore.Int64)); err != nil {
-						return err
-					}
-				}
-				if err := nodeFinishTask(ctx, tx, node); err != nil {
-					return err
-				}
+			} else if err := releaseTaskNode(ctx, tx, &task, node); err != nil {
+				return err
 			}
 		}
 
-		err = emitEvent(ctx, tx, &models.TaskEndAbortedEvent{
+		err := emitEvent(ctx, tx, &models.TaskEndAbortedEvent{
 			TaskIDCommitment: task.TaskIDCommitment,
 			AbortIssuer:      aboutIssuer,
 			AbortReason:      task.AbortReason,
@@ -377,7 +415,6 @@ func SetTaskStatusEndAborted(ctx context.Context, db *gorm.DB, originTask *model
 		if err != nil {
 			return err
 		}
-		commitFunc()
 		return nil
 	}); err != nil {
 		return err
@@ -386,8 +423,95 @@ func SetTaskStatusEndAborted(ctx context.Context, db *gorm.DB, originTask *model
 	return nil
 }
 
+// CancelTask aborts a task that has not been dispatched yet and refunds the task fee to the creator.
+// It fails with models.ErrTaskStatusChanged if the task is started concurrently.
+func CancelTask(ctx context.Context, db *gorm.DB, originTask *models.InferenceTask) error {
+	task := *originTask
+	if task.Status != models.TaskQueued && task.Status != models.TaskWaiting {
+		return errWrongTaskStatus
+	}
+	task.AbortReason = models.TaskAbortCancelledByCreator
+	task.ValidatedTime = sql.NullTime{Time: utils.Now(), Valid: true}
+	if err := SetTaskStatusEndAborted(ctx, db, &task, task.Creator); err != nil {
+		return err
+	}
+	*originTask = task
+	return nil
+}
+
+// IncreaseTaskFee adds the amount to the fee of the queued task.
+// The fee is only updated if it is not changed since it is read, and it is read again and retried
+// when another increase happens at the same time, so that no increase is lost.
+func IncreaseTaskFee(ctx context.Context, db *gorm.DB, originTask *models.InferenceTask, amount *big.Int) error {
+	task := *originTask
+	for {
+		if task.Status != models.TaskQueued {
+			return errWrongTaskStatus
+		}
+		err := increaseTaskFee(ctx, db, &task, amount)
+		if err == nil {
+			*originTask = task
+			return nil
+		}
+		if !errors.Is(err, models.ErrTaskStatusChanged) {
+			return err
+		}
+		if err := task.SyncStatus(ctx, db); err != nil {
+			return err
+		}
+		if task.Status != models.TaskQueued {
+			return models.ErrTaskStatusChanged
+		}
+		if err := task.SyncTaskFee(ctx, db); err != nil {
+			return err
+		}
+	}
+}
+
+func increaseTaskFee(ctx context.Context, db *gorm.DB, task *models.InferenceTask, amount *big.Int) error {
+	taskFee := models.BigInt{Int: *new(big.Int).Add(&task.TaskFee.Int, amount)}
+	appConfig := config.GetConfig()
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := Transfer(ctx, tx, task.Creator, appConfig.Blockchain.Account.Address, amount); err != nil {
+			return err
+		}
+		// only update the fee when the task is still queued and its fee is not changed by another increase
+		dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		res := tx.WithContext(dbCtx).Model(&models.InferenceTask{}).
+			Where("id = ?", task.ID).
+			Where("status = ?", models.TaskQueued).
+			Where("task_fee = ?", task.TaskFee.String()).
+			Update("task_fee", taskFee)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return models.ErrTaskStatusChanged
+		}
+		err := emitEvent(ctx, tx, &models.TaskFeeIncreasedEvent{
+			TaskIDCommitment: task.TaskIDCommitment,
+			Creator:          task.Creator,
+			Amount:           models.BigInt{Int: *new(big.Int).Set(amount)},
+			TaskFee:          taskFee,
+		})
+		if err != nil {
+			return err
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	task.TaskFee = taskFee
+	return nil
+}
+
 func SetTaskStatusEndSuccess(ctx context.Context, db *gorm.DB, originTask *models.InferenceTask) error {
 	task := *originTask
+	status, err := getTaskTransition(&task, models.TaskStatusEventUploadResult)
+	if err != nil {
+		return err
+	}
 	node, err := checkTaskSelectedNode(ctx, db, &task)
 	if err != nil {
 		return err
@@ -397,10 +521,9 @@ func SetTaskStatusEndSuccess(ctx context.Context, db *gorm.DB, originTask *model
 	if err != nil {
 		return err
 	}
-	status := models.TaskEndSuccess
 	payments := map[string]*big.Int{}
+	var spotCheck *models.SpotCheck
 	if len(tasks) > 1 {
-		status = models.TaskEndGroupSuccess
 		// calculate each task's payment
 		var totalScore uint64 = 0
 		var validTasks []models.InferenceTask
@@ -423,34 +546,51 @@ func SetTaskStatusEndSuccess(ctx context.Context, db *gorm.DB, originTask *model
 		}
 
 	} else {
-		payments[task.SelectedNode] = &task.TaskFee.Int
+		// the payment is held until the spot check resolves when the node is spot checked
+		needed, rate, err := needSpotCheck(ctx, db, &task, node)
+		if err != nil {
+			return err
+		}
+		if needed {
+			spotCheck = newSpotCheck(&task, rate, &task.TaskFee.Int)
+		} else {
+			payments[task.SelectedNode] = &task.TaskFee.Int
+		}
 	}
 
 	appConfig := config.GetConfig()
 	if err := db.Transaction(func(tx *gorm.DB) error {
-		var commitFuncs []func()
 		for address, payment := range payments {
-			commitFunc, err := Transfer(ctx, tx, appConfig.Blockchain.Account.Address, address, payment)
-			if err != nil {
+			if err := Transfer(ctx, tx, appConfig.Blockchain.Account.Address, address, payment); err != nil {
 				return err
 			}
-			commitFuncs = append(commitFuncs, commitFunc)
 		}
 
-		for address, payment := range payments {
-			incentive, _ := utils.WeiToEther(payment).Float64()
-			if err := addNodeIncentive(ctx, tx, address, incentive, task.TaskType); err != nil {
+		if spotCheck != nil {
+			if err := spotCheck.Create(ctx, tx); err != nil {
 				return err
 			}
 		}
 
-		err = task.Update(ctx, tx, map[string]interface{}{
-			"status":               status,
-			"result_uploaded_time": sql.NullTime{Time: time.Now(), Valid: true},
+		// canary tasks are paid by the relay, they are not counted as node incentives
+		if !task.Canary {
+			for address, payment := range payments {
+				incentive, _ := utils.WeiToEther(payment).Float64()
+				if err := addNodeIncentive(ctx, tx, address, incentive, task.TaskType); err != nil {
+					return err
+				}
+			}
+		}
+
+		err = transitTaskStatus(ctx, tx, &task, models.TaskStatusEventUploadResult, task.SelectedNode, "", map[string]interface{}{
+			"result_uploaded_time": sql.NullTime{Time: utils.Now(), Valid: true},
 		})
 		if err != nil {
 			return err
 		}
+		if err := finishTaskAttempt(ctx, tx, &task, models.TaskAttemptSucceeded); err != nil {
+			return err
+		}
 
 		if err := nodeFinishTask(ctx, tx, node); err != nil {
 			return err
@@ -464,9 +604,6 @@ func SetTaskStatusEndSuccess(ctx context.Context, db *gorm.DB, originTask *model
 		if err != nil {
 			return err
 		}
-		for _, commitFunc := range commitFuncs {
-			commitFunc()
-		}
 		return nil
 	}); err != nil {
 		return err
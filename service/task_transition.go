@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"crynux_relay/models"
+
+	"gorm.io/gorm"
+)
+
+type taskTransition struct {
+	From  models.TaskStatus
+	Event models.TaskStatusEvent
+	To    models.TaskStatus
+}
+
+// taskTransitions is the table of all legal task status transitions, every status change of a task goes through it.
+// The side effects of an event, like payments, qos scores and node status changes, are done by the
+// SetTaskStatus function of the event in the same transaction as the transition:
+//
+//	release: SetTaskStatusQueued, start: SetTaskStatusStarted, requeue: SetTaskStatusRequeued,
+//	report_score: SetTaskStatusScoreReady, report_error: SetTaskStatusErrorReported,
+//	validate: SetTaskStatusValidated, group_validate: SetTaskStatusGroupValidated,
+//	invalidate: SetTaskStatusEndInvalidated, group_refund: SetTaskStatusEndGroupRefund,
+//	upload_result: SetTaskStatusEndSuccess, abort: SetTaskStatusEndAborted
+var taskTransitions = []taskTransition{
+	{models.TaskWaiting, models.TaskStatusEventRelease, models.TaskQueued},
+	{models.TaskQueued, models.TaskStatusEventStart, models.TaskStarted},
+	{models.TaskStarted, models.TaskStatusEventRequeue, models.TaskQueued},
+	{models.TaskParametersUploaded, models.TaskStatusEventRequeue, models.TaskQueued},
+	{models.TaskStarted, models.TaskStatusEventReportScore, models.TaskScoreReady},
+	{models.TaskStarted, models.TaskStatusEventReportError, models.TaskErrorReported},
+	{models.TaskScoreReady, models.TaskStatusEventValidate, models.TaskValidated},
+	{models.TaskScoreReady, models.TaskStatusEventGroupValidate, models.TaskGroupValidated},
+	{models.TaskScoreReady, models.TaskStatusEventInvalidate, models.TaskEndInvalidated},
+	{models.TaskErrorReported, models.TaskStatusEventInvalidate, models.TaskEndInvalidated},
+	{models.TaskEndAborted, models.TaskStatusEventInvalidate, models.TaskEndInvalidated},
+	{models.TaskScoreReady, models.TaskStatusEventGroupRefund, models.TaskEndGroupRefund},
+	{models.TaskValidated, models.TaskStatusEventUploadResult, models.TaskEndSuccess},
+	{models.TaskGroupValidated, models.TaskStatusEventUploadResult, models.TaskEndGroupSuccess},
+	{models.TaskWaiting, models.TaskStatusEventAbort, models.TaskEndAborted},
+	{models.TaskQueued, models.TaskStatusEventAbort, models.TaskEndAborted},
+	{models.TaskStarted, models.TaskStatusEventAbort, models.TaskEndAborted},
+	{models.TaskParametersUploaded, models.TaskStatusEventAbort, models.TaskEndAborted},
+	{models.TaskErrorReported, models.TaskStatusEventAbort, models.TaskEndAborted},
+	{models.TaskScoreReady, models.TaskStatusEventAbort, models.TaskEndAborted},
+	{models.TaskValidated, models.TaskStatusEventAbort, models.TaskEndAborted},
+	{models.TaskGroupValidated, models.TaskStatusEventAbort, models.TaskEndAborted},
+}
+
+var taskTransitionTable = func() map[models.TaskStatus]map[models.TaskStatusEvent]models.TaskStatus {
+	res := make(map[models.TaskStatus]map[models.TaskStatusEvent]models.TaskStatus)
+	for _, transition := range taskTransitions {
+		if _, ok := res[transition.From]; !ok {
+			res[transition.From] = make(map[models.TaskStatusEvent]models.TaskStatus)
+		}
+		res[transition.From][transition.Event] = transition.To
+	}
+	return res
+}()
+
+// getTaskTransition returns the status the task moves to by the event
+func getTaskTransition(task *models.InferenceTask, event models.TaskStatusEvent) (models.TaskStatus, error) {
+	to, ok := taskTransitionTable[task.Status][event]
+	if !ok {
+		return 0, errWrongTaskStatus
+	}
+	return to, nil
+}
+
+// transitTaskStatus moves the task by the event, updates the task with the values in the same statement and logs the transition.
+// It fails with models.ErrTaskStatusChanged if the task status is changed by others.
+func transitTaskStatus(ctx context.Context, db *gorm.DB, task *models.InferenceTask, event models.TaskStatusEvent, actor, reason string, values map[string]interface{}) error {
+	from := task.Status
+	to, err := getTaskTransition(task, event)
+	if err != nil {
+		return err
+	}
+	values["status"] = to
+	if err := task.Update(ctx, db, values); err != nil {
+		return err
+	}
+	transition := &models.TaskStatusTransition{
+		TaskIDCommitment: task.TaskIDCommitment,
+		Event:            event,
+		FromStatus:       from,
+		ToStatus:         to,
+		Actor:            actor,
+		Reason:           reason,
+	}
+	return transition.Create(ctx, db)
+}
+
+func logTaskCreated(ctx context.Context, db *gorm.DB, task *models.InferenceTask) error {
+	transition := &models.TaskStatusTransition{
+		TaskIDCommitment: task.TaskIDCommitment,
+		Event:            models.TaskStatusEventCreate,
+		FromStatus:       task.Status,
+		ToStatus:         task.Status,
+		Actor:            task.Creator,
+	}
+	return transition.Create(ctx, db)
+}
@@ -7,8 +7,8 @@ import (
 	"crynux_relay/utils"
 	"database/sql"
 	"errors"
+	"fmt"
 	"sort"
-	"time"
 
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -69,7 +69,7 @@ func validateVRFProof(samplingSeed, vrfProof, publicKey string, creator string,
 	if err != nil {
 		return err
 	}
-	needValidation := utils.VrfNeedValidation(beta)
+	needValidation := utils.VrfNeedValidation(beta, config.GetConfig().Validation.SamplingModulus)
 	if grouped && !needValidation {
 		return errors.New("task is not selected for validation")
 	}
@@ -96,56 +96,115 @@ func ValidateSingleTask(ctx context.Context, originTask *models.InferenceTask, t
 		return err
 	}
 
-	var err error
-	if task.Status == models.TaskScoreReady {
-		err = SetTaskStatusValidated(ctx, config.GetDB(), &task)
-	} else {
-		task.AbortReason = models.TaskAbortIncorrectResult
-		task.ValidatedTime = sql.NullTime{Time: time.Now(), Valid: true}
-		err = SetTaskStatusEndAborted(ctx, config.GetDB(), &task, task.Creator)
-	}
-	if err != nil {
+	record := newValidationRecord(&task, taskID, false)
+	if err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+		if task.Status == models.TaskScoreReady {
+			if err := SetTaskStatusValidated(ctx, tx, &task); err != nil {
+				return err
+			}
+		} else {
+			task.AbortReason = models.TaskAbortIncorrectResult
+			task.ValidatedTime = sql.NullTime{Time: utils.Now(), Valid: true}
+			if err := SetTaskStatusEndAborted(ctx, tx, &task, task.Creator); err != nil {
+				return err
+			}
+		}
+		record.Verdict = task.Status
+		return record.Create(ctx, tx)
+	}); err != nil {
 		return err
 	}
 	*originTask = task
 	return nil
 }
 
-func checkHammingDistance(h1, h2 []byte, threshold uint64) bool {
-	if len(h1) != len(h2) || len(h1)%8 != 0 {
-		return false
+// compareTaskScore compares the score of task2 against task1 by the validator of task1
+func compareTaskScore(task1, task2 *models.InferenceTask) models.ValidationComparison {
+	if task1.TaskType != task2.TaskType {
+		return models.ValidationComparison{TaskIDCommitment: task2.TaskIDCommitment}
 	}
-
-	for i := 0; i < len(h1); i += 8 {
-		distance := utils.HammingDistance(h1[i:i+8], h2[i:i+8])
-		if uint64(distance) >= threshold {
-			return false
-		}
+	if task1.Status != task2.Status {
+		return models.ValidationComparison{TaskIDCommitment: task2.TaskIDCommitment}
+	}
+	if task1.Status == models.TaskScoreReady {
+		return compareScore(task1, task2)
+	} else {
+		return models.ValidationComparison{TaskIDCommitment: task2.TaskIDCommitment, Same: true}
 	}
-
-	return true
 }
 
-func compareTaskScore(task1, task2 *models.InferenceTask, threshold uint64) bool {
-	if task1.TaskType != task2.TaskType {
-		return false
+// compareScore compares the score of task2 against task1 by the validator of task1, regardless of their status
+func compareScore(task1, task2 *models.InferenceTask) models.ValidationComparison {
+	res := models.ValidationComparison{TaskIDCommitment: task2.TaskIDCommitment}
+	v, threshold, err := models.GetTaskValidator(task1)
+	if err != nil {
+		return res
 	}
-	if task1.Status != task2.Status {
-		return false
+	score1, err := hexutil.Decode(task1.Score)
+	if err != nil {
+		return res
 	}
-	if task1.Status == models.TaskScoreReady {
-		if task1.TaskType == models.TaskTypeSD || task1.TaskType == models.TaskTypeSDFTLora {
-			h1 := hexutil.MustDecode(task1.Score)
-			h2 := hexutil.MustDecode(task2.Score)
-			return checkHammingDistance(h1, h2, threshold)
-		} else if task1.TaskType == models.TaskTypeLLM {
-			return task1.Score == task2.Score
-		} else {
-			return false
+	score2, err := hexutil.Decode(task2.Score)
+	if err != nil {
+		return res
+	}
+	if distances, err := v.Distances(score1, score2); err == nil {
+		res.Distances = distances
+	}
+	res.Same = v.Compare(score1, score2, threshold)
+	return res
+}
+
+func newValidationRecord(task *models.InferenceTask, taskID string, grouped bool) *models.ValidationRecord {
+	record := &models.ValidationRecord{
+		TaskID:           taskID,
+		TaskIDCommitment: task.TaskIDCommitment,
+		SelectedNode:     task.SelectedNode,
+		Grouped:          grouped,
+		Status:           task.Status,
+		Score:            task.Score,
+		Validator:        task.Validator,
+		Threshold:        task.ValidatorThreshold,
+	}
+	if task.StartTime.Valid && task.ScoreReadyTime.Valid {
+		record.ExecutionTime = uint64(task.ExecutionTime().Milliseconds())
+	}
+	return record
+}
+
+// GroupTasksByScore finds the first task, in the order of finished tasks, whose result is agreed by at least quorum tasks.
+// The task is validated and the agreeing tasks are refunded, and the other tasks are invalidated.
+// If the agreed result is an error, the agreeing tasks are aborted.
+// Tasks not in the returned map are aborted.
+// comparisons[i][j] is the comparison of finishedTasks[j] against finishedTasks[i].
+func GroupTasksByScore(finishedTasks []*models.InferenceTask, comparisons [][]models.ValidationComparison, quorum uint64) map[string]models.TaskStatus {
+	res := make(map[string]models.TaskStatus)
+	for i, leader := range finishedTasks {
+		var members []*models.InferenceTask
+		for j, task := range finishedTasks {
+			if i == j || comparisons[i][j].Same {
+				members = append(members, task)
+			}
 		}
-	} else {
-		return true
+		if uint64(len(members)) < quorum {
+			continue
+		}
+
+		for _, task := range finishedTasks {
+			res[task.TaskIDCommitment] = models.TaskEndInvalidated
+		}
+		for _, task := range members {
+			delete(res, task.TaskIDCommitment)
+		}
+		if leader.Status == models.TaskScoreReady {
+			for _, task := range members {
+				res[task.TaskIDCommitment] = models.TaskEndGroupRefund
+			}
+			res[leader.TaskIDCommitment] = models.TaskGroupValidated
+		}
+		return res
 	}
+	return res
 }
 
 func ValidateTaskGroup(ctx context.Context, originTasks []*models.InferenceTask, taskID, vrfProof, publicKey string) error {
@@ -155,8 +214,9 @@ func ValidateTaskGroup(ctx context.Context, originTasks []*models.InferenceTask,
 		tasks[i] = &newTask
 	}
 
-	if len(tasks) != 3 {
-		return errors.New("task group size is not 3")
+	groupSize := config.GetConfig().Validation.GroupSize
+	if uint64(len(tasks)) != groupSize {
+		return fmt.Errorf("task group size is not %d", groupSize)
 	}
 
 	for _, task := range tasks {
@@ -215,8 +275,6 @@ func ValidateTaskGroup(ctx context.Context, originTasks []*models.InferenceTask,
 	}
 
 	// validate tasks' score
-	appConfig := config.GetConfig()
-
 	nextStatusMap := make(map[string]models.TaskStatus)
 	finishedTasks := make([]*models.InferenceTask, 0)
 	for _, task := range tasks {
@@ -226,43 +284,41 @@ func ValidateTaskGroup(ctx context.Context, originTasks []*models.InferenceTask,
 		}
 	}
 
-	if len(finishedTasks) == 2 {
-		if compareTaskScore(finishedTasks[0], finishedTasks[1], appConfig.Task.DistanceThreshold) && finishedTasks[0].Status == models.TaskScoreReady {
-			nextStatusMap[finishedTasks[0].TaskIDCommitment] = models.TaskGroupValidated
-			nextStatusMap[finishedTasks[1].TaskIDCommitment] = models.TaskEndGroupRefund
-		}
-	} else if len(finishedTasks) == 3 {
-		same1 := compareTaskScore(finishedTasks[0], finishedTasks[1], appConfig.Task.DistanceThreshold)
-		same2 := compareTaskScore(finishedTasks[0], finishedTasks[2], appConfig.Task.DistanceThreshold)
-		same3 := compareTaskScore(finishedTasks[1], finishedTasks[2], appConfig.Task.DistanceThreshold)
-		if same1 {
-			if finishedTasks[0].Status == models.TaskScoreReady {
-				nextStatusMap[finishedTasks[0].TaskIDCommitment] = models.TaskGroupValidated
-				nextStatusMap[finishedTasks[1].TaskIDCommitment] = models.TaskEndGroupRefund
+	comparisons := make([][]models.ValidationComparison, len(finishedTasks))
+	for i, task1 := range finishedTasks {
+		comparisons[i] = make([]models.ValidationComparison, len(finishedTasks))
+		for j, task2 := range finishedTasks {
+			if i != j {
+				comparisons[i][j] = compareTaskScore(task1, task2)
 			}
-			if same2 {
-				nextStatusMap[finishedTasks[2].TaskIDCommitment] = models.TaskEndGroupRefund
-			} else {
-				nextStatusMap[finishedTasks[2].TaskIDCommitment] = models.TaskEndInvalidated
-			}
-		} else if same2 {
-			if finishedTasks[0].Status == models.TaskScoreReady {
-				nextStatusMap[finishedTasks[0].TaskIDCommitment] = models.TaskGroupValidated
-				nextStatusMap[finishedTasks[2].TaskIDCommitment] = models.TaskEndGroupRefund
-			}
-			nextStatusMap[finishedTasks[1].TaskIDCommitment] = models.TaskEndInvalidated
-		} else if same3 {
-			if finishedTasks[1].Status == models.TaskScoreReady {
-				nextStatusMap[finishedTasks[1].TaskIDCommitment] = models.TaskGroupValidated
-				nextStatusMap[finishedTasks[2].TaskIDCommitment] = models.TaskEndGroupRefund
+		}
+	}
+
+	// keep the evidence of the validation before the task status changes
+	records := make(map[string]*models.ValidationRecord)
+	for i, task := range tasks {
+		record := newValidationRecord(task, taskID, true)
+		record.ExecutionOrder = uint64(i)
+		records[task.TaskIDCommitment] = record
+	}
+	for i, task := range finishedTasks {
+		record := records[task.TaskIDCommitment]
+		for j := range finishedTasks {
+			if i != j {
+				record.Comparisons = append(record.Comparisons, comparisons[i][j])
 			}
-			nextStatusMap[finishedTasks[0].TaskIDCommitment] = models.TaskEndInvalidated
 		}
-	} else if len(finishedTasks) == 0 {
+	}
+
+	if len(finishedTasks) == 0 {
 		// all tasks are aborted, set all qos score to null
 		for _, task := range tasks {
 			task.QOSScore = sql.NullInt64{Int64: 0, Valid: false}
 		}
+	} else {
+		for taskIDCommitment, nextStatus := range GroupTasksByScore(finishedTasks, comparisons, config.GetConfig().Validation.Quorum) {
+			nextStatusMap[taskIDCommitment] = nextStatus
+		}
 	}
 
 	if err := config.GetDB().Transaction(func(tx *gorm.DB) error {
@@ -285,7 +341,7 @@ func ValidateTaskGroup(ctx context.Context, originTasks []*models.InferenceTask,
 				if task.Status != models.TaskEndAborted {
 					task.AbortReason = models.TaskAbortIncorrectResult
 
-					task.ValidatedTime = sql.NullTime{Time: time.Now(), Valid: true}
+					task.ValidatedTime = sql.NullTime{Time: utils.Now(), Valid: true}
 					if err := SetTaskStatusEndAborted(ctx, tx, task, task.Creator); err != nil {
 						return err
 					}
@@ -297,6 +353,13 @@ func ValidateTaskGroup(ctx context.Context, originTasks []*models.InferenceTask,
 					}
 				}
 			}
+
+			record := records[task.TaskIDCommitment]
+			record.QOSScore = task.QOSScore
+			record.Verdict = task.Status
+			if err := record.Create(ctx, tx); err != nil {
+				return err
+			}
 		}
 		return nil
 	}); err != nil {
@@ -0,0 +1,98 @@
+package service_test
+
+import (
+	"crynux_relay/models"
+	"crynux_relay/service"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newFinishedTasks makes finished tasks in the order of the results, tasks with the same result agree with each other.
+// Results starting with "error" are reported errors.
+func newFinishedTasks(results []string) ([]*models.InferenceTask, [][]models.ValidationComparison) {
+	tasks := make([]*models.InferenceTask, len(results))
+	for i, result := range results {
+		status := models.TaskScoreReady
+		if strings.HasPrefix(result, "error") {
+			status = models.TaskErrorReported
+		}
+		tasks[i] = &models.InferenceTask{TaskIDCommitment: string(rune('a' + i)), Status: status}
+	}
+	comparisons := make([][]models.ValidationComparison, len(results))
+	for i := range results {
+		comparisons[i] = make([]models.ValidationComparison, len(results))
+		for j := range results {
+			comparisons[i][j] = models.ValidationComparison{TaskIDCommitment: tasks[j].TaskIDCommitment, Same: results[i] == results[j]}
+		}
+	}
+	return tasks, comparisons
+}
+
+func TestGroupTasksByScore(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		results []string
+		quorum  uint64
+		want    map[string]models.TaskStatus
+	}{
+		{
+			name:    "default group",
+			results: []string{"x", "y", "x"},
+			quorum:  2,
+			want: map[string]models.TaskStatus{
+				"a": models.TaskGroupValidated,
+				"b": models.TaskEndInvalidated,
+				"c": models.TaskEndGroupRefund,
+			},
+		},
+		{
+			name:    "later leader of group 5",
+			results: []string{"x", "x", "y", "y", "y"},
+			quorum:  3,
+			want: map[string]models.TaskStatus{
+				"a": models.TaskEndInvalidated,
+				"b": models.TaskEndInvalidated,
+				"c": models.TaskGroupValidated,
+				"d": models.TaskEndGroupRefund,
+				"e": models.TaskEndGroupRefund,
+			},
+		},
+		{
+			name:    "no quorum in group 5",
+			results: []string{"x", "x", "y", "y", "z"},
+			quorum:  3,
+			want:    map[string]models.TaskStatus{},
+		},
+		{
+			name:    "quorum 3 of group 4",
+			results: []string{"x", "x", "y", "x"},
+			quorum:  3,
+			want: map[string]models.TaskStatus{
+				"a": models.TaskGroupValidated,
+				"b": models.TaskEndGroupRefund,
+				"c": models.TaskEndInvalidated,
+				"d": models.TaskEndGroupRefund,
+			},
+		},
+		{
+			name:    "agreed error of group 4",
+			results: []string{"error", "x", "error", "error"},
+			quorum:  3,
+			want: map[string]models.TaskStatus{
+				"b": models.TaskEndInvalidated,
+			},
+		},
+		{
+			name:    "finished tasks fewer than quorum",
+			results: []string{"x", "x"},
+			quorum:  3,
+			want:    map[string]models.TaskStatus{},
+		},
+	} {
+		tasks, comparisons := newFinishedTasks(c.results)
+		if res := service.GroupTasksByScore(tasks, comparisons, c.quorum); !reflect.DeepEqual(res, c.want) {
+			t.Fatalf("%s: wrong task statuses %v, want %v", c.name, res, c.want)
+		}
+	}
+}
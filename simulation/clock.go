@@ -0,0 +1,99 @@
+package simulation
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+type timer struct {
+	time time.Time
+	seq  uint64
+	ch   chan time.Time
+}
+
+// timerQueue orders timers by time, and timers at the same time by the order they are created
+type timerQueue []*timer
+
+func (q timerQueue) Len() int { return len(q) }
+
+func (q timerQueue) Less(i, j int) bool {
+	if q[i].time.Equal(q[j].time) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].time.Before(q[j].time)
+}
+
+func (q timerQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *timerQueue) Push(x any) { *q = append(*q, x.(*timer)) }
+
+func (q *timerQueue) Pop() any {
+	old := *q
+	t := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return t
+}
+
+// virtualClock is the clock of the task lifecycle in the simulator.
+// It only moves when the simulator advances it, and then fires the timers the dispatcher waits on.
+type virtualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	seq    uint64
+	timers timerQueue
+}
+
+func newVirtualClock(now time.Time) *virtualClock {
+	return &virtualClock{now: now}
+}
+
+func (c *virtualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *virtualClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.seq++
+	heap.Push(&c.timers, &timer{time: c.now.Add(d), seq: c.seq, ch: ch})
+	return ch
+}
+
+// nextTimer returns the time of the earliest timer
+func (c *virtualClock) nextTimer() (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.timers) == 0 {
+		return time.Time{}, false
+	}
+	return c.timers[0].time, true
+}
+
+// timerSeq changes whenever a timer is created, so it tells whether the dispatcher is still working
+func (c *virtualClock) timerSeq() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.seq
+}
+
+// advance moves the clock to the time and fires the timers up to the time
+func (c *virtualClock) advance(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.After(c.now) {
+		c.now = now
+	}
+	for len(c.timers) > 0 && !c.timers[0].time.After(c.now) {
+		t := heap.Pop(&c.timers).(*timer)
+		t.ch <- c.now
+	}
+}
@@ -0,0 +1,132 @@
+package simulation
+
+import (
+	"crynux_relay/models"
+	"errors"
+	"time"
+)
+
+// GPU is a kind of gpu of the simulated nodes
+type GPU struct {
+	Name string
+	Vram uint64
+	// Speed is how fast the gpu runs a task, relative to the base execution time
+	Speed float64
+	// Share is the share of the nodes with the gpu
+	Share float64
+}
+
+type Params struct {
+	// Seed seeds the synthetic nodes and tasks
+	Seed int64
+	// Duration is how long tasks arrive for, the simulation goes on until all the tasks end
+	Duration time.Duration
+
+	Nodes int
+	GPUs  []GPU
+	// the stake of a node is uniform between min stake and max stake, in ether
+	MinStake float64
+	MaxStake float64
+	// NodeModels is how many of the task models are local to a node when it joins
+	NodeModels  int
+	NodeVersion string
+
+	// faulty nodes report errors and time out tasks at the failure rate and the timeout rate
+	FaultyNodeShare float64
+	FailureRate     float64
+	TimeoutRate     float64
+	// cheater nodes report a made up score at the cheat rate
+	CheaterShare float64
+	CheatRate    float64
+
+	Creators int
+	// ArrivalRate is the mean number of tasks arriving per second, as a poisson process
+	ArrivalRate float64
+	TaskType    models.TaskType
+	TaskVersion string
+	// the task fee is uniform in task fee * (1 +- task fee spread), in ether
+	TaskFee       float64
+	TaskFeeSpread float64
+	MinVRAM       uint64
+	// Models is the number of task models, the models of tasks follow a zipf distribution
+	Models      int
+	Timeout     uint64
+	MaxAttempts uint64
+	// ExecutionTime is the mean execution time of a task on a gpu of speed 1.
+	// Loading a model not in use takes model switch time more.
+	ExecutionTime   time.Duration
+	ModelSwitchTime time.Duration
+
+	// ValidateInterval is the time between two rounds of the creators validating the waiting groups
+	ValidateInterval time.Duration
+}
+
+func DefaultParams() Params {
+	return Params{
+		Seed:     1,
+		Duration: time.Hour,
+		Nodes:    50,
+		GPUs: []GPU{
+			{Name: "NVIDIA GeForce RTX 4090", Vram: 24, Speed: 1, Share: 0.2},
+			{Name: "NVIDIA GeForce RTX 3090", Vram: 24, Speed: 0.7, Share: 0.3},
+			{Name: "NVIDIA GeForce RTX 3080", Vram: 10, Speed: 0.5, Share: 0.5},
+		},
+		MinStake:         400,
+		MaxStake:         4000,
+		NodeModels:       2,
+		NodeVersion:      "2.5.0",
+		FaultyNodeShare:  0.1,
+		FailureRate:      0.1,
+		TimeoutRate:      0.2,
+		CheaterShare:     0.05,
+		CheatRate:        0.5,
+		Creators:         5,
+		ArrivalRate:      0.5,
+		TaskType:         models.TaskTypeSD,
+		TaskVersion:      "2.5.0",
+		TaskFee:          6,
+		TaskFeeSpread:    0.5,
+		MinVRAM:          8,
+		Models:           10,
+		Timeout:          180,
+		MaxAttempts:      2,
+		ExecutionTime:    20 * time.Second,
+		ModelSwitchTime:  30 * time.Second,
+		ValidateInterval: time.Second,
+	}
+}
+
+func (p *Params) Check() error {
+	if p.Duration <= 0 || p.ValidateInterval <= 0 {
+		return errors.New("duration and validate interval should be positive")
+	}
+	if p.Nodes <= 0 || p.Creators <= 0 || p.Models <= 0 {
+		return errors.New("nodes, creators and models should be positive")
+	}
+	if len(p.GPUs) == 0 {
+		return errors.New("gpus not set")
+	}
+	for _, gpu := range p.GPUs {
+		if gpu.Speed <= 0 || gpu.Share < 0 {
+			return errors.New("gpu speed should be positive and gpu share should not be negative")
+		}
+	}
+	if p.MinStake <= 0 || p.MinStake > p.MaxStake {
+		return errors.New("stakes should satisfy 0 < min stake <= max stake")
+	}
+	if p.ArrivalRate <= 0 || p.TaskFee <= 0 || p.TaskFeeSpread < 0 || p.TaskFeeSpread >= 1 {
+		return errors.New("arrival rate and task fee should be positive, and task fee spread should be in [0, 1)")
+	}
+	for _, rate := range []float64{p.FaultyNodeShare, p.FailureRate, p.TimeoutRate, p.CheaterShare, p.CheatRate} {
+		if rate < 0 || rate > 1 {
+			return errors.New("shares and rates should be in [0, 1]")
+		}
+	}
+	if p.FailureRate+p.TimeoutRate > 1 {
+		return errors.New("failure rate and timeout rate should add up to at most 1")
+	}
+	if p.Timeout == 0 || p.MaxAttempts == 0 {
+		return errors.New("timeout and max attempts should be positive")
+	}
+	return nil
+}
@@ -0,0 +1,328 @@
+package simulation
+
+import (
+	"context"
+	"crynux_relay/models"
+	"crynux_relay/service"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+var taskStatusNames = map[models.TaskStatus]string{
+	models.TaskQueued:             "queued",
+	models.TaskStarted:            "started",
+	models.TaskParametersUploaded: "parameters_uploaded",
+	models.TaskErrorReported:      "error_reported",
+	models.TaskScoreReady:         "score_ready",
+	models.TaskValidated:          "validated",
+	models.TaskGroupValidated:     "group_validated",
+	models.TaskEndInvalidated:     "end_invalidated",
+	models.TaskEndSuccess:         "end_success",
+	models.TaskEndAborted:         "end_aborted",
+	models.TaskEndGroupRefund:     "end_group_refund",
+	models.TaskEndGroupSuccess:    "end_group_success",
+	models.TaskWaiting:            "waiting",
+}
+
+var nodeStatusNames = map[models.NodeStatus]string{
+	models.NodeStatusQuit:         "quit",
+	models.NodeStatusAvailable:    "available",
+	models.NodeStatusBusy:         "busy",
+	models.NodeStatusPendingPause: "pending_pause",
+	models.NodeStatusPendingQuit:  "pending_quit",
+	models.NodeStatusPaused:       "paused",
+}
+
+// Tunables are the parameters of the service package tuned by the simulation
+type Tunables struct {
+	TaskScorePoolSize    uint64  `json:"task_score_pool_size"`
+	NodeQosScorePoolSize uint64  `json:"node_qos_score_pool_size"`
+	KickoutThreshold     uint64  `json:"kickout_threshold"`
+	MaxTaskQosScore      uint64  `json:"max_task_qos_score"`
+	StakingScoreWeight   float64 `json:"staking_score_weight"`
+	QosScoreWeight       float64 `json:"qos_score_weight"`
+	LocalityBonus        float64 `json:"locality_bonus"`
+}
+
+func getTunables() Tunables {
+	return Tunables{
+		TaskScorePoolSize:    service.TASK_SCORE_POOL_SIZE,
+		NodeQosScorePoolSize: service.NODE_QOS_SCORE_POOL_SIZE,
+		KickoutThreshold:     service.KickoutThreshold,
+		MaxTaskQosScore:      service.MAX_TASK_QOS_SCORE,
+		StakingScoreWeight:   service.StakingScoreWeight,
+		QosScoreWeight:       service.QosScoreWeight,
+		LocalityBonus:        service.LocalityBonus,
+	}
+}
+
+type NodeResult struct {
+	Address     string  `json:"address"`
+	GPUName     string  `json:"gpu_name"`
+	GPUVram     uint64  `json:"gpu_vram"`
+	Stake       float64 `json:"stake"`
+	FailureRate float64 `json:"failure_rate"`
+	TimeoutRate float64 `json:"timeout_rate"`
+	CheatRate   float64 `json:"cheat_rate"`
+	// Tasks is the number of task attempts started on the node
+	Tasks    int `json:"tasks"`
+	Errors   int `json:"errors"`
+	Timeouts int `json:"timeouts"`
+	Cheats   int `json:"cheats"`
+	// Earnings is the task fees paid to the node, in ether
+	Earnings  float64 `json:"earnings"`
+	QOSScore  float64 `json:"qos_score"`
+	Status    string  `json:"status"`
+	KickedOut int     `json:"kicked_out"`
+	Slashed   int     `json:"slashed"`
+}
+
+type QueueWait struct {
+	TaskIDCommitment string  `json:"task_id_commitment"`
+	Attempt          uint64  `json:"attempt"`
+	Node             string  `json:"node"`
+	Seconds          float64 `json:"seconds"`
+}
+
+type QueueWaitBucket struct {
+	LE    string `json:"le"`
+	Count int    `json:"count"`
+}
+
+// QueueWaitDistribution is the distribution of the seconds task attempts wait in the queue before they start
+type QueueWaitDistribution struct {
+	Count int     `json:"count"`
+	Mean  float64 `json:"mean"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+	// Buckets are cumulative, each counts the waits less than or equal to its bound
+	Buckets []QueueWaitBucket `json:"buckets"`
+}
+
+var queueWaitBounds = []float64{1, 5, 10, 30, 60, 120, 300, 600}
+
+func newQueueWaitDistribution(waits []QueueWait) QueueWaitDistribution {
+	seconds := make([]float64, len(waits))
+	sum := 0.0
+	for i, wait := range waits {
+		seconds[i] = wait.Seconds
+		sum += wait.Seconds
+	}
+	sort.Float64s(seconds)
+
+	res := QueueWaitDistribution{Count: len(seconds)}
+	for _, bound := range queueWaitBounds {
+		res.Buckets = append(res.Buckets, QueueWaitBucket{
+			LE:    strconv.FormatFloat(bound, 'f', -1, 64) + "s",
+			Count: sort.SearchFloat64s(seconds, math.Nextafter(bound, math.Inf(1))),
+		})
+	}
+	res.Buckets = append(res.Buckets, QueueWaitBucket{LE: "+Inf", Count: len(seconds)})
+	if len(seconds) == 0 {
+		return res
+	}
+	percentile := func(p float64) float64 {
+		return seconds[int(math.Ceil(p*float64(len(seconds))))-1]
+	}
+	res.Mean = sum / float64(len(seconds))
+	res.P50 = percentile(0.5)
+	res.P90 = percentile(0.9)
+	res.P99 = percentile(0.99)
+	res.Max = seconds[len(seconds)-1]
+	return res
+}
+
+type ValidationResult struct {
+	// SingleVerdicts and GroupVerdicts count the validation records by their verdict
+	SingleVerdicts map[string]int `json:"single_verdicts"`
+	GroupVerdicts  map[string]int `json:"group_verdicts"`
+	// CheatedTasks counts the tasks ending with a made up score by their final status
+	CheatedTasks map[string]int `json:"cheated_tasks"`
+}
+
+type Result struct {
+	Params         Params                `json:"params"`
+	Tunables       Tunables              `json:"tunables"`
+	VirtualSeconds float64               `json:"virtual_seconds"`
+	CreatedTasks   int                   `json:"created_tasks"`
+	TaskStatuses   map[string]int        `json:"task_statuses"`
+	QueueWait      QueueWaitDistribution `json:"queue_wait"`
+	Validation     ValidationResult      `json:"validation"`
+	Nodes          []NodeResult          `json:"nodes"`
+	QueueWaits     []QueueWait           `json:"-"`
+}
+
+func (s *Simulator) result(ctx context.Context) (*Result, error) {
+	res := &Result{
+		Params:         s.params,
+		Tunables:       getTunables(),
+		VirtualSeconds: s.now.Sub(s.end.Add(-s.params.Duration)).Seconds(),
+		CreatedTasks:   s.createdTasks,
+		TaskStatuses:   make(map[string]int),
+		QueueWait:      newQueueWaitDistribution(s.queueWaits),
+		Validation: ValidationResult{
+			SingleVerdicts: make(map[string]int),
+			GroupVerdicts:  make(map[string]int),
+			CheatedTasks:   make(map[string]int),
+		},
+		QueueWaits: s.queueWaits,
+	}
+	db := s.db.WithContext(ctx)
+
+	var statusCounts []struct {
+		Status models.TaskStatus
+		Count  int
+	}
+	if err := db.Model(&models.InferenceTask{}).Select("status, COUNT(*) AS count").Group("status").Scan(&statusCounts).Error; err != nil {
+		return nil, err
+	}
+	for _, c := range statusCounts {
+		res.TaskStatuses[taskStatusNames[c.Status]] = c.Count
+	}
+
+	var verdictCounts []struct {
+		Grouped bool
+		Verdict models.TaskStatus
+		Count   int
+	}
+	if err := db.Model(&models.ValidationRecord{}).Select("grouped, verdict, COUNT(*) AS count").Group("grouped, verdict").Scan(&verdictCounts).Error; err != nil {
+		return nil, err
+	}
+	for _, c := range verdictCounts {
+		if c.Grouped {
+			res.Validation.GroupVerdicts[taskStatusNames[c.Verdict]] += c.Count
+		} else {
+			res.Validation.SingleVerdicts[taskStatusNames[c.Verdict]] += c.Count
+		}
+	}
+
+	var cheated []string
+	for taskIDCommitment, st := range s.tasks {
+		if st.cheated {
+			cheated = append(cheated, taskIDCommitment)
+		}
+	}
+	if len(cheated) > 0 {
+		tasks, err := models.GetTasksByIDCommitments(ctx, s.db, cheated)
+		if err != nil {
+			return nil, err
+		}
+		for _, task := range tasks {
+			res.Validation.CheatedTasks[taskStatusNames[task.Status]]++
+		}
+	}
+
+	var earnings []struct {
+		NodeAddress string
+		Incentive   float64
+	}
+	if err := db.Model(&models.NodeIncentive{}).Select("node_address, SUM(incentive) AS incentive").Group("node_address").Scan(&earnings).Error; err != nil {
+		return nil, err
+	}
+	nodeEarnings := make(map[string]float64)
+	for _, e := range earnings {
+		nodeEarnings[e.NodeAddress] = e.Incentive
+	}
+
+	var eventCounts []struct {
+		NodeAddress string
+		Type        string
+		Count       int
+	}
+	if err := db.Model(&models.Event{}).Select("node_address, type, COUNT(*) AS count").
+		Where("type IN ?", []string{"NodeKickedOut", "NodeSlashed"}).
+		Group("node_address, type").Scan(&eventCounts).Error; err != nil {
+		return nil, err
+	}
+	kickouts := make(map[string]int)
+	slashes := make(map[string]int)
+	for _, c := range eventCounts {
+		if c.Type == "NodeKickedOut" {
+			kickouts[c.NodeAddress] = c.Count
+		} else {
+			slashes[c.NodeAddress] = c.Count
+		}
+	}
+
+	for _, address := range s.nodeAddresses {
+		node := s.nodes[address]
+		dbNode, err := models.GetNodeByAddress(ctx, s.db, address)
+		if err != nil {
+			return nil, err
+		}
+		res.Nodes = append(res.Nodes, NodeResult{
+			Address:     address,
+			GPUName:     node.gpu.Name,
+			GPUVram:     node.gpu.Vram,
+			Stake:       node.stake,
+			FailureRate: node.failureRate,
+			TimeoutRate: node.timeoutRate,
+			CheatRate:   node.cheatRate,
+			Tasks:       node.tasks,
+			Errors:      node.errors,
+			Timeouts:    node.timeouts,
+			Cheats:      node.cheats,
+			Earnings:    nodeEarnings[address],
+			QOSScore:    dbNode.QOSScore,
+			Status:      nodeStatusNames[dbNode.Status],
+			KickedOut:   kickouts[address],
+			Slashed:     slashes[address],
+		})
+	}
+	return res, nil
+}
+
+func (r *Result) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func (r *Result) WriteNodesCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{
+		"address", "gpu_name", "gpu_vram", "stake", "failure_rate", "timeout_rate", "cheat_rate",
+		"tasks", "errors", "timeouts", "cheats", "earnings", "qos_score", "status", "kicked_out", "slashed",
+	}); err != nil {
+		return err
+	}
+	for _, node := range r.Nodes {
+		if err := writer.Write([]string{
+			node.Address, node.GPUName, strconv.FormatUint(node.GPUVram, 10), formatFloat(node.Stake),
+			formatFloat(node.FailureRate), formatFloat(node.TimeoutRate), formatFloat(node.CheatRate),
+			strconv.Itoa(node.Tasks), strconv.Itoa(node.Errors), strconv.Itoa(node.Timeouts), strconv.Itoa(node.Cheats),
+			formatFloat(node.Earnings), formatFloat(node.QOSScore), node.Status,
+			strconv.Itoa(node.KickedOut), strconv.Itoa(node.Slashed),
+		}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func (r *Result) WriteQueueWaitsCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"task_id_commitment", "attempt", "node", "seconds"}); err != nil {
+		return err
+	}
+	for _, wait := range r.QueueWaits {
+		if err := writer.Write([]string{
+			wait.TaskIDCommitment, strconv.FormatUint(wait.Attempt, 10), wait.Node, formatFloat(wait.Seconds),
+		}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,730 @@
+package simulation
+
+import (
+	"container/heap"
+	"context"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"crynux_relay/service"
+	"crynux_relay/utils"
+	"crypto/ecdsa"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/vechain/go-ecvrf"
+	"gorm.io/gorm"
+)
+
+type eventKind uint8
+
+const (
+	eventArrival eventKind = iota
+	eventValidate
+	eventReport
+	eventTimeout
+)
+
+type taskOutcome uint8
+
+const (
+	outcomeScore taskOutcome = iota
+	outcomeError
+	outcomeCheat
+)
+
+type event struct {
+	time    time.Time
+	seq     uint64
+	kind    eventKind
+	task    *simTask
+	attempt uint64
+	outcome taskOutcome
+}
+
+// eventQueue orders events by time, and events at the same time by the order they are scheduled
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].time.Equal(q[j].time) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].time.Before(q[j].time)
+}
+
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *eventQueue) Push(x any) { *q = append(*q, x.(*event)) }
+
+func (q *eventQueue) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+type simNode struct {
+	address     string
+	gpu         GPU
+	stake       float64
+	failureRate float64
+	timeoutRate float64
+	cheatRate   float64
+
+	tasks    int
+	errors   int
+	timeouts int
+	cheats   int
+}
+
+type simCreator struct {
+	key       *ecdsa.PrivateKey
+	address   string
+	publicKey string
+}
+
+// simTask is a task of a creator. The tasks of a validation group share the task id, the vrf proof and the group.
+type simTask struct {
+	taskIDCommitment string
+	creator          *simCreator
+	taskID           string
+	vrfProof         string
+	group            []string
+	// cheated is whether the last reported score of the task is made up
+	cheated bool
+}
+
+// recordingPolicy records the tasks started by the dispatcher, so that the simulator runs them on their nodes
+type recordingPolicy struct {
+	service.QueuePolicy
+
+	mu      sync.Mutex
+	started []*models.InferenceTask
+}
+
+func (p *recordingPolicy) TaskStarted(task *models.InferenceTask) {
+	p.QueuePolicy.TaskStarted(task)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	startedTask := *task
+	p.started = append(p.started, &startedTask)
+}
+
+func (p *recordingPolicy) takeStarted() []*models.InferenceTask {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	res := p.started
+	p.started = nil
+	return res
+}
+
+// the dispatcher is regarded as waiting for the clock, when no query is running,
+// and it creates no timer and makes no query in the settle rounds
+const (
+	settleRounds   = 2
+	settleInterval = 200 * time.Microsecond
+)
+
+type dispatcherActivity struct {
+	timers     uint64
+	queries    uint64
+	goroutines int
+}
+
+// Simulator runs synthetic nodes and tasks through the real dispatch, validation and payment code of the service package,
+// in virtual time. The clock of the task lifecycle is set to the virtual clock while the simulator runs, and the dispatcher
+// runs its loops on it. The clock moves to the next event or dispatcher timer only after the dispatcher waits for the clock.
+// The dispatcher goroutines race for the nodes as in the relay, so runs with the same seed can differ.
+// Spot checks, canary tasks and disputes are resolved by background jobs, which are not simulated.
+type Simulator struct {
+	params     Params
+	db         *gorm.DB
+	rng        *rand.Rand
+	zipf       *rand.Zipf
+	policy     *recordingPolicy
+	dispatcher *service.TaskDispatcher
+	clock      *virtualClock
+	// the running and finished queries of the dispatcher and the simulator
+	runningQueries atomic.Int64
+	queries        atomic.Uint64
+
+	now    time.Time
+	end    time.Time
+	seq    uint64
+	events eventQueue
+
+	nodes         map[string]*simNode
+	nodeAddresses []string
+	creators      []*simCreator
+	tasks         map[string]*simTask
+	// groups with reported tasks, waiting for the other tasks to finish before validation, by task id
+	waitingGroups map[string]*simTask
+	createdTasks  int
+	queueWaits    []QueueWait
+}
+
+// NewSimulator creates a simulator on the database of the config, which should be empty
+func NewSimulator(params Params) (*Simulator, error) {
+	if err := params.Check(); err != nil {
+		return nil, err
+	}
+	rng := rand.New(rand.NewSource(params.Seed))
+	policy := &recordingPolicy{QueuePolicy: service.NewQueuePolicy()}
+	now := time.Now().Truncate(time.Second)
+	s := &Simulator{
+		params:        params,
+		db:            config.GetDB(),
+		rng:           rng,
+		policy:        policy,
+		dispatcher:    service.NewTaskDispatcher(policy),
+		clock:         newVirtualClock(now),
+		now:           now,
+		nodes:         make(map[string]*simNode),
+		tasks:         make(map[string]*simTask),
+		waitingGroups: make(map[string]*simTask),
+	}
+	if params.Models > 1 {
+		s.zipf = rand.NewZipf(rng, 1.2, 1, uint64(params.Models-1))
+	}
+	s.end = s.now.Add(params.Duration)
+	return s, nil
+}
+
+// trackQueries counts the running and finished queries, to tell whether the dispatcher is still working
+func (s *Simulator) trackQueries() error {
+	begin := func(*gorm.DB) {
+		s.runningQueries.Add(1)
+	}
+	end := func(*gorm.DB) {
+		s.runningQueries.Add(-1)
+		s.queries.Add(1)
+	}
+	callbacks := s.db.Callback()
+	return errors.Join(
+		callbacks.Query().Before("gorm:query").Register("simulation:begin_query", begin),
+		callbacks.Query().After("gorm:query").Register("simulation:end_query", end),
+		callbacks.Create().Before("gorm:create").Register("simulation:begin_create", begin),
+		callbacks.Create().After("gorm:create").Register("simulation:end_create", end),
+		callbacks.Update().Before("gorm:update").Register("simulation:begin_update", begin),
+		callbacks.Update().After("gorm:update").Register("simulation:end_update", end),
+		callbacks.Delete().Before("gorm:delete").Register("simulation:begin_delete", begin),
+		callbacks.Delete().After("gorm:delete").Register("simulation:end_delete", end),
+		callbacks.Row().Before("gorm:row").Register("simulation:begin_row", begin),
+		callbacks.Row().After("gorm:row").Register("simulation:end_row", end),
+		callbacks.Raw().Before("gorm:raw").Register("simulation:begin_raw", begin),
+		callbacks.Raw().After("gorm:raw").Register("simulation:end_raw", end),
+	)
+}
+
+func (s *Simulator) dispatcherActivity() dispatcherActivity {
+	return dispatcherActivity{
+		timers:     s.clock.timerSeq(),
+		queries:    s.queries.Load(),
+		goroutines: runtime.NumGoroutine(),
+	}
+}
+
+// settle waits until the dispatcher waits for the clock, and runs the tasks started by the dispatcher on their nodes
+func (s *Simulator) settle() {
+	last := s.dispatcherActivity()
+	for idle := 0; idle < settleRounds; {
+		// sleeping takes much longer than the interval, so the simulator yields to the dispatcher instead
+		yieldUntil := time.Now().Add(settleInterval)
+		for time.Now().Before(yieldUntil) {
+			runtime.Gosched()
+		}
+		activity := s.dispatcherActivity()
+		if activity == last && s.runningQueries.Load() == 0 {
+			idle++
+		} else {
+			last = activity
+			idle = 0
+		}
+	}
+	for _, task := range s.policy.takeStarted() {
+		s.startTask(task)
+	}
+}
+
+// advance moves the virtual time, and fires the dispatcher timers up to the time
+func (s *Simulator) advance(now time.Time) {
+	s.now = now
+	s.clock.advance(now)
+}
+
+func (s *Simulator) schedule(t time.Time, kind eventKind, task *simTask, attempt uint64, outcome taskOutcome) {
+	s.seq++
+	heap.Push(&s.events, &event{time: t, seq: s.seq, kind: kind, task: task, attempt: attempt, outcome: outcome})
+}
+
+func (s *Simulator) randomBytes(n int) []byte {
+	bs := make([]byte, n)
+	s.rng.Read(bs)
+	return bs
+}
+
+func etherToWei(ether float64) *big.Int {
+	wei, _ := new(big.Float).Mul(big.NewFloat(ether), big.NewFloat(params.Ether)).Int(nil)
+	return wei
+}
+
+func (s *Simulator) nextArrival() time.Duration {
+	return time.Duration(s.rng.ExpFloat64() / s.params.ArrivalRate * float64(time.Second))
+}
+
+func (s *Simulator) randomModelID() string {
+	var index uint64
+	if s.zipf != nil {
+		index = s.zipf.Uint64()
+	}
+	return fmt.Sprintf("model-%d", index)
+}
+
+func (s *Simulator) randomGPU() GPU {
+	total := 0.0
+	for _, gpu := range s.params.GPUs {
+		total += gpu.Share
+	}
+	r := s.rng.Float64() * total
+	for _, gpu := range s.params.GPUs {
+		if r < gpu.Share {
+			return gpu
+		}
+		r -= gpu.Share
+	}
+	return s.params.GPUs[len(s.params.GPUs)-1]
+}
+
+func (s *Simulator) transferFromRelay(ctx context.Context, to string, amount *big.Int) error {
+	if err := service.Transfer(ctx, s.db, config.GetConfig().Blockchain.Account.Address, to, amount); err != nil {
+		return err
+	}
+	return nil
+}
+
+// setup creates the tables, the relay account with enough tokens, the creators and the nodes
+func (s *Simulator) setup(ctx context.Context) error {
+	if err := s.db.AutoMigrate(allModels()...); err != nil {
+		return err
+	}
+	// network node data is upserted by address when a node joins, which sqlite only allows on a unique column
+	if err := s.db.Exec("CREATE UNIQUE INDEX idx_network_node_data_address_unique ON network_node_data(address)").Error; err != nil {
+		return err
+	}
+
+	// creators are funded for twice the expected task fees, counting every task as a validation group
+	appConfig := config.GetConfig()
+	expectedTasks := s.params.ArrivalRate * s.params.Duration.Seconds()
+	maxTaskFee := s.params.TaskFee * (1 + s.params.TaskFeeSpread)
+	creatorFund := 2*expectedTasks*maxTaskFee*float64(appConfig.Validation.GroupSize)/float64(s.params.Creators) + maxTaskFee
+	genesis := creatorFund*float64(s.params.Creators) + s.params.MaxStake*float64(s.params.Nodes)
+	appConfig.Blockchain.Account.GenesisTokenAmount = uint64(math.Ceil(genesis)) + 1
+
+	if err := service.CreateGenesisAccount(ctx, s.db); err != nil {
+		return err
+	}
+
+	for i := 0; i < s.params.Creators; i++ {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			return err
+		}
+		creator := &simCreator{
+			key:       key,
+			address:   crypto.PubkeyToAddress(key.PublicKey).Hex(),
+			publicKey: hexutil.Encode(crypto.FromECDSAPub(&key.PublicKey)[1:]),
+		}
+		if err := s.transferFromRelay(ctx, creator.address, etherToWei(creatorFund)); err != nil {
+			return err
+		}
+		s.creators = append(s.creators, creator)
+	}
+
+	nodeVersion, err := models.ParseVersion(s.params.NodeVersion)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < s.params.Nodes; i++ {
+		node := &simNode{
+			address: common.BytesToAddress(s.randomBytes(20)).Hex(),
+			gpu:     s.randomGPU(),
+			stake:   s.params.MinStake + s.rng.Float64()*(s.params.MaxStake-s.params.MinStake),
+		}
+		if s.rng.Float64() < s.params.FaultyNodeShare {
+			node.failureRate = s.params.FailureRate
+			node.timeoutRate = s.params.TimeoutRate
+		}
+		if s.rng.Float64() < s.params.CheaterShare {
+			node.cheatRate = s.params.CheatRate
+		}
+
+		stake := etherToWei(node.stake)
+		if err := s.transferFromRelay(ctx, node.address, stake); err != nil {
+			return err
+		}
+		modelIDs := make([]string, 0)
+		for _, index := range s.rng.Perm(s.params.Models)[:min(s.params.NodeModels, s.params.Models)] {
+			modelIDs = append(modelIDs, fmt.Sprintf("model-%d", index))
+		}
+		if err := service.SetNodeStatusJoin(ctx, s.db, &models.Node{
+			Address:      node.address,
+			GPUName:      node.gpu.Name,
+			GPUVram:      node.gpu.Vram,
+			StakeAmount:  models.BigInt{Int: *stake},
+			MajorVersion: nodeVersion[0],
+			MinorVersion: nodeVersion[1],
+			PatchVersion: nodeVersion[2],
+			Status:       models.NodeStatusQuit,
+		}, modelIDs, nil); err != nil {
+			return err
+		}
+		s.nodes[node.address] = node
+		s.nodeAddresses = append(s.nodeAddresses, node.address)
+	}
+	return nil
+}
+
+// Run runs the simulation until all the tasks arrived in the duration end
+func (s *Simulator) Run(ctx context.Context) (*Result, error) {
+	utils.SetClock(s.clock)
+	defer utils.SetClock(nil)
+
+	if err := s.setup(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.trackQueries(); err != nil {
+		return nil, err
+	}
+
+	dispatchCtx, cancel := context.WithCancel(ctx)
+	dispatcherDone := make(chan struct{})
+	go func() {
+		defer close(dispatcherDone)
+		s.dispatcher.Run(dispatchCtx)
+	}()
+	defer func() {
+		cancel()
+		<-dispatcherDone
+	}()
+
+	// tasks queued for a whole attempt timeout after the end are aborted by the dispatcher,
+	// so all tasks should end in max attempts times the longest life of an attempt
+	drainLimit := time.Duration(s.params.MaxAttempts) * (3*time.Minute + 2*time.Duration(s.params.Timeout)*time.Second)
+
+	s.schedule(s.now.Add(s.nextArrival()), eventArrival, nil, 0, 0)
+	s.schedule(s.now, eventValidate, nil, 0, 0)
+	for s.events.Len() > 0 {
+		s.settle()
+		// the dispatcher timers go before the events at the same time
+		if next, ok := s.clock.nextTimer(); ok && !next.After(s.events[0].time) {
+			s.advance(next)
+			continue
+		}
+		e := heap.Pop(&s.events).(*event)
+		s.advance(e.time)
+
+		var err error
+		switch e.kind {
+		case eventArrival:
+			err = s.createTask(ctx)
+			if next := s.now.Add(s.nextArrival()); next.Before(s.end) {
+				s.schedule(next, eventArrival, nil, 0, 0)
+			}
+		case eventValidate:
+			var finished bool
+			finished, err = s.validateWaitingGroups(ctx)
+			if err == nil && !finished {
+				if s.now.After(s.end.Add(drainLimit)) {
+					return nil, errors.New("tasks not finished after the drain limit")
+				}
+				s.schedule(s.now.Add(s.params.ValidateInterval), eventValidate, nil, 0, 0)
+			}
+		case eventReport:
+			err = s.reportTask(ctx, e)
+		case eventTimeout:
+			err = s.timeoutTask(ctx, e)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.result(ctx)
+}
+
+func (s *Simulator) newTask(creator *simCreator, taskIDBytes []byte, modelID string, taskFee *big.Int) (*models.InferenceTask, error) {
+	nonceBytes := s.randomBytes(32)
+	v, threshold, err := models.ResolveTaskValidator(s.params.TaskType, []string{modelID})
+	if err != nil {
+		return nil, err
+	}
+	return &models.InferenceTask{
+		TaskArgs:           "{}",
+		TaskIDCommitment:   crypto.Keccak256Hash(taskIDBytes, nonceBytes).Hex(),
+		Creator:            creator.address,
+		SamplingSeed:       hexutil.Encode(s.randomBytes(32)),
+		Nonce:              hexutil.Encode(nonceBytes),
+		Status:             models.TaskQueued,
+		TaskType:           s.params.TaskType,
+		TaskVersion:        s.params.TaskVersion,
+		TaskFee:            models.BigInt{Int: *new(big.Int).Set(taskFee)},
+		ModelIDs:           []string{modelID},
+		MinVRAM:            s.params.MinVRAM,
+		Timeout:            s.params.Timeout,
+		MaxAttempts:        s.params.MaxAttempts,
+		Validator:          v.Name(),
+		ValidatorThreshold: threshold,
+		CreateTime:         sql.NullTime{Time: s.now, Valid: true},
+	}, nil
+}
+
+// createTask creates a task of a random creator as the api does.
+// The creator creates the other tasks of the validation group when the task is selected for validation by its vrf.
+func (s *Simulator) createTask(ctx context.Context) error {
+	creator := s.creators[s.rng.Intn(len(s.creators))]
+	taskIDBytes := s.randomBytes(32)
+	modelID := s.randomModelID()
+	taskFee := etherToWei(s.params.TaskFee * (1 + s.params.TaskFeeSpread*(2*s.rng.Float64()-1)))
+
+	task, err := s.newTask(creator, taskIDBytes, modelID, taskFee)
+	if err != nil {
+		return err
+	}
+	if err := service.CreateTask(ctx, s.db, task, nil); err != nil {
+		return err
+	}
+	samplingSeed, err := hexutil.Decode(task.SamplingSeed)
+	if err != nil {
+		return err
+	}
+	beta, pi, err := ecvrf.Secp256k1Sha256Tai.Prove(creator.key, samplingSeed)
+	if err != nil {
+		return err
+	}
+	st := &simTask{
+		taskIDCommitment: task.TaskIDCommitment,
+		creator:          creator,
+		taskID:           hexutil.Encode(taskIDBytes),
+		vrfProof:         hexutil.Encode(pi),
+	}
+	s.tasks[st.taskIDCommitment] = st
+	s.createdTasks++
+
+	appConfig := config.GetConfig()
+	if !utils.VrfNeedValidation(beta, appConfig.Validation.SamplingModulus) {
+		return nil
+	}
+	group := []string{st.taskIDCommitment}
+	for i := uint64(1); i < appConfig.Validation.GroupSize; i++ {
+		task, err := s.newTask(creator, taskIDBytes, modelID, taskFee)
+		if err != nil {
+			return err
+		}
+		if err := service.CreateTask(ctx, s.db, task, nil); err != nil {
+			return err
+		}
+		member := *st
+		member.taskIDCommitment = task.TaskIDCommitment
+		s.tasks[member.taskIDCommitment] = &member
+		s.createdTasks++
+		group = append(group, member.taskIDCommitment)
+	}
+	for _, taskIDCommitment := range group {
+		s.tasks[taskIDCommitment].group = group
+	}
+	return nil
+}
+
+// validateWaitingGroups tries to validate the waiting groups, whose tasks may be aborted by the dispatcher.
+// It reports whether all tasks are finished after the arrival of tasks ends.
+func (s *Simulator) validateWaitingGroups(ctx context.Context) (bool, error) {
+	taskIDs := make([]string, 0, len(s.waitingGroups))
+	for taskID := range s.waitingGroups {
+		taskIDs = append(taskIDs, taskID)
+	}
+	sort.Strings(taskIDs)
+	for _, taskID := range taskIDs {
+		if err := s.validateTask(ctx, s.waitingGroups[taskID]); err != nil {
+			return false, err
+		}
+	}
+
+	if s.now.Before(s.end) {
+		return false, nil
+	}
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.InferenceTask{}).
+		Where("status IN ?", []models.TaskStatus{
+			models.TaskQueued, models.TaskStarted, models.TaskErrorReported,
+			models.TaskScoreReady, models.TaskValidated, models.TaskGroupValidated,
+		}).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count == 0, nil
+}
+
+// startTask decides how the selected node runs the task, by the gpu speed and the rates of the node
+func (s *Simulator) startTask(task *models.InferenceTask) {
+	st := s.tasks[task.TaskIDCommitment]
+	node := s.nodes[task.SelectedNode]
+	node.tasks++
+	s.queueWaits = append(s.queueWaits, QueueWait{
+		TaskIDCommitment: task.TaskIDCommitment,
+		Attempt:          task.Attempts,
+		Node:             task.SelectedNode,
+		Seconds:          task.StartTime.Time.Sub(task.QueueStartTime()).Seconds(),
+	})
+
+	// the execution time varies by a log normal factor around the mean of the gpu
+	execution := time.Duration(float64(s.params.ExecutionTime) / node.gpu.Speed * math.Exp(0.2*s.rng.NormFloat64()))
+	if task.ModelSwtiched {
+		execution += s.params.ModelSwitchTime
+	}
+	timeout := time.Duration(task.Timeout) * time.Second
+	r := s.rng.Float64()
+	if r < node.timeoutRate || execution >= timeout {
+		s.schedule(task.StartTime.Time.Add(timeout), eventTimeout, st, task.Attempts, 0)
+		return
+	}
+	outcome := outcomeScore
+	if r < node.timeoutRate+node.failureRate {
+		outcome = outcomeError
+	} else if s.rng.Float64() < node.cheatRate {
+		outcome = outcomeCheat
+	}
+	s.schedule(task.StartTime.Time.Add(execution), eventReport, st, task.Attempts, outcome)
+}
+
+// getStartedTask returns the task if it is still running the attempt of the event
+func (s *Simulator) getStartedTask(ctx context.Context, e *event) (*models.InferenceTask, error) {
+	task, err := models.GetTaskByIDCommitment(ctx, s.db, e.task.taskIDCommitment)
+	if err != nil {
+		return nil, err
+	}
+	if task.Status != models.TaskStarted || task.Attempts != e.attempt {
+		return nil, nil
+	}
+	return task, nil
+}
+
+// reportTask reports the score or the error of the task by its node, and the creator validates the task
+func (s *Simulator) reportTask(ctx context.Context, e *event) error {
+	task, err := s.getStartedTask(ctx, e)
+	if err != nil || task == nil {
+		return err
+	}
+	node := s.nodes[task.SelectedNode]
+	if e.outcome == outcomeError {
+		node.errors++
+		task.TaskError = models.TaskErrorParametersValidationFailed
+		if err := service.SetTaskStatusErrorReported(ctx, s.db, task); err != nil {
+			return err
+		}
+	} else {
+		// honest nodes agree on the score of the same task id, cheaters make up a random one
+		score := crypto.Keccak256([]byte(e.task.taskID))
+		e.task.cheated = e.outcome == outcomeCheat
+		if e.task.cheated {
+			node.cheats++
+			score = s.randomBytes(len(score))
+		}
+		task.Score = hexutil.Encode(score)
+		if err := service.SetTaskStatusScoreReady(ctx, s.db, task); err != nil {
+			return err
+		}
+	}
+	return s.validateTask(ctx, e.task)
+}
+
+// timeoutTask aborts the task by its creator after the timeout, the task is requeued if it has attempts left
+func (s *Simulator) timeoutTask(ctx context.Context, e *event) error {
+	task, err := s.getStartedTask(ctx, e)
+	if err != nil || task == nil {
+		return err
+	}
+	s.nodes[task.SelectedNode].timeouts++
+	task.AbortReason = models.TaskAbortTimeout
+	task.ValidatedTime = sql.NullTime{Time: s.now, Valid: true}
+	if err := service.AbortTask(ctx, s.db, task, e.task.creator.address); err != nil {
+		return err
+	}
+	if task.Status == models.TaskEndAborted && e.task.group != nil {
+		return s.validateTask(ctx, e.task)
+	}
+	return nil
+}
+
+func isTaskWaitingValidation(task *models.InferenceTask, grouped bool) bool {
+	if task.Status == models.TaskScoreReady || task.Status == models.TaskErrorReported {
+		return true
+	}
+	return grouped && task.Status == models.TaskEndAborted
+}
+
+// validateTask validates the task by its creator, a group is validated after all of its tasks finish.
+// The nodes upload the results of validated tasks at once.
+func (s *Simulator) validateTask(ctx context.Context, st *simTask) error {
+	var tasks []*models.InferenceTask
+	if st.group == nil {
+		task, err := models.GetTaskByIDCommitment(ctx, s.db, st.taskIDCommitment)
+		if err != nil {
+			return err
+		}
+		if !isTaskWaitingValidation(task, false) {
+			return nil
+		}
+		if err := service.ValidateSingleTask(ctx, task, st.taskID, st.vrfProof, st.creator.publicKey); err != nil {
+			return err
+		}
+		tasks = append(tasks, task)
+	} else {
+		groupTasks, err := models.GetTasksByIDCommitments(ctx, s.db, st.group)
+		if err != nil {
+			return err
+		}
+		for i := range groupTasks {
+			if !isTaskWaitingValidation(&groupTasks[i], true) {
+				s.waitingGroups[st.taskID] = st
+				return nil
+			}
+			tasks = append(tasks, &groupTasks[i])
+		}
+		delete(s.waitingGroups, st.taskID)
+		if err := service.ValidateTaskGroup(ctx, tasks, st.taskID, st.vrfProof, st.creator.publicKey); err != nil {
+			return err
+		}
+	}
+
+	for _, task := range tasks {
+		if task.Status == models.TaskValidated || task.Status == models.TaskGroupValidated {
+			if err := service.SetTaskStatusEndSuccess(ctx, s.db, task); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func allModels() []interface{} {
+	return []interface{}{
+		&models.Balance{}, &models.TransferEvent{}, &models.Event{}, &models.InferenceTask{}, &models.InferenceTaskAttempt{},
+		&models.Node{}, &models.NodeModel{}, &models.NodeHardware{}, &models.NodeIncentive{}, &models.NetworkNodeData{},
+		&models.TaskDependency{}, &models.TaskStatusTransition{}, &models.TaskStreamChunk{}, &models.ValidationRecord{},
+		&models.SpotCheck{}, &models.CanaryTask{}, &models.TaskDispute{},
+	}
+}
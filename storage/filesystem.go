@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type FilesystemStorage struct {
+	root string
+}
+
+func NewFilesystemStorage(root string) *FilesystemStorage {
+	return &FilesystemStorage{root: root}
+}
+
+func (s *FilesystemStorage) filename(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
+
+func (s *FilesystemStorage) Put(ctx context.Context, key string, reader io.Reader, size int64) error {
+	filename := s.filename(key)
+	if err := os.MkdirAll(filepath.Dir(filename), 0o711); err != nil {
+		return err
+	}
+
+	// write to a temp file first, so that readers never see a partial file.
+	// The temp file is unique, so that concurrent puts of the same key do not write to the same file.
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpFilename := f.Name()
+	// keep the permission of files created by os.Create, temp files are only readable by the owner
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		os.Remove(tmpFilename)
+		return err
+	}
+	if _, err := io.Copy(f, reader); err != nil {
+		f.Close()
+		os.Remove(tmpFilename)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpFilename)
+		return err
+	}
+	if err := os.Rename(tmpFilename, filename); err != nil {
+		os.Remove(tmpFilename)
+		return err
+	}
+	return nil
+}
+
+func (s *FilesystemStorage) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	f, err := os.Open(s.filename(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if stat.IsDir() {
+		f.Close()
+		return nil, nil, ErrNotFound
+	}
+	return f, &ObjectInfo{Key: key, Size: stat.Size(), LastModified: stat.ModTime()}, nil
+}
+
+func (s *FilesystemStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	stat, err := os.Stat(s.filename(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if stat.IsDir() {
+		return nil, ErrNotFound
+	}
+	return &ObjectInfo{Key: key, Size: stat.Size(), LastModified: stat.ModTime()}, nil
+}
+
+func (s *FilesystemStorage) Delete(ctx context.Context, key string) error {
+	return os.RemoveAll(s.filename(key))
+}
+
+func (s *FilesystemStorage) PresignedURL(ctx context.Context, key string, expires time.Duration, filename string) (string, error) {
+	return "", ErrPresignNotSupported
+}
@@ -0,0 +1,118 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"crynux_relay/storage"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestFilesystemStorage(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	s := storage.NewFilesystemStorage(root)
+
+	key := "task/results/0.png"
+	content := []byte("result")
+	if err := s.Put(ctx, key, bytes.NewReader(content), int64(len(content))); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, info, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Fatalf("Wrong content: %s", data)
+	}
+	if info.Key != key || info.Size != int64(len(content)) {
+		t.Fatalf("Wrong object info: %+v", info)
+	}
+
+	stat, err := os.Stat(filepath.Join(root, "task", "results", "0.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode().Perm() != 0o644 {
+		t.Fatalf("Wrong file permission: %v", stat.Mode().Perm())
+	}
+
+	if err := s.Delete(ctx, "task"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Get(ctx, key); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Object not deleted: %v", err)
+	}
+	if _, err := s.Stat(ctx, key); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Object not deleted: %v", err)
+	}
+}
+
+func TestFilesystemStorageConcurrentPut(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	s := storage.NewFilesystemStorage(root)
+
+	key := "task/results/0.png"
+	contents := make([][]byte, 10)
+	for i := range contents {
+		contents[i] = bytes.Repeat([]byte(fmt.Sprint(i)), 1<<16)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(contents))
+	for i, content := range contents {
+		wg.Add(1)
+		go func(i int, content []byte) {
+			defer wg.Done()
+			errs[i] = s.Put(ctx, key, bytes.NewReader(content), int64(len(content)))
+		}(i, content)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the object is one of the puts as a whole
+	reader, _, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, content := range contents {
+		if bytes.Equal(data, content) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Fatal("Object is mixed from concurrent puts")
+	}
+
+	// no temp files are left
+	entries, err := os.ReadDir(filepath.Join(root, "task", "results"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Temp files left: %d", len(entries))
+	}
+}
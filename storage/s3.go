@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+type S3Options struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	UseSSL          bool
+	// prefix of all object keys in the bucket, so that the bucket can be shared
+	Prefix string
+}
+
+type S3Storage struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func NewS3Storage(opts *S3Options) (*S3Storage, error) {
+	client, err := minio.New(opts.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(opts.AccessKeyID, opts.SecretAccessKey, ""),
+		Secure: opts.UseSSL,
+		Region: opts.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &S3Storage{
+		client: client,
+		bucket: opts.Bucket,
+		prefix: strings.Trim(opts.Prefix, "/"),
+	}, nil
+}
+
+func (s *S3Storage) objectName(key string) string {
+	if len(s.prefix) == 0 {
+		return key
+	}
+	return path.Join(s.prefix, key)
+}
+
+func isNotFound(err error) bool {
+	code := minio.ToErrorResponse(err).Code
+	return code == "NoSuchKey" || code == "NotFound"
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, reader io.Reader, size int64) error {
+	_, err := s.client.PutObject(ctx, s.bucket, s.objectName(key), reader, size, minio.PutObjectOptions{
+		ContentType: "application/octet-stream",
+	})
+	return err
+}
+
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, s.objectName(key), minio.GetObjectOptions{})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, err
+	}
+	// GetObject is lazy, the object is requested by Stat
+	stat, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		if isNotFound(err) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, err
+	}
+	return obj, &ObjectInfo{Key: key, Size: stat.Size, LastModified: stat.LastModified}, nil
+}
+
+func (s *S3Storage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	stat, err := s.client.StatObject(ctx, s.bucket, s.objectName(key), minio.StatObjectOptions{})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &ObjectInfo{Key: key, Size: stat.Size, LastModified: stat.LastModified}, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	objectName := s.objectName(key)
+	if err := s.client.RemoveObject(ctx, s.bucket, objectName, minio.RemoveObjectOptions{}); err != nil && !isNotFound(err) {
+		return err
+	}
+
+	objectsCh := s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		Prefix:    objectName + "/",
+		Recursive: true,
+	})
+	for e := range s.client.RemoveObjects(ctx, s.bucket, objectsCh, minio.RemoveObjectsOptions{}) {
+		if e.Err != nil {
+			return e.Err
+		}
+	}
+	return nil
+}
+
+func (s *S3Storage) PresignedURL(ctx context.Context, key string, expires time.Duration, filename string) (string, error) {
+	params := url.Values{}
+	if len(filename) > 0 {
+		params.Set("response-content-disposition", "attachment; filename="+filename)
+	}
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, s.objectName(key), expires, params)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"crynux_relay/config"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+)
+
+var (
+	ErrNotFound            = errors.New("object not found")
+	ErrPresignNotSupported = errors.New("presigned url is not supported by the storage backend")
+)
+
+const (
+	BackendFilesystem = "filesystem"
+	BackendS3         = "s3"
+)
+
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// Storage stores the task artifacts, keyed by slash separated paths
+// like "<task id commitment>/results/0.png"
+type Storage interface {
+	Put(ctx context.Context, key string, reader io.Reader, size int64) error
+	Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Delete removes the object of the key, and all objects under the key if it is a directory
+	Delete(ctx context.Context, key string) error
+	// PresignedURL returns a url from which the object can be downloaded without the relay
+	PresignedURL(ctx context.Context, key string, expires time.Duration, filename string) (string, error)
+}
+
+var storage Storage
+
+func InitStorage(appConfig *config.AppConfig) error {
+	switch appConfig.Storage.Backend {
+	case "", BackendFilesystem:
+		storage = NewFilesystemStorage(appConfig.DataDir.InferenceTasks)
+	case BackendS3:
+		s3Config := appConfig.Storage.S3
+		s, err := NewS3Storage(&S3Options{
+			Endpoint:        s3Config.Endpoint,
+			Region:          s3Config.Region,
+			Bucket:          s3Config.Bucket,
+			AccessKeyID:     s3Config.AccessKeyID,
+			SecretAccessKey: s3Config.SecretAccessKey,
+			UseSSL:          s3Config.UseSSL,
+			Prefix:          s3Config.Prefix,
+		})
+		if err != nil {
+			return err
+		}
+		storage = s
+	default:
+		return fmt.Errorf("unknown storage backend: %s", appConfig.Storage.Backend)
+	}
+	return nil
+}
+
+func GetStorage() Storage {
+	return storage
+}
+
+func TaskInputKey(taskIDCommitment, filename string) string {
+	return path.Join(taskIDCommitment, "input", filename)
+}
+
+func TaskResultKey(taskIDCommitment, filename string) string {
+	return path.Join(taskIDCommitment, "results", filename)
+}
+
+func TaskKey(taskIDCommitment string) string {
+	return taskIDCommitment
+}
+
+func Copy(ctx context.Context, s Storage, srcKey, dstKey string) error {
+	reader, info, err := s.Get(ctx, srcKey)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return s.Put(ctx, dstKey, reader, info.Size)
+}
@@ -0,0 +1,84 @@
+package tasks
+
+import (
+	"context"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"crynux_relay/service"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func resolveCanaryTasks(ctx context.Context) error {
+	var startID uint = 0
+	limit := 100
+	for {
+		canaries, err := models.GetPendingCanaryTasks(ctx, config.GetDB(), startID, limit)
+		if err != nil {
+			return err
+		}
+		for i := range canaries {
+			canary := &canaries[i]
+			if err := service.ResolveCanaryTask(ctx, config.GetDB(), canary); err != nil {
+				log.Errorf("CanaryTasks: resolve canary task %s error: %v", canary.TaskIDCommitment, err)
+			}
+		}
+		if len(canaries) < limit {
+			return nil
+		}
+		startID = canaries[len(canaries)-1].ID
+	}
+}
+
+func StartCreateCanaryTasks(ctx context.Context) {
+	appConfig := config.GetConfig()
+	if !appConfig.Canary.Enabled {
+		return
+	}
+
+	duration := time.Duration(appConfig.Canary.Interval) * time.Minute
+	ticker := time.NewTicker(duration)
+
+	index := 0
+	for {
+		select {
+		case <-ctx.Done():
+			err := ctx.Err()
+			ticker.Stop()
+			log.Errorf("CanaryTasks: stop creating canary tasks due to %v", err)
+			return
+		case <-ticker.C:
+			task, err := service.CreateCanaryTask(ctx, config.GetDB(), index)
+			if err != nil {
+				log.Errorf("CanaryTasks: create canary task error: %v", err)
+			} else {
+				log.Infof("CanaryTasks: create canary task %s", task.TaskIDCommitment)
+			}
+			index++
+		}
+	}
+}
+
+func StartResolveCanaryTasks(ctx context.Context) {
+	if !config.GetConfig().Canary.Enabled {
+		return
+	}
+
+	duration := 5 * time.Second
+	ticker := time.NewTicker(duration)
+
+	for {
+		select {
+		case <-ctx.Done():
+			err := ctx.Err()
+			ticker.Stop()
+			log.Errorf("CanaryTasks: stop resolving canary tasks due to %v", err)
+			return
+		case <-ticker.C:
+			if err := resolveCanaryTasks(ctx); err != nil {
+				log.Errorf("CanaryTasks: resolve canary tasks error: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package tasks
+
+import (
+	"context"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"crynux_relay/storage"
+	"database/sql"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func getTaskRetention(taskType models.TaskType) time.Duration {
+	spec, ok := models.GetTaskTypeSpec(taskType)
+	if !ok || spec.RetentionHours == nil {
+		return 0
+	}
+	return time.Duration(spec.RetentionHours()) * time.Hour
+}
+
+func getExpiredTasks(ctx context.Context, taskType models.TaskType, deadline time.Time, startID uint, limit int) ([]models.InferenceTask, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	endStatuses := []models.TaskStatus{
+		models.TaskEndSuccess,
+		models.TaskEndGroupSuccess,
+		models.TaskEndAborted,
+		models.TaskEndInvalidated,
+		models.TaskEndGroupRefund,
+	}
+
+	var tasks []models.InferenceTask
+	if err := config.GetDB().WithContext(dbCtx).Model(&models.InferenceTask{}).
+		Where("task_type = ?", taskType).
+		Where("status IN ?", endStatuses).
+		// the retention starts when the task ends, every end status sets the validated time.
+		// The update time is not used as it changes with every later update, like setting retain_until.
+		Where("validated_time < ?", deadline).
+		Where("results_purged_at IS NULL").
+		Where("retain_until IS NULL OR retain_until < ?", time.Now()).
+		Where("id > ?", startID).
+		Order("id").
+		Limit(limit).
+		Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func purgeTaskData(ctx context.Context, task *models.InferenceTask) error {
+	// results of a task group may be used by a waiting task which depends on any task of the group
+	taskIDCommitments := []string{task.TaskIDCommitment}
+	if len(task.TaskID) > 0 {
+		groupTasks, err := models.GetTaskGroupByTaskID(ctx, config.GetDB(), task.TaskID)
+		if err != nil {
+			return err
+		}
+		for _, t := range groupTasks {
+			if t.TaskIDCommitment != task.TaskIDCommitment {
+				taskIDCommitments = append(taskIDCommitments, t.TaskIDCommitment)
+			}
+		}
+	}
+	hasWaitingChild, err := models.HasWaitingChildTask(ctx, config.GetDB(), taskIDCommitments)
+	if err != nil {
+		return err
+	}
+	if hasWaitingChild {
+		return nil
+	}
+
+	if err := storage.GetStorage().Delete(ctx, storage.TaskKey(task.TaskIDCommitment)); err != nil {
+		return err
+	}
+	if models.IsStreamingTaskType(task.TaskType) {
+		if err := models.DeleteTaskStreamChunks(ctx, config.GetDB(), task.TaskIDCommitment); err != nil {
+			return err
+		}
+	}
+	return task.Update(ctx, config.GetDB(), map[string]interface{}{
+		"results_purged_at": sql.NullTime{Time: time.Now(), Valid: true},
+	})
+}
+
+func PurgeTaskData(ctx context.Context) error {
+	taskTypes := models.GetTaskTypes()
+
+	limit := 100
+	for _, taskType := range taskTypes {
+		retention := getTaskRetention(taskType)
+		if retention == 0 {
+			continue
+		}
+		deadline := time.Now().Add(-retention)
+
+		var startID uint = 0
+		for {
+			tasks, err := getExpiredTasks(ctx, taskType, deadline, startID, limit)
+			if err != nil {
+				return err
+			}
+			for i := range tasks {
+				if err := purgeTaskData(ctx, &tasks[i]); err != nil {
+					log.Errorf("PurgeTaskData: purge task %s data error: %v", tasks[i].TaskIDCommitment, err)
+				}
+			}
+			if len(tasks) < limit {
+				break
+			}
+			startID = tasks[len(tasks)-1].ID
+		}
+	}
+	return nil
+}
+
+func StartPurgeTaskData(ctx context.Context) {
+	appConfig := config.GetConfig()
+	if !appConfig.Retention.Enabled {
+		return
+	}
+
+	interval := appConfig.Retention.CheckInterval
+	if interval == 0 {
+		interval = 60
+	}
+	duration := time.Duration(interval) * time.Minute
+	ticker := time.NewTicker(duration)
+
+	for {
+		select {
+		case <-ctx.Done():
+			err := ctx.Err()
+			ticker.Stop()
+			log.Errorf("PurgeTaskData: stop purging task data due to %v", err)
+			return
+		case <-ticker.C:
+			log.Infof("PurgeTaskData: start purging task data")
+			if err := PurgeTaskData(ctx); err != nil {
+				log.Errorf("PurgeTaskData: purge task data error %v", err)
+			}
+			log.Infof("PurgeTaskData: end purging task data")
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package tasks
+
+import (
+	"context"
+	"crynux_relay/config"
+	"crynux_relay/service"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// StartRefreshMaxStaking runs on every relay instance, not only on the leader,
+// because the max staking is kept in memory by each instance
+func StartRefreshMaxStaking(ctx context.Context) {
+	duration := 30 * time.Second
+	ticker := time.NewTicker(duration)
+
+	for {
+		select {
+		case <-ctx.Done():
+			err := ctx.Err()
+			ticker.Stop()
+			log.Errorf("RefreshMaxStaking: stop refreshing max staking due to %v", err)
+			return
+		case <-ticker.C:
+			func() {
+				ctx1, cancel := context.WithTimeout(ctx, duration)
+				defer cancel()
+				if err := service.RefreshMaxStaking(ctx1, config.GetDB()); err != nil {
+					log.Errorf("RefreshMaxStaking: refresh max staking error %v", err)
+				}
+			}()
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package tasks
+
+import (
+	"context"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"crynux_relay/service"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func resolveSpotChecks(ctx context.Context) error {
+	var startID uint = 0
+	limit := 100
+	for {
+		spotChecks, err := models.GetPendingSpotChecks(ctx, config.GetDB(), startID, limit)
+		if err != nil {
+			return err
+		}
+		for i := range spotChecks {
+			spotCheck := &spotChecks[i]
+			if err := service.ResolveSpotCheck(ctx, config.GetDB(), spotCheck); err != nil {
+				log.Errorf("SpotChecks: resolve spot check of task %s error: %v", spotCheck.TaskIDCommitment, err)
+			}
+		}
+		if len(spotChecks) < limit {
+			return nil
+		}
+		startID = spotChecks[len(spotChecks)-1].ID
+	}
+}
+
+func StartResolveSpotChecks(ctx context.Context) {
+	if !config.GetConfig().SpotCheck.Enabled {
+		return
+	}
+
+	duration := 5 * time.Second
+	ticker := time.NewTicker(duration)
+
+	for {
+		select {
+		case <-ctx.Done():
+			err := ctx.Err()
+			ticker.Stop()
+			log.Errorf("SpotChecks: stop resolving spot checks due to %v", err)
+			return
+		case <-ticker.C:
+			if err := resolveSpotChecks(ctx); err != nil {
+				log.Errorf("SpotChecks: resolve spot checks error: %v", err)
+			}
+		}
+	}
+}
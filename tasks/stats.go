@@ -17,7 +17,7 @@ var statsDuration time.Duration = time.Hour
 func getTaskCounts(ctx context.Context, start, end time.Time) ([]*models.TaskCount, error) {
 	var results []*models.TaskCount
 
-	taskTypes := []models.TaskType{models.TaskTypeSD, models.TaskTypeLLM, models.TaskTypeSDFTLora}
+	taskTypes := models.GetTaskTypes()
 
 	for _, taskType := range taskTypes {
 		var successCount, abortedCount int64
@@ -28,6 +28,7 @@ func getTaskCounts(ctx context.Context, start, end time.Time) ([]*models.TaskCou
 			if err := config.GetDB().WithContext(dbCtx).Model(&models.InferenceTask{}).
 				Where("created_at >= ?", start).Where("created_at < ?", end).
 				Where("task_type = ?", taskType).
+				Where("canary = ?", false).
 				Where("(status = ? OR status = ?)", models.TaskEndAborted, models.TaskEndInvalidated).
 				Count(&abortedCount).Error; err != nil {
 				return err
@@ -45,6 +46,7 @@ func getTaskCounts(ctx context.Context, start, end time.Time) ([]*models.TaskCou
 			if err := config.GetDB().WithContext(dbCtx).Model(&models.InferenceTask{}).
 				Where("created_at >= ?", start).Where("created_at < ?", end).
 				Where("task_type = ?", taskType).
+				Where("canary = ?", false).
 				Where("(status = ? OR status = ?)", models.TaskEndSuccess, models.TaskEndGroupRefund).
 				Count(&successCount).Error; err != nil {
 				return err
@@ -143,7 +145,7 @@ func StartStatsTaskCount(ctx context.Context) {
 func getTaskExecutionTimeCount(ctx context.Context, start, end time.Time) ([]*models.TaskExecutionTimeCount, error) {
 	var results []*models.TaskExecutionTimeCount
 
-	taskTypes := []models.TaskType{models.TaskTypeSD, models.TaskTypeLLM, models.TaskTypeSDFTLora}
+	taskTypes := models.GetTaskTypes()
 	modelSwitchedEnums := []bool{false, true}
 	binSize := 5
 	for _, taskType := range taskTypes {
@@ -156,6 +158,7 @@ func getTaskExecutionTimeCount(ctx context.Context, start, end time.Time) ([]*mo
 					Select("id, CAST(TIMESTAMPDIFF(SECOND, start_time, score_ready_time) / ? AS SIGNED) AS time", binSize).
 					Where("created_at >= ?", start).Where("created_at < ?", end).
 					Where("task_type = ?", taskType).
+					Where("canary = ?", false).
 					Where("model_swtiched = ?", modelSwitched).
 					Where("score_ready_time IS NOT NULL")
 				return config.GetDB().WithContext(dbCtx).
@@ -259,7 +262,7 @@ func StartStatsTaskExecutionTimeCount(ctx context.Context) {
 func getTaskUploadResultTimeCount(ctx context.Context, start, end time.Time) ([]*models.TaskUploadResultTimeCount, error) {
 	var results []*models.TaskUploadResultTimeCount
 
-	taskTypes := []models.TaskType{models.TaskTypeSD, models.TaskTypeLLM, models.TaskTypeSDFTLora}
+	taskTypes := models.GetTaskTypes()
 	binSize := 5
 	for _, taskType := range taskTypes {
 		rows, err := func() (*sql.Rows, error) {
@@ -269,6 +272,7 @@ func getTaskUploadResultTimeCount(ctx context.Context, start, end time.Time) ([]
 				Select("id, CAST(TIMESTAMPDIFF(SECOND, validated_time, result_uploaded_time) / ? AS SIGNED) AS time", binSize).
 				Where("created_at >= ?", start).Where("created_at < ?", end).
 				Where("task_type = ?", taskType).
+				Where("canary = ?", false).
 				Where("result_uploaded_time IS NOT NULL")
 			return config.GetDB().WithContext(dbCtx).
 				Table("(?) AS s", subQuery).
@@ -367,7 +371,7 @@ func StartStatsTaskUploadResultTimeCount(ctx context.Context) {
 func getTaskWaitingTimeCount(ctx context.Context, start, end time.Time) ([]*models.TaskWaitingTimeCount, error) {
 	var results []*models.TaskWaitingTimeCount
 
-	taskTypes := []models.TaskType{models.TaskTypeSD, models.TaskTypeLLM, models.TaskTypeSDFTLora}
+	taskTypes := models.GetTaskTypes()
 	binSize := 5
 	for _, taskType := range taskTypes {
 		rows, err := func() (*sql.Rows, error) {
@@ -377,6 +381,7 @@ func getTaskWaitingTimeCount(ctx context.Context, start, end time.Time) ([]*mode
 				Select("id, CAST(TIMESTAMPDIFF(SECOND, create_time, start_time) / ? AS SIGNED) AS time", binSize).
 				Where("created_at >= ?", start).Where("created_at < ?", end).
 				Where("task_type = ?", taskType).
+				Where("canary = ?", false).
 				Where("start_time IS NOT NULL")
 			return config.GetDB().WithContext(dbCtx).Table("(?) AS s", subQuery).
 				Select("s.time * ? as T, COUNT(s.id) AS count", binSize).
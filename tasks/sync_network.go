@@ -204,8 +204,25 @@ func syncNodeData(ctx context.Context) error {
 		ctx1, cancel := context.WithTimeout(ctx, 10*time.Second)
 		defer cancel()
 		defer wg.Done()
+		addresses := make([]string, len(allNodeDatas))
+		for i, data := range allNodeDatas {
+			addresses[i] = data.Address
+		}
+		hardwares, err := models.GetNodeHardwares(ctx1, config.GetDB(), addresses)
+		if err != nil {
+			log.Errorf("SyncNetwork: error getting node hardwares %v", err)
+			select {
+			case errChan <- err:
+			default:
+			}
+			return
+		}
 		for _, data := range allNodeDatas {
-			totalGFLOPS += models.GetGPUGFLOPS(data.CardModel)
+			if hardware, ok := hardwares[data.Address]; ok {
+				totalGFLOPS += hardware.GFLOPS()
+			} else {
+				totalGFLOPS += models.GetGPUGFLOPS("", data.CardModel)
+			}
 		}
 
 		networkFLOPS := models.NetworkFLOPS{GFLOPS: totalGFLOPS}
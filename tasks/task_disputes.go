@@ -0,0 +1,55 @@
+package tasks
+
+import (
+	"context"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"crynux_relay/service"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func resolveTaskDisputes(ctx context.Context) error {
+	var startID uint = 0
+	limit := 100
+	for {
+		disputes, err := models.GetOpenTaskDisputes(ctx, config.GetDB(), startID, limit)
+		if err != nil {
+			return err
+		}
+		for i := range disputes {
+			dispute := &disputes[i]
+			if err := service.ResolveTaskDispute(ctx, config.GetDB(), dispute); err != nil {
+				log.Errorf("TaskDisputes: resolve dispute of task %s error: %v", dispute.TaskIDCommitment, err)
+			}
+		}
+		if len(disputes) < limit {
+			return nil
+		}
+		startID = disputes[len(disputes)-1].ID
+	}
+}
+
+func StartResolveTaskDisputes(ctx context.Context) {
+	if !config.GetConfig().Dispute.Enabled {
+		return
+	}
+
+	duration := 5 * time.Second
+	ticker := time.NewTicker(duration)
+
+	for {
+		select {
+		case <-ctx.Done():
+			err := ctx.Err()
+			ticker.Stop()
+			log.Errorf("TaskDisputes: stop resolving task disputes due to %v", err)
+			return
+		case <-ticker.C:
+			if err := resolveTaskDisputes(ctx); err != nil {
+				log.Errorf("TaskDisputes: resolve task disputes error: %v", err)
+			}
+		}
+	}
+}
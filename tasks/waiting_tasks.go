@@ -0,0 +1,67 @@
+package tasks
+
+import (
+	"context"
+	"crynux_relay/config"
+	"crynux_relay/models"
+	"crynux_relay/service"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func getWaitingTasks(ctx context.Context, startID uint, limit int) ([]models.InferenceTask, error) {
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var tasks []models.InferenceTask
+	if err := config.GetDB().WithContext(dbCtx).Model(&models.InferenceTask{}).
+		Where("status = ?", models.TaskWaiting).
+		Where("id > ?", startID).
+		Order("id").
+		Limit(limit).
+		Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func processWaitingTasks(ctx context.Context) error {
+	var startID uint = 0
+	limit := 100
+	for {
+		tasks, err := getWaitingTasks(ctx, startID, limit)
+		if err != nil {
+			return err
+		}
+		for i := range tasks {
+			task := &tasks[i]
+			if err := service.ProcessWaitingTask(ctx, config.GetDB(), task); err != nil {
+				log.Errorf("WaitingTasks: process waiting task %s error: %v", task.TaskIDCommitment, err)
+			}
+		}
+		if len(tasks) < limit {
+			return nil
+		}
+		startID = tasks[len(tasks)-1].ID
+	}
+}
+
+func StartProcessWaitingTasks(ctx context.Context) {
+	duration := 5 * time.Second
+	ticker := time.NewTicker(duration)
+
+	for {
+		select {
+		case <-ctx.Done():
+			err := ctx.Err()
+			ticker.Stop()
+			log.Errorf("WaitingTasks: stop processing waiting tasks due to %v", err)
+			return
+		case <-ticker.C:
+			if err := processWaitingTasks(ctx); err != nil {
+				log.Errorf("WaitingTasks: process waiting tasks error: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock is the clock of the task lifecycle
+type Clock interface {
+	Now() time.Time
+	// After returns a channel which receives the time after the duration passes
+	After(d time.Duration) <-chan time.Time
+}
+
+type wallClock struct{}
+
+func (wallClock) Now() time.Time {
+	return time.Now()
+}
+
+func (wallClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+var (
+	clockMu sync.RWMutex
+	clock   Clock = wallClock{}
+)
+
+func getClock() Clock {
+	clockMu.RLock()
+	defer clockMu.RUnlock()
+	return clock
+}
+
+// Now returns the current time of the task lifecycle. It is the wall clock,
+// unless a virtual clock is set by SetClock, e.g. in the simulator.
+func Now() time.Time {
+	return getClock().Now()
+}
+
+// After waits for the duration on the clock of the task lifecycle
+func After(d time.Duration) <-chan time.Time {
+	return getClock().After(d)
+}
+
+// WithDeadline is context.WithDeadline on the clock of the task lifecycle
+func WithDeadline(ctx context.Context, deadline time.Time) (context.Context, context.CancelFunc) {
+	c := getClock()
+	if _, ok := c.(wallClock); ok {
+		return context.WithDeadline(ctx, deadline)
+	}
+	ctx1, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-c.After(deadline.Sub(c.Now())):
+			cancel()
+		case <-ctx1.Done():
+		}
+	}()
+	return ctx1, cancel
+}
+
+// SetClock replaces the clock of the task lifecycle, a nil clock restores the wall clock
+func SetClock(c Clock) {
+	clockMu.Lock()
+	defer clockMu.Unlock()
+	if c == nil {
+		c = wallClock{}
+	}
+	clock = c
+}
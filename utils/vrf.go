@@ -2,8 +2,8 @@ package utils
 
 import "math/big"
 
-func VrfNeedValidation(vrfNumber []byte) bool {
+func VrfNeedValidation(vrfNumber []byte, modulus uint64) bool {
 	number := big.NewInt(0).SetBytes(vrfNumber)
-	r := big.NewInt(0).Mod(number, big.NewInt(100)).Uint64()
+	r := big.NewInt(0).Mod(number, big.NewInt(0).SetUint64(modulus)).Uint64()
 	return r == 0
 }
@@ -0,0 +1,58 @@
+package validator
+
+import (
+	"encoding/binary"
+	"image"
+	"image/png"
+	"io"
+
+	"github.com/corona10/goimagehash"
+)
+
+type imageHashValidator struct {
+	name     string
+	hashFunc func(img image.Image) (*goimagehash.ImageHash, error)
+}
+
+func (v *imageHashValidator) Name() string {
+	return v.name
+}
+
+func (v *imageHashValidator) Hash(reader io.Reader) ([]byte, error) {
+	img, err := png.Decode(reader)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := v.hashFunc(img)
+	if err != nil {
+		return nil, err
+	}
+
+	bs := make([]byte, hash.Bits()/8)
+	binary.BigEndian.PutUint64(bs, hash.GetHash())
+	return bs, nil
+}
+
+func (v *imageHashValidator) HashSize() int {
+	return 8
+}
+
+func (v *imageHashValidator) Distances(score1, score2 []byte) ([]uint64, error) {
+	return hammingDistances(score1, score2, v.HashSize())
+}
+
+func (v *imageHashValidator) Compare(score1, score2 []byte, threshold float64) bool {
+	return compareHashes(score1, score2, v.HashSize(), threshold)
+}
+
+func perceptionHash(img image.Image) (*goimagehash.ImageHash, error) {
+	return goimagehash.PerceptionHash(img)
+}
+
+func differenceHash(img image.Image) (*goimagehash.ImageHash, error) {
+	return goimagehash.DifferenceHash(img)
+}
+
+func averageHash(img image.Image) (*goimagehash.ImageHash, error) {
+	return goimagehash.AverageHash(img)
+}
@@ -0,0 +1,133 @@
+package validator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash/fnv"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// exactHashValidator requires the results to be identical, or identical after normalized
+type exactHashValidator struct {
+	name      string
+	normalize bool
+}
+
+func (v *exactHashValidator) Name() string {
+	return v.name
+}
+
+func (v *exactHashValidator) Hash(reader io.Reader) ([]byte, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if v.normalize {
+		content = []byte(strings.Join(tokenize(string(content)), " "))
+	}
+	h := sha256.Sum256(content)
+	return h[:], nil
+}
+
+func (v *exactHashValidator) HashSize() int {
+	return sha256.Size
+}
+
+// Distances of exact hashes are 0 when the hashes are equal, or 1 otherwise
+func (v *exactHashValidator) Distances(score1, score2 []byte) ([]uint64, error) {
+	if len(score1) != len(score2) || len(score1)%v.HashSize() != 0 {
+		return nil, errScoreSizeMismatch
+	}
+	res := make([]uint64, 0, len(score1)/v.HashSize())
+	for i := 0; i < len(score1); i += v.HashSize() {
+		if bytes.Equal(score1[i:i+v.HashSize()], score2[i:i+v.HashSize()]) {
+			res = append(res, 0)
+		} else {
+			res = append(res, 1)
+		}
+	}
+	return res, nil
+}
+
+func (v *exactHashValidator) Compare(score1, score2 []byte, threshold float64) bool {
+	return len(score1)%v.HashSize() == 0 && bytes.Equal(score1, score2)
+}
+
+// simHashValidator compares the results by the simhash of their tokens,
+// so that results with a few different tokens are considered the same
+type simHashValidator struct{}
+
+func (v *simHashValidator) Name() string {
+	return "simhash"
+}
+
+func (v *simHashValidator) Hash(reader io.Reader) ([]byte, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var weights [64]int
+	for _, token := range tokenize(string(content)) {
+		h := fnv.New64a()
+		h.Write([]byte(token))
+		sum := h.Sum64()
+		for i := 0; i < 64; i++ {
+			if sum&(1<<uint(i)) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+	var res uint64
+	for i := 0; i < 64; i++ {
+		if weights[i] > 0 {
+			res |= 1 << uint(i)
+		}
+	}
+
+	bs := make([]byte, 8)
+	binary.BigEndian.PutUint64(bs, res)
+	return bs, nil
+}
+
+func (v *simHashValidator) HashSize() int {
+	return 8
+}
+
+func (v *simHashValidator) Distances(score1, score2 []byte) ([]uint64, error) {
+	return hammingDistances(score1, score2, v.HashSize())
+}
+
+func (v *simHashValidator) Compare(score1, score2 []byte, threshold float64) bool {
+	return compareHashes(score1, score2, v.HashSize(), threshold)
+}
+
+// tokenize splits the text into lower case words and punctuations, whitespaces are dropped
+func tokenize(text string) []string {
+	var tokens []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			current.WriteRune(unicode.ToLower(r))
+		default:
+			flush()
+			tokens = append(tokens, string(r))
+		}
+	}
+	flush()
+	return tokens
+}
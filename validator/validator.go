@@ -0,0 +1,79 @@
+package validator
+
+import (
+	"crynux_relay/utils"
+	"errors"
+	"io"
+	"sync"
+)
+
+// Validator computes the score of the result files of a task, and decides whether
+// the results of two tasks are the same by their scores.
+// The score of a task is the concatenated hashes of its result files.
+type Validator interface {
+	Name() string
+	// Hash returns the hash of a result file
+	Hash(reader io.Reader) ([]byte, error)
+	// HashSize is the size of the hash of a result file in bytes
+	HashSize() int
+	// Distances returns the distance between each pair of the hashes in two scores
+	Distances(score1, score2 []byte) ([]uint64, error)
+	// Compare returns whether two scores are considered the same result under the threshold
+	Compare(score1, score2 []byte, threshold float64) bool
+}
+
+var errScoreSizeMismatch = errors.New("score size mismatch")
+
+var (
+	validatorsMu sync.RWMutex
+	validators   = make(map[string]Validator)
+)
+
+func Register(v Validator) {
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+	validators[v.Name()] = v
+}
+
+func Get(name string) (Validator, bool) {
+	validatorsMu.RLock()
+	defer validatorsMu.RUnlock()
+	v, ok := validators[name]
+	return v, ok
+}
+
+func init() {
+	Register(&imageHashValidator{name: "phash", hashFunc: perceptionHash})
+	Register(&imageHashValidator{name: "dhash", hashFunc: differenceHash})
+	Register(&imageHashValidator{name: "ahash", hashFunc: averageHash})
+	Register(&exactHashValidator{name: "sha256", normalize: false})
+	Register(&exactHashValidator{name: "text", normalize: true})
+	Register(&simHashValidator{})
+}
+
+// hammingDistances returns the hamming distance of each pair of the hashes in two scores
+func hammingDistances(score1, score2 []byte, hashSize int) ([]uint64, error) {
+	if len(score1) != len(score2) || len(score1)%hashSize != 0 {
+		return nil, errScoreSizeMismatch
+	}
+	res := make([]uint64, 0, len(score1)/hashSize)
+	for i := 0; i < len(score1); i += hashSize {
+		res = append(res, uint64(utils.HammingDistance(score1[i:i+hashSize], score2[i:i+hashSize])))
+	}
+	return res, nil
+}
+
+// compareHashes compares each pair of the hashes in two scores by hamming distance,
+// scores are the same when all the distances are less than the threshold
+func compareHashes(score1, score2 []byte, hashSize int, threshold float64) bool {
+	distances, err := hammingDistances(score1, score2, hashSize)
+	if err != nil {
+		return false
+	}
+	for _, distance := range distances {
+		if float64(distance) >= threshold {
+			return false
+		}
+	}
+	return true
+}